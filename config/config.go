@@ -0,0 +1,432 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/joho/godotenv"
+)
+
+// Config is everything the server reads from its environment. Load fills
+// it from, in order of precedence, the process environment, the file named
+// by CONFIG_FILE and a .env file in the working directory, falling back to
+// the defaults below.
+//
+//	APP_ENV                  development (default) or production
+//	ADDR                     listen address, default :8080; PORT=n is short for :n
+//	BASE_URL                 origin for emailed links; required in production,
+//	                         default http://localhost:PORT in development
+//	JWTKEY                   session signing key, at least 32 bytes
+//	DATABASE_URL             Postgres URL; otherwise built from DB_USER, DB_PASS,
+//	                         DB_HOST, DB_PORT (5432), DB_NAME and DB_SSLMODE (prefer)
+//	DB_MAX_CONNS             pool size, default 10
+//	DB_MIN_CONNS             idle connections kept open, default 0
+//	DB_MAX_CONN_LIFETIME     default 1h
+//	DB_MAX_CONN_IDLE_TIME    default 30m
+//	TLS_MODE                 off (default), file, or proxy when a proxy terminates TLS
+//	TLS_CERT_FILE            certificate and key for TLS_MODE=file, reloaded
+//	TLS_KEY_FILE             when they change
+//	HTTP_REDIRECT_ADDR       with TLS_MODE=file, also listen here and redirect to HTTPS
+//	HSTS_MAX_AGE             Strict-Transport-Security max-age when TLS is on,
+//	                         default 8760h (a year); 0 turns the header off
+//	CSP_REPORT_ONLY          true sends the Content-Security-Policy as report-only
+//	TEMPLATE_RELOAD          true re-reads page templates on every request;
+//	                         default true in development
+//	ASSETS_DIR               read templates, static files and chat safety lists
+//	                         from this checkout instead of the binary; default
+//	                         . in development; static files reload in development
+//	SAFETY_DIR               read wordlists/*.txt and crisis_resources.json from
+//	                         here instead; production needs a non-empty slur list
+//	AVATAR_DIR               where uploaded avatars are stored; default
+//	                         data/avatars in development, required in production
+//	DRAIN_DELAY              how long to fail readiness before closing connections
+//	                         on shutdown, at least one probe interval; default
+//	                         10s in production, 0 in development
+//	SMTP_ADDR                host:port of the mail server; unset logs mail instead
+//	SMTP_FROM, SMTP_USER, SMTP_PASS
+type Config struct {
+	Env     Environment
+	Addr    string
+	BaseURL string
+	JWTKey  []byte
+
+	// CSPReportOnly sends the Content-Security-Policy without enforcing it
+	CSPReportOnly bool
+
+	// ReloadTemplates parses page templates per request instead of once
+	ReloadTemplates bool
+
+	// AssetsDir holds frontend/ and assets/ to use in place of the copies
+	// embedded in the binary; empty uses the embedded ones
+	AssetsDir string
+
+	// SafetyDir holds wordlists/ and crisis_resources.json to use in place
+	// of the copies in AssetsDir or the binary; empty uses those
+	SafetyDir string
+
+	// AvatarDir is the directory uploaded avatars are written to
+	AvatarDir string
+
+	// DrainDelay is how long shutdown fails the readiness probe, so load
+	// balancers stop sending traffic, before it closes connections
+	DrainDelay time.Duration
+
+	Database Database
+	TLS      TLS
+	SMTP     SMTP
+}
+
+// Environment is the deployment profile.
+type Environment string
+
+const (
+	Development Environment = "development"
+	Production  Environment = "production"
+)
+
+// MinJWTKeyLength is the shortest signing key Load accepts, in bytes.
+const MinJWTKeyLength = 32
+
+// Database says where Postgres is and how big the pool gets.
+type Database struct {
+	// URL wins over the individual fields when set
+	URL string
+
+	Host     string
+	Port     string
+	User     string
+	Password string
+	Name     string
+	SSLMode  string
+
+	MaxConns        int32
+	MinConns        int32
+	MaxConnLifetime time.Duration
+	MaxConnIdleTime time.Duration
+}
+
+// TLSMode says who terminates TLS.
+type TLSMode string
+
+const (
+	// TLSOff serves plain HTTP
+	TLSOff TLSMode = "off"
+	// TLSFile serves HTTPS with a certificate and key from disk
+	TLSFile TLSMode = "file"
+	// TLSProxy serves plain HTTP behind a proxy that speaks HTTPS to clients
+	TLSProxy TLSMode = "proxy"
+)
+
+type TLS struct {
+	Mode     TLSMode
+	CertFile string
+	KeyFile  string
+
+	// RedirectAddr is an optional plain HTTP listener that sends
+	// everything to HTTPS
+	RedirectAddr string
+
+	// HSTSMaxAge is how long browsers should insist on HTTPS; zero sends
+	// no Strict-Transport-Security header
+	HSTSMaxAge time.Duration
+}
+
+// SMTP configures outgoing mail. An empty Addr means no mail server.
+type SMTP struct {
+	Addr     string
+	From     string
+	User     string
+	Password string
+}
+
+// Load reads and validates the configuration.
+func Load() (*Config, error) {
+	var files []map[string]string
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		values, err := godotenv.Read(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading CONFIG_FILE: %w", err)
+		}
+		files = append(files, values)
+	}
+	values, err := godotenv.Read(".env")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("reading .env: %w", err)
+	}
+	files = append(files, values)
+
+	return load(func(key string) (string, bool) {
+		if v, ok := os.LookupEnv(key); ok {
+			return v, true
+		}
+		for _, f := range files {
+			if v, ok := f[key]; ok {
+				return v, true
+			}
+		}
+		return "", false
+	})
+}
+
+// load builds a Config from lookup and validates it.
+func load(lookup func(string) (string, bool)) (*Config, error) {
+	s := &source{lookup: lookup}
+
+	cfg := &Config{
+		Env:     Environment(strings.ToLower(s.str("APP_ENV", string(Development)))),
+		Addr:    s.str("ADDR", ""),
+		BaseURL: strings.TrimSuffix(s.str("BASE_URL", ""), "/"),
+		JWTKey:  []byte(s.str("JWTKEY", "")),
+
+		CSPReportOnly: s.bool("CSP_REPORT_ONLY", false),
+
+		Database: Database{
+			URL:             s.str("DATABASE_URL", ""),
+			Host:            s.str("DB_HOST", ""),
+			Port:            s.str("DB_PORT", "5432"),
+			User:            s.str("DB_USER", ""),
+			Password:        s.str("DB_PASS", ""),
+			Name:            s.str("DB_NAME", ""),
+			SSLMode:         s.str("DB_SSLMODE", "prefer"),
+			MaxConns:        s.int32("DB_MAX_CONNS", 10),
+			MinConns:        s.int32("DB_MIN_CONNS", 0),
+			MaxConnLifetime: s.duration("DB_MAX_CONN_LIFETIME", time.Hour),
+			MaxConnIdleTime: s.duration("DB_MAX_CONN_IDLE_TIME", 30*time.Minute),
+		},
+		TLS: TLS{
+			Mode:     TLSMode(strings.ToLower(s.str("TLS_MODE", string(TLSOff)))),
+			CertFile: s.str("TLS_CERT_FILE", ""),
+			KeyFile:  s.str("TLS_KEY_FILE", ""),
+
+			RedirectAddr: s.str("HTTP_REDIRECT_ADDR", ""),
+			HSTSMaxAge:   s.duration("HSTS_MAX_AGE", 365*24*time.Hour),
+		},
+		SMTP: SMTP{
+			Addr:     s.str("SMTP_ADDR", ""),
+			From:     s.str("SMTP_FROM", ""),
+			User:     s.str("SMTP_USER", ""),
+			Password: s.str("SMTP_PASS", ""),
+		},
+	}
+	if cfg.Addr == "" {
+		cfg.Addr = ":" + s.str("PORT", "8080")
+	}
+	if cfg.BaseURL == "" && cfg.Env == Development {
+		cfg.BaseURL = localOrigin(cfg.Addr)
+	}
+	cfg.ReloadTemplates = s.bool("TEMPLATE_RELOAD", cfg.Env == Development)
+	assetsDir := ""
+	if cfg.Env == Development {
+		assetsDir = "."
+	}
+	cfg.AssetsDir = s.str("ASSETS_DIR", assetsDir)
+	cfg.SafetyDir = s.str("SAFETY_DIR", "")
+	drainDelay := 10 * time.Second
+	if cfg.Env == Development {
+		drainDelay = 0
+	}
+	cfg.DrainDelay = s.duration("DRAIN_DELAY", drainDelay)
+	avatarDir := ""
+	if cfg.Env == Development {
+		avatarDir = "data/avatars"
+	}
+	cfg.AvatarDir = s.str("AVATAR_DIR", avatarDir)
+
+	if err := errors.Join(append(s.errs, cfg.Validate())...); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// localOrigin is the origin a server listening on addr can be reached at
+// from the same machine.
+func localOrigin(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "http://localhost"
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
+
+// Validate reports every problem with c at once.
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	switch c.Env {
+	case Development, Production:
+	default:
+		add("APP_ENV must be %q or %q, not %q", Development, Production, c.Env)
+	}
+	if c.Addr == "" {
+		add("ADDR is empty")
+	}
+	// Emailed links are never built from the request's Host header, which
+	// the client controls
+	if c.BaseURL == "" {
+		add("BASE_URL is not set")
+	} else {
+		if u, err := url.Parse(c.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("BASE_URL must be an http or https origin, not %q", c.BaseURL)
+		}
+	}
+
+	switch {
+	case len(c.JWTKey) == 0:
+		add("JWTKEY is not set")
+	case len(c.JWTKey) < MinJWTKeyLength:
+		add("JWTKEY is %d bytes; use at least %d random bytes", len(c.JWTKey), MinJWTKeyLength)
+	}
+
+	errs = append(errs, c.Database.validate())
+
+	switch c.TLS.Mode {
+	case TLSOff, TLSProxy:
+	case TLSFile:
+		if c.TLS.CertFile == "" || c.TLS.KeyFile == "" {
+			add("TLS_MODE=file needs TLS_CERT_FILE and TLS_KEY_FILE")
+		}
+	default:
+		add("TLS_MODE must be %q, %q or %q, not %q", TLSOff, TLSFile, TLSProxy, c.TLS.Mode)
+	}
+	if c.TLS.RedirectAddr != "" && c.TLS.Mode != TLSFile {
+		add("HTTP_REDIRECT_ADDR needs TLS_MODE=file")
+	}
+	if c.DrainDelay < 0 {
+		add("DRAIN_DELAY can't be negative")
+	}
+	if c.TLS.HSTSMaxAge < 0 {
+		add("HSTS_MAX_AGE can't be negative")
+	}
+	// Production cookies are Secure, which only works over HTTPS
+	if c.Env == Production && c.TLS.Mode == TLSOff {
+		add("production needs TLS_MODE=file, or TLS_MODE=proxy behind an HTTPS proxy")
+	}
+	if c.AvatarDir == "" {
+		add("AVATAR_DIR is not set")
+	}
+
+	if c.SMTP.Addr != "" && c.SMTP.From == "" {
+		add("SMTP_ADDR is set but SMTP_FROM is not")
+	}
+	return errors.Join(errs...)
+}
+
+func (d Database) validate() error {
+	var errs []error
+	if d.URL != "" {
+		if _, err := pgxpool.ParseConfig(d.URL); err != nil {
+			errs = append(errs, fmt.Errorf("DATABASE_URL: %w", err))
+		}
+	} else if d.Host == "" || d.User == "" || d.Name == "" {
+		errs = append(errs, errors.New("set DATABASE_URL, or DB_HOST, DB_USER and DB_NAME"))
+	}
+
+	switch d.SSLMode {
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		errs = append(errs, fmt.Errorf("DB_SSLMODE %q is not a libpq sslmode", d.SSLMode))
+	}
+	if d.MaxConns < 1 {
+		errs = append(errs, fmt.Errorf("DB_MAX_CONNS must be at least 1, not %d", d.MaxConns))
+	}
+	if d.MinConns < 0 || d.MinConns > d.MaxConns {
+		errs = append(errs, fmt.Errorf("DB_MIN_CONNS must be between 0 and DB_MAX_CONNS, not %d", d.MinConns))
+	}
+	return errors.Join(errs...)
+}
+
+// DSN is the connection string for d.
+func (d Database) DSN() string {
+	if d.URL != "" {
+		return d.URL
+	}
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(d.User, d.Password),
+		Host:     d.Host + ":" + d.Port,
+		Path:     "/" + d.Name,
+		RawQuery: url.Values{"sslmode": {d.SSLMode}}.Encode(),
+	}
+	return u.String()
+}
+
+// SecureCookies says whether cookies should be marked Secure: in
+// production, and whenever clients reach us over HTTPS.
+func (c *Config) SecureCookies() bool {
+	return c.Env == Production || c.TLS.Mode != TLSOff
+}
+
+// HSTS is the max-age to send in Strict-Transport-Security, or zero when
+// clients may be on plain HTTP.
+func (c *Config) HSTS() time.Duration {
+	if c.TLS.Mode == TLSOff {
+		return 0
+	}
+	return c.TLS.HSTSMaxAge
+}
+
+// source reads typed values, collecting parse errors for Load to report.
+type source struct {
+	lookup func(string) (string, bool)
+	errs   []error
+}
+
+func (s *source) str(key, def string) string {
+	if v, ok := s.lookup(key); ok && v != "" {
+		return v
+	}
+	return def
+}
+
+func (s *source) int32(key string, def int32) int32 {
+	v := s.str(key, "")
+	if v == "" {
+		return def
+	}
+	n, err := strconv.ParseInt(v, 10, 32)
+	if err != nil {
+		s.errs = append(s.errs, fmt.Errorf("%s: %q is not a whole number", key, v))
+		return def
+	}
+	return int32(n)
+}
+
+func (s *source) bool(key string, def bool) bool {
+	v := s.str(key, "")
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		s.errs = append(s.errs, fmt.Errorf("%s: %q is not true or false", key, v))
+		return def
+	}
+	return b
+}
+
+func (s *source) duration(key string, def time.Duration) time.Duration {
+	v := s.str(key, "")
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		s.errs = append(s.errs, fmt.Errorf("%s: %q is not a duration like 30m", key, v))
+		return def
+	}
+	return d
+}
@@ -4,59 +4,27 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"net/url"
-	"os"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	_ "github.com/jackc/pgx/v5/stdlib"
-	"github.com/joho/godotenv"
 )
 
 // var DB *sql.DB
 var DB *pgxpool.Pool
 
-func Init() {
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using environment variables")
+// InitDB connects the DB pool.
+func InitDB(cfg Database) error {
+	poolConfig, err := pgxpool.ParseConfig(cfg.DSN())
+	if err != nil {
+		return fmt.Errorf("invalid database config: %w", err)
 	}
-}
-
-func InitDB() error {
-
-	// user := os.Getenv("DB_USER")
-	// pass := os.Getenv("DB_PASS")
-	// host := os.Getenv("DB_HOST")
-	// port := os.Getenv("DB_PORT")
-	// name := os.Getenv("DB_NAME")
-
-	// dsn := fmt.Sprintf(
-	// 	"postgres://%s:%s@%s:%s/%s?sslmode=disable",
-	// 	user, url.QueryEscape(pass), host, port, name,
-	// )
-
-	// var err error
-	// DB, err = sql.Open("pgx", dsn)
-	// if err != nil {
-	// 	return err
-	// }
-
-	// return DB.Ping()
-
-	user := os.Getenv("DB_USER")
-	pass := os.Getenv("DB_PASS")
-	host := os.Getenv("DB_HOST")
-	port := os.Getenv("DB_PORT")
-	name := os.Getenv("DB_NAME")
-
-	passEscaped := url.QueryEscape(pass)
-
-	dsn := fmt.Sprintf(
-		"postgres://%s:%s@%s:%s/%s?sslmode=disable",
-		user, passEscaped, host, port, name,
-	)
+	poolConfig.MaxConns = cfg.MaxConns
+	poolConfig.MinConns = cfg.MinConns
+	poolConfig.MaxConnLifetime = cfg.MaxConnLifetime
+	poolConfig.MaxConnIdleTime = cfg.MaxConnIdleTime
 
 	ctx := context.Background()
-	pool, err := pgxpool.New(ctx, dsn)
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
 		return fmt.Errorf("failed to create connection pool: %w", err)
 	}
@@ -71,9 +39,3 @@ func InitDB() error {
 	log.Println("Connected to PostgreSQL successfully!")
 	return nil
 }
-
-func LoadEnv() {
-	if err := godotenv.Load(); err != nil {
-		log.Fatal("Error loading .env file")
-	}
-}
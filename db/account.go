@@ -0,0 +1,214 @@
+package db
+
+import (
+	"Remainwith/config"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	// ErrEmailTaken is returned when another account already uses an email.
+	ErrEmailTaken = errors.New("email already in use")
+
+	// ErrEmailChangeInvalid is returned for unknown, used or expired
+	// confirmation tokens.
+	ErrEmailChangeInvalid = errors.New("email change link is invalid or has expired")
+)
+
+// InitEmailChanges creates the table of pending email changes.
+func InitEmailChanges(ctx context.Context) error {
+	if config.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	_, err := config.DB.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS email_changes (
+			token_hash TEXT PRIMARY KEY,
+			user_id INT NOT NULL,
+			new_email TEXT NOT NULL,
+			expires_at TIMESTAMPTZ NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS email_changes_user_idx ON email_changes (user_id);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create email_changes table: %w", err)
+	}
+	return nil
+}
+
+// GetUserByID returns the account with the given ID.
+func (p *Postgres) GetUserByID(ctx context.Context, userID int) (*Userinfo, error) {
+	if p.pool == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	return getUserByID(ctx, p.pool, userID)
+}
+
+// queryRower is satisfied by both the pool and a transaction.
+type queryRower interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func getUserByID(ctx context.Context, q queryRower, userID int) (*Userinfo, error) {
+	user := &Userinfo{}
+	err := q.QueryRow(ctx, `
+		SELECT id, name, email, password, role, suspended_until, suspended_reason
+		FROM users WHERE id = $1`, userID).
+		Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Role, &user.SuspendedUntil, &user.SuspendedReason)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// UpdateUserName changes a user's account name.
+func (p *Postgres) UpdateUserName(ctx context.Context, userID int, name string) error {
+	if p.pool == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	tag, err := p.pool.Exec(ctx, `UPDATE users SET name = $2 WHERE id = $1`, userID, name)
+	if err != nil {
+		return fmt.Errorf("failed to update name: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("user not found")
+	}
+	return nil
+}
+
+// SetBio changes the bio on a user's public profile.
+func (p *Postgres) SetBio(ctx context.Context, userID int, bio string) error {
+	if p.pool == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	_, err := p.pool.Exec(ctx, `
+		INSERT INTO profiles (user_id, bio, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (user_id) DO UPDATE SET
+			bio = EXCLUDED.bio,
+			updated_at = NOW()`, userID, bio)
+	if err != nil {
+		return fmt.Errorf("failed to set bio: %w", err)
+	}
+	return nil
+}
+
+// CreateEmailChange records a pending change of userID's email to
+// newEmail and returns the token that confirms it. Only the token's hash
+// is stored. Earlier pending changes for the user are discarded.
+func (p *Postgres) CreateEmailChange(ctx context.Context, userID int, newEmail string, ttl time.Duration) (string, error) {
+	if p.pool == nil {
+		return "", fmt.Errorf("database not initialized")
+	}
+
+	taken, err := p.CheckUser(ctx, newEmail)
+	if err != nil {
+		return "", err
+	}
+	if taken {
+		return "", ErrEmailTaken
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(raw)
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM email_changes WHERE user_id = $1`, userID); err != nil {
+		return "", err
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO email_changes (token_hash, user_id, new_email, expires_at)
+		VALUES ($1, $2, $3, $4)`,
+		hashToken(token), userID, newEmail, time.Now().Add(ttl))
+	if err != nil {
+		return "", fmt.Errorf("failed to save email change: %w", err)
+	}
+	return token, tx.Commit(ctx)
+}
+
+// ConfirmEmailChange applies the pending change for token and returns the
+// updated account along with its previous email. A token works once.
+func (p *Postgres) ConfirmEmailChange(ctx context.Context, token string) (*Userinfo, string, error) {
+	if p.pool == nil {
+		return nil, "", fmt.Errorf("database not initialized")
+	}
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	defer tx.Rollback(ctx)
+
+	var userID int
+	var newEmail string
+	var fresh bool
+	err = tx.QueryRow(ctx, `
+		DELETE FROM email_changes
+		WHERE token_hash = $1
+		RETURNING user_id, new_email, expires_at > NOW()`, hashToken(token)).
+		Scan(&userID, &newEmail, &fresh)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, "", ErrEmailChangeInvalid
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if !fresh {
+		// Keep the delete so expired tokens don't linger
+		if err := tx.Commit(ctx); err != nil {
+			return nil, "", err
+		}
+		return nil, "", ErrEmailChangeInvalid
+	}
+
+	var oldEmail string
+	err = tx.QueryRow(ctx, `SELECT email FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&oldEmail)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, "", ErrEmailChangeInvalid
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE users SET email = $2 WHERE id = $1`, userID, newEmail); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, "", ErrEmailTaken
+		}
+		return nil, "", err
+	}
+
+	user, err := getUserByID(ctx, tx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	return user, oldEmail, tx.Commit(ctx)
+}
+
+// hashToken is how confirmation tokens are stored.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
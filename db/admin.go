@@ -0,0 +1,309 @@
+package db
+
+import (
+	"Remainwith/config"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// User roles, from least to most privileged.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// roleRanks orders roles so that a higher role satisfies a lower one.
+var roleRanks = map[string]int{RoleUser: 0, RoleModerator: 1, RoleAdmin: 2}
+
+// ValidRole reports whether role is one of the known roles.
+func ValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// RoleAtLeast reports whether role grants everything min does.
+func RoleAtLeast(role, min string) bool {
+	r, ok := roleRanks[role]
+	return ok && r >= roleRanks[min]
+}
+
+// Report review states.
+const (
+	ReportOpen      = "open"
+	ReportActioned  = "actioned"
+	ReportDismissed = "dismissed"
+)
+
+// UserAccount is a user as seen from the admin console.
+type UserAccount struct {
+	ID              int
+	Name            string
+	Email           string
+	Role            string
+	SuspendedUntil  *time.Time
+	SuspendedReason string
+	CreatedAt       time.Time
+}
+
+// Suspended reports whether the account is suspended at now.
+func (u UserAccount) Suspended(now time.Time) bool {
+	return u.SuspendedUntil != nil && u.SuspendedUntil.After(now)
+}
+
+// AuditEntry is one recorded staff action.
+type AuditEntry struct {
+	ID         int
+	ActorID    int
+	ActorName  string
+	Action     string
+	TargetType string
+	TargetID   string
+	Details    string
+	CreatedAt  time.Time
+}
+
+// InitAdmin adds roles and suspensions to users and creates the audit_log
+// table if it does not exist.
+func InitAdmin(ctx context.Context) error {
+	if config.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	_, err := config.DB.Exec(ctx, `
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'user';
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS suspended_until TIMESTAMPTZ;
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS suspended_reason TEXT NOT NULL DEFAULT '';
+		CREATE TABLE IF NOT EXISTS audit_log (
+			id SERIAL PRIMARY KEY,
+			actor_id INT NOT NULL,
+			action TEXT NOT NULL,
+			target_type TEXT NOT NULL,
+			target_id TEXT NOT NULL,
+			details TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS audit_log_created_idx ON audit_log (created_at DESC);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create admin tables: %w", err)
+	}
+	return nil
+}
+
+// GetUserAccount returns the account for id.
+func GetUserAccount(ctx context.Context, id int) (*UserAccount, error) {
+	if config.DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	u := &UserAccount{}
+	err := config.DB.QueryRow(ctx,
+		`SELECT id, name, email, role, suspended_until, suspended_reason, created_at
+		 FROM users WHERE id = $1`, id,
+	).Scan(&u.ID, &u.Name, &u.Email, &u.Role, &u.SuspendedUntil, &u.SuspendedReason, &u.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("user not found")
+		}
+		return nil, err
+	}
+	return u, nil
+}
+
+// SearchUsers returns accounts whose name or email contains query, newest
+// first. An empty query lists the most recent accounts.
+func SearchUsers(ctx context.Context, query string, limit int) ([]UserAccount, error) {
+	if config.DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	rows, err := config.DB.Query(ctx,
+		`SELECT id, name, email, role, suspended_until, suspended_reason, created_at
+		 FROM users
+		 WHERE $1 = '' OR name ILIKE '%' || $1 || '%' OR email ILIKE '%' || $1 || '%'
+		 ORDER BY created_at DESC
+		 LIMIT $2`, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []UserAccount
+	for rows.Next() {
+		var u UserAccount
+		if err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.Role, &u.SuspendedUntil, &u.SuspendedReason, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// SetUserRole changes a user's role.
+func SetUserRole(ctx context.Context, id int, role string) error {
+	if config.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	if !ValidRole(role) {
+		return fmt.Errorf("unknown role %q", role)
+	}
+
+	_, err := config.DB.Exec(ctx, `UPDATE users SET role = $1 WHERE id = $2`, role, id)
+	if err != nil {
+		return fmt.Errorf("failed to set role: %w", err)
+	}
+	return nil
+}
+
+// SuspendUser stops a user signing in until the given time.
+func SuspendUser(ctx context.Context, id int, until time.Time, reason string) error {
+	if config.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	_, err := config.DB.Exec(ctx,
+		`UPDATE users SET suspended_until = $1, suspended_reason = $2 WHERE id = $3`, until, reason, id)
+	if err != nil {
+		return fmt.Errorf("failed to suspend user: %w", err)
+	}
+	return nil
+}
+
+// UnsuspendUser lifts a suspension.
+func UnsuspendUser(ctx context.Context, id int) error {
+	if config.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	_, err := config.DB.Exec(ctx,
+		`UPDATE users SET suspended_until = NULL, suspended_reason = '' WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to unsuspend user: %w", err)
+	}
+	return nil
+}
+
+// GetSuspensions returns the end of every suspension still in force at
+// now, keyed by user ID.
+func GetSuspensions(ctx context.Context, now time.Time) (map[int]time.Time, error) {
+	if config.DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	rows, err := config.DB.Query(ctx,
+		`SELECT id, suspended_until FROM users WHERE suspended_until > $1`, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suspensions := make(map[int]time.Time)
+	for rows.Next() {
+		var id int
+		var until time.Time
+		if err := rows.Scan(&id, &until); err != nil {
+			return nil, err
+		}
+		suspensions[id] = until
+	}
+	return suspensions, rows.Err()
+}
+
+// ListReports returns reports with status, oldest first so the queue is
+// worked in order. An empty status lists every report, newest first.
+func ListReports(ctx context.Context, status string, limit int) ([]Report, error) {
+	if config.DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	query := `SELECT id, reporter_id, message_id, reported_user_id, reason, details, content, status, created_at
+		 FROM reports WHERE status = $1 ORDER BY created_at LIMIT $2`
+	args := []any{status, limit}
+	if status == "" {
+		query = `SELECT id, reporter_id, message_id, reported_user_id, reason, details, content, status, created_at
+		 FROM reports ORDER BY created_at DESC LIMIT $1`
+		args = []any{limit}
+	}
+
+	rows, err := config.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reports: %w", err)
+	}
+	defer rows.Close()
+
+	var reports []Report
+	for rows.Next() {
+		var r Report
+		if err := rows.Scan(&r.ID, &r.ReporterID, &r.MessageID, &r.ReportedUserID,
+			&r.Reason, &r.Details, &r.Content, &r.Status, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		reports = append(reports, r)
+	}
+	return reports, rows.Err()
+}
+
+// ResolveReport marks a report actioned or dismissed.
+func ResolveReport(ctx context.Context, id int, status string) error {
+	if config.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	if status != ReportActioned && status != ReportDismissed {
+		return fmt.Errorf("unknown report status %q", status)
+	}
+
+	_, err := config.DB.Exec(ctx, `UPDATE reports SET status = $1 WHERE id = $2`, status, id)
+	if err != nil {
+		return fmt.Errorf("failed to resolve report: %w", err)
+	}
+	return nil
+}
+
+// LogAudit records a staff action. Failures are returned but callers
+// usually only log them: the action itself has already happened.
+func LogAudit(ctx context.Context, actorID int, action, targetType, targetID, details string) error {
+	if config.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	_, err := config.DB.Exec(ctx,
+		`INSERT INTO audit_log (actor_id, action, target_type, target_id, details)
+		 VALUES ($1, $2, $3, $4, $5)`,
+		actorID, action, targetType, targetID, details)
+	if err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+// ListAuditLog returns the most recent staff actions.
+func ListAuditLog(ctx context.Context, limit int) ([]AuditEntry, error) {
+	if config.DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	rows, err := config.DB.Query(ctx,
+		`SELECT a.id, a.actor_id, COALESCE(u.name, ''), a.action, a.target_type, a.target_id, a.details, a.created_at
+		 FROM audit_log a LEFT JOIN users u ON u.id = a.actor_id
+		 ORDER BY a.created_at DESC
+		 LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		if err := rows.Scan(&e.ID, &e.ActorID, &e.ActorName, &e.Action, &e.TargetType, &e.TargetID, &e.Details, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
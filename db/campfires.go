@@ -0,0 +1,239 @@
+package db
+
+import (
+	"Remainwith/config"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Campfire lifecycle states.
+const (
+	CampfireScheduled = "scheduled"
+	CampfireOpen      = "open"
+	CampfireClosed    = "closed"
+)
+
+// Campfire is a user-hosted conversation around a topic.
+type Campfire struct {
+	ID              int       `json:"id"`
+	Topic           string    `json:"topic"`
+	InterestID      int       `json:"interest_id,omitempty"`
+	Interest        string    `json:"interest,omitempty"`
+	HostID          int       `json:"host_id"`
+	HostName        string    `json:"host_name"`
+	HostAnonymous   bool      `json:"-"` // the host chose anonymous mode for campfires
+	StartsAt        time.Time `json:"starts_at"`
+	MaxParticipants int       `json:"max_participants"`
+	Status          string    `json:"status"`
+	LastActivity    time.Time `json:"last_activity"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// InitCampfires creates the campfires table if it does not exist.
+func InitCampfires(ctx context.Context) error {
+	if config.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	_, err := config.DB.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS campfires (
+			id SERIAL PRIMARY KEY,
+			topic TEXT NOT NULL,
+			interest_id INT,
+			host_id INT NOT NULL,
+			starts_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			max_participants INT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'open',
+			last_activity TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS campfires_status_idx ON campfires (status, starts_at);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create campfires table: %w", err)
+	}
+	return nil
+}
+
+const campfireColumns = `c.id, c.topic, COALESCE(c.interest_id, 0), COALESCE(i.name, ''),
+	c.host_id, u.name, COALESCE(pr.anonymous_campfires, false),
+	c.starts_at, c.max_participants, c.status, c.last_activity, c.created_at`
+
+const campfireFrom = `FROM campfires c
+	JOIN users u ON u.id = c.host_id
+	LEFT JOIN profiles pr ON pr.user_id = c.host_id
+	LEFT JOIN interests i ON i.id = c.interest_id`
+
+func scanCampfire(row pgx.Row) (*Campfire, error) {
+	c := &Campfire{}
+	err := row.Scan(&c.ID, &c.Topic, &c.InterestID, &c.Interest,
+		&c.HostID, &c.HostName, &c.HostAnonymous, &c.StartsAt, &c.MaxParticipants, &c.Status, &c.LastActivity, &c.CreatedAt)
+	return c, err
+}
+
+// NewCampfire creates a campfire. It is scheduled if StartsAt is in the
+// future and open otherwise. The ID, Status and timestamps are filled in.
+func NewCampfire(ctx context.Context, c *Campfire) error {
+	if config.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	if c.Topic == "" {
+		return fmt.Errorf("topic is required")
+	}
+
+	now := time.Now()
+	if c.StartsAt.IsZero() || c.StartsAt.Before(now) {
+		c.StartsAt = now
+	}
+	c.Status = CampfireOpen
+	if c.StartsAt.After(now) {
+		c.Status = CampfireScheduled
+	}
+
+	var interestID *int
+	if c.InterestID != 0 {
+		interestID = &c.InterestID
+	}
+
+	err := config.DB.QueryRow(
+		ctx,
+		`INSERT INTO campfires (topic, interest_id, host_id, starts_at, max_participants, status, last_activity)
+		 VALUES ($1, $2, $3, $4, $5, $6, $4)
+		 RETURNING id, last_activity, created_at`,
+		c.Topic, interestID, c.HostID, c.StartsAt, c.MaxParticipants, c.Status,
+	).Scan(&c.ID, &c.LastActivity, &c.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert campfire: %w", err)
+	}
+	return nil
+}
+
+// GetCampfire returns the campfire with id.
+func GetCampfire(ctx context.Context, id int) (*Campfire, error) {
+	if config.DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	c, err := scanCampfire(config.DB.QueryRow(ctx,
+		`SELECT `+campfireColumns+` `+campfireFrom+` WHERE c.id = $1`, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("campfire not found")
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+// ListCampfires returns campfires that are not closed, open ones first and
+// scheduled ones by start time. A limit of 0 returns all of them.
+func ListCampfires(ctx context.Context, limit int) ([]Campfire, error) {
+	if config.DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	// LIMIT NULL is no limit
+	var n *int
+	if limit > 0 {
+		n = &limit
+	}
+	rows, err := config.DB.Query(ctx,
+		`SELECT `+campfireColumns+` `+campfireFrom+`
+		 WHERE c.status <> 'closed'
+		 ORDER BY c.status = 'open' DESC, c.starts_at
+		 LIMIT $1`, n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query campfires: %w", err)
+	}
+	defer rows.Close()
+
+	var campfires []Campfire
+	for rows.Next() {
+		c, err := scanCampfire(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan campfire: %w", err)
+		}
+		campfires = append(campfires, *c)
+	}
+	return campfires, rows.Err()
+}
+
+// TouchCampfire records activity in a campfire.
+func TouchCampfire(ctx context.Context, id int, at time.Time) error {
+	if config.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	_, err := config.DB.Exec(ctx,
+		`UPDATE campfires SET last_activity = $1 WHERE id = $2 AND last_activity < $1`, at, id)
+	return err
+}
+
+// OpenDueCampfires opens scheduled campfires whose start time has passed
+// and returns their IDs.
+func OpenDueCampfires(ctx context.Context, now time.Time) ([]int, error) {
+	return updateCampfireIDs(ctx,
+		`UPDATE campfires SET status = 'open', last_activity = $1
+		 WHERE status = 'scheduled' AND starts_at <= $1
+		 RETURNING id`, now)
+}
+
+// CloseIdleCampfires closes open campfires with no activity since before
+// and returns their IDs.
+func CloseIdleCampfires(ctx context.Context, before time.Time) ([]int, error) {
+	return updateCampfireIDs(ctx,
+		`UPDATE campfires SET status = 'closed'
+		 WHERE status = 'open' AND last_activity < $1
+		 RETURNING id`, before)
+}
+
+// ReopenCampfire puts a campfire back into the open state with fresh activity.
+func ReopenCampfire(ctx context.Context, id int, at time.Time) error {
+	if config.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	_, err := config.DB.Exec(ctx,
+		`UPDATE campfires SET status = 'open', last_activity = $1 WHERE id = $2`, at, id)
+	return err
+}
+
+// CloseCampfire closes a campfire regardless of its state.
+func CloseCampfire(ctx context.Context, id int) error {
+	if config.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	_, err := config.DB.Exec(ctx, `UPDATE campfires SET status = 'closed' WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to close campfire: %w", err)
+	}
+	return nil
+}
+
+func updateCampfireIDs(ctx context.Context, query string, at time.Time) ([]int, error) {
+	if config.DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	rows, err := config.DB.Query(ctx, query, at)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
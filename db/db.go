@@ -16,6 +16,11 @@ type Userinfo struct {
 	Name     string
 	Email    string
 	Password string // store hashed password
+	Role     string
+
+	// SuspendedUntil is set while an account is suspended
+	SuspendedUntil  *time.Time
+	SuspendedReason string
 }
 
 type Journal struct {
@@ -26,12 +31,42 @@ type Journal struct {
 	CreatedAt time.Time // or time.Time, but for simplicity string
 }
 
-func GetUserByEmail(ctx context.Context, email string) (*Userinfo, error) {
+// InitUsers creates the users and journal tables. Later Init functions
+// add columns to users, so it runs first.
+func InitUsers(ctx context.Context) error {
+	if config.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	_, err := config.DB.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS users (
+			id SERIAL PRIMARY KEY,
+			name TEXT NOT NULL,
+			email TEXT NOT NULL UNIQUE,
+			password TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+		CREATE TABLE IF NOT EXISTS journal (
+			id SERIAL PRIMARY KEY,
+			user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			title TEXT NOT NULL,
+			"desc" TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS journal_user_idx ON journal (user_id, created_at DESC);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create users tables: %w", err)
+	}
+	return nil
+}
+
+func (p *Postgres) GetUserByEmail(ctx context.Context, email string) (*Userinfo, error) {
 	user := &Userinfo{}
 
-	err := config.DB.QueryRow(
+	err := p.pool.QueryRow(
 		ctx,
-		`SELECT id, name, email, password
+		`SELECT id, name, email, password, role, suspended_until, suspended_reason
          FROM users
          WHERE email = $1`,
 		email,
@@ -40,6 +75,9 @@ func GetUserByEmail(ctx context.Context, email string) (*Userinfo, error) {
 		&user.Name,
 		&user.Email,
 		&user.Password,
+		&user.Role,
+		&user.SuspendedUntil,
+		&user.SuspendedReason,
 	)
 
 	if err != nil {
@@ -52,12 +90,12 @@ func GetUserByEmail(ctx context.Context, email string) (*Userinfo, error) {
 	return user, nil
 }
 
-func NewUser(ctx context.Context, name, email, password string) error {
-	if config.DB == nil {
+func (p *Postgres) NewUser(ctx context.Context, name, email, password string) error {
+	if p.pool == nil {
 		return fmt.Errorf("database not initialized")
 	}
 
-	_, err := config.DB.Exec(
+	_, err := p.pool.Exec(
 		ctx,
 		`INSERT INTO users (name, email, password, created_at)
          VALUES ($1, $2, $3, NOW())`,
@@ -108,10 +146,10 @@ func NewUser(ctx context.Context, name, email, password string) error {
 // 	return true, nil
 // }
 
-func CheckUser(ctx context.Context, email string) (bool, error) {
+func (p *Postgres) CheckUser(ctx context.Context, email string) (bool, error) {
 	var exists bool
 
-	err := config.DB.QueryRow(
+	err := p.pool.QueryRow(
 		ctx,
 		`SELECT EXISTS (
             SELECT 1 FROM users WHERE email = $1
@@ -140,12 +178,37 @@ func CheckUser(ctx context.Context, email string) (bool, error) {
 // 	return err
 // }
 
-func GetJournalsByUserID(ctx context.Context, userID int) ([]Journal, error) {
-	if config.DB == nil {
+// ErrJournalNotFound is returned for journal entries that don't exist or
+// belong to someone else.
+var ErrJournalNotFound = errors.New("journal not found")
+
+// GetJournal returns one of userID's journal entries.
+func (p *Postgres) GetJournal(ctx context.Context, id, userID int) (*Journal, error) {
+	if p.pool == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	j := &Journal{}
+	err := p.pool.QueryRow(ctx, `
+		SELECT id, user_id, title, "desc", created_at
+		FROM journal
+		WHERE id = $1 AND user_id = $2`, id, userID).
+		Scan(&j.ID, &j.UserID, &j.Title, &j.Desc, &j.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrJournalNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query journal: %w", err)
+	}
+	return j, nil
+}
+
+func (p *Postgres) GetJournalsByUserID(ctx context.Context, userID int) ([]Journal, error) {
+	if p.pool == nil {
 		return nil, fmt.Errorf("database not initialized")
 	}
 
-	rows, err := config.DB.Query(
+	rows, err := p.pool.Query(
 		ctx,
 		`SELECT id, user_id, title, "desc", created_at
          FROM journal
@@ -175,8 +238,8 @@ func GetJournalsByUserID(ctx context.Context, userID int) ([]Journal, error) {
 	return journals, nil
 }
 
-func UpdateJournal(ctx context.Context, id, userID int, title, description string) error {
-	if config.DB == nil {
+func (p *Postgres) UpdateJournal(ctx context.Context, id, userID int, title, description string) error {
+	if p.pool == nil {
 		return fmt.Errorf("database not initialized")
 	}
 
@@ -184,7 +247,7 @@ func UpdateJournal(ctx context.Context, id, userID int, title, description strin
 		return fmt.Errorf("title and description are required")
 	}
 
-	_, err := config.DB.Exec(
+	tag, err := p.pool.Exec(
 		ctx,
 		`UPDATE journal SET title = $1, "desc" = $2 WHERE id = $3 AND user_id = $4`,
 		title, description, id, userID,
@@ -193,16 +256,19 @@ func UpdateJournal(ctx context.Context, id, userID int, title, description strin
 	if err != nil {
 		return fmt.Errorf("failed to update journal: %w", err)
 	}
+	if tag.RowsAffected() == 0 {
+		return ErrJournalNotFound
+	}
 
 	return nil
 }
 
-func DeleteJournal(ctx context.Context, id, userID int) error {
-	if config.DB == nil {
+func (p *Postgres) DeleteJournal(ctx context.Context, id, userID int) error {
+	if p.pool == nil {
 		return fmt.Errorf("database not initialized")
 	}
 
-	_, err := config.DB.Exec(
+	tag, err := p.pool.Exec(
 		ctx,
 		`DELETE FROM journal WHERE id = $1 AND user_id = $2`,
 		id, userID,
@@ -211,11 +277,14 @@ func DeleteJournal(ctx context.Context, id, userID int) error {
 	if err != nil {
 		return fmt.Errorf("failed to delete journal: %w", err)
 	}
+	if tag.RowsAffected() == 0 {
+		return ErrJournalNotFound
+	}
 
 	return nil
 }
-func NewJournal(ctx context.Context, userID int, title, description string) (int, error) {
-	if config.DB == nil {
+func (p *Postgres) NewJournal(ctx context.Context, userID int, title, description string) (int, error) {
+	if p.pool == nil {
 		return 0, fmt.Errorf("database not initialized")
 	}
 
@@ -225,7 +294,7 @@ func NewJournal(ctx context.Context, userID int, title, description string) (int
 
 	var journalID int
 
-	err := config.DB.QueryRow(
+	err := p.pool.QueryRow(
 		ctx,
 		`INSERT INTO journal (user_id, title, "desc")
 		 VALUES ($1, $2, $3)
@@ -259,42 +328,13 @@ func NewJournal(ctx context.Context, userID int, title, description string) (int
 
 // --- Interests & Onboarding Logic ---
 
-type Interest struct {
-	ID       int    `json:"id"`
-	Name     string `json:"name"`
-	Category string `json:"category"`
-}
-
-// GetAllInterests retrieves all available interests organized for the UI.
-func GetAllInterests(ctx context.Context) ([]Interest, error) {
-	if config.DB == nil {
-		return nil, fmt.Errorf("database not initialized")
-	}
-
-	rows, err := config.DB.Query(ctx, "SELECT id, name, category FROM interests WHERE is_active = true ORDER BY category, id")
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var interests []Interest
-	for rows.Next() {
-		var i Interest
-		if err := rows.Scan(&i.ID, &i.Name, &i.Category); err != nil {
-			return nil, err
-		}
-		interests = append(interests, i)
-	}
-	return interests, nil
-}
-
 // GetUserInterests retrieves the names of interests selected by a user.
-func GetUserInterests(ctx context.Context, userID int) ([]string, error) {
-	if config.DB == nil {
+func (p *Postgres) GetUserInterests(ctx context.Context, userID int) ([]string, error) {
+	if p.pool == nil {
 		return nil, fmt.Errorf("database not initialized")
 	}
 
-	rows, err := config.DB.Query(ctx, "SELECT i.name FROM interests i JOIN user_interests ui ON i.id = ui.interest_id WHERE ui.user_id = $1", userID)
+	rows, err := p.pool.Query(ctx, "SELECT i.name FROM interests i JOIN user_interests ui ON i.id = ui.interest_id WHERE ui.user_id = $1", userID)
 	if err != nil {
 		return nil, err
 	}
@@ -313,12 +353,12 @@ func GetUserInterests(ctx context.Context, userID int) ([]string, error) {
 
 // SaveUserInterests saves the selected interests for a user.
 // It clears existing interests first to allow for updates.
-func SaveUserInterests(ctx context.Context, userID int, interestIDs []int) error {
-	if config.DB == nil {
+func (p *Postgres) SaveUserInterests(ctx context.Context, userID int, interestIDs []int) error {
+	if p.pool == nil {
 		return fmt.Errorf("database not initialized")
 	}
 
-	tx, err := config.DB.Begin(ctx)
+	tx, err := p.pool.Begin(ctx)
 	if err != nil {
 		return err
 	}
@@ -342,12 +382,12 @@ func SaveUserInterests(ctx context.Context, userID int, interestIDs []int) error
 }
 
 // SaveUserInterestsByNames saves the selected interests for a user using interest names.
-func SaveUserInterestsByNames(ctx context.Context, userID int, interestNames []string) error {
-	if config.DB == nil {
+func (p *Postgres) SaveUserInterestsByNames(ctx context.Context, userID int, interestNames []string) error {
+	if p.pool == nil {
 		return fmt.Errorf("database not initialized")
 	}
 
-	tx, err := config.DB.Begin(ctx)
+	tx, err := p.pool.Begin(ctx)
 	if err != nil {
 		return err
 	}
@@ -376,9 +416,9 @@ func SaveUserInterestsByNames(ctx context.Context, userID int, interestNames []s
 // ShouldShowOnboarding checks if the user should see the interest dialog.
 // Criteria: User has NO interests set.
 // This ensures users who haven't selected interests see the dialog.
-func ShouldShowOnboarding(ctx context.Context, userID int) (bool, error) {
+func (p *Postgres) ShouldShowOnboarding(ctx context.Context, userID int) (bool, error) {
 	var hasInterests bool
-	err := config.DB.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM user_interests WHERE user_id = $1)", userID).Scan(&hasInterests)
+	err := p.pool.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM user_interests WHERE user_id = $1)", userID).Scan(&hasInterests)
 	if err != nil {
 		return false, err
 	}
@@ -388,122 +428,3 @@ func ShouldShowOnboarding(ctx context.Context, userID int) (bool, error) {
 
 	return true, nil
 }
-
-// GetSuggestedUsers finds other users who share similar interests.
-func GetSuggestedUsers(ctx context.Context, userID int, limit int) ([]Userinfo, error) {
-	rows, err := config.DB.Query(ctx, `
-		SELECT DISTINCT u.id, u.name, u.email
-		FROM users u
-		JOIN user_interests ui ON u.id = ui.user_id
-		WHERE u.id != $1
-		AND ui.interest_id IN (SELECT interest_id FROM user_interests WHERE user_id = $1)
-		LIMIT $2
-	`, userID, limit)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var users []Userinfo
-	for rows.Next() {
-		var u Userinfo
-		// Note: Password is not selected, so it will be empty (safe)
-		if err := rows.Scan(&u.ID, &u.Name, &u.Email); err != nil {
-			return nil, err
-		}
-		users = append(users, u)
-	}
-	return users, nil
-}
-
-// SeedInterests populates the interests table with predefined categories and options if not already present.
-func SeedInterests(ctx context.Context) error {
-	if config.DB == nil {
-		return fmt.Errorf("database not initialized")
-	}
-
-	// Ensure tables exist
-	_, err := config.DB.Exec(ctx, `
-		CREATE TABLE IF NOT EXISTS interests (
-			id SERIAL PRIMARY KEY,
-			name TEXT NOT NULL,
-			category TEXT NOT NULL,
-			is_active BOOLEAN DEFAULT TRUE
-		);
-		CREATE TABLE IF NOT EXISTS user_interests (
-			user_id INT NOT NULL,
-			interest_id INT NOT NULL,
-			PRIMARY KEY (user_id, interest_id)
-		);
-	`)
-	if err != nil {
-		return fmt.Errorf("failed to create interests tables: %w", err)
-	}
-
-	interests := []struct {
-		name     string
-		category string
-	}{ // 🧠 How You’ve Been Feeling
-		{"Anxiety", "🧠 How You’ve Been Feeling"},
-		{"Overthinking", "🧠 How You’ve Been Feeling"},
-		{"Stress", "🧠 How You’ve Been Feeling"},
-		{"Loneliness", "🧠 How You’ve Been Feeling"},
-		{"Emotional exhaustion", "🧠 How You’ve Been Feeling"},
-		{"Calm & clarity", "🧠 How You’ve Been Feeling"},
-		{"Gratitude", "🧠 How You’ve Been Feeling"},
-
-		// 🎯 What You’re Working On
-		{"Self-discipline", "🎯 What You’re Working On"},
-		{"Staying consistent", "🎯 What You’re Working On"},
-		{"Finding motivation", "🎯 What You’re Working On"},
-		{"Breaking a habit", "🎯 What You’re Working On"},
-		{"Improving focus", "🎯 What You’re Working On"},
-		{"Building confidence", "🎯 What You’re Working On"},
-
-		// 🧍 Life Situations
-		{"Student life", "🧍 Life Situations"},
-		{"Career confusion", "🧍 Life Situations"},
-		{"Relationship struggles", "🧍 Life Situations"},
-		{"Family pressure", "🧍 Life Situations"},
-		{"Living alone", "🧍 Life Situations"},
-		{"Feeling stuck", "🧍 Life Situations"},
-
-		// 🌱 Reflection & Meaning
-		{"Self-reflection", "🌱 Reflection & Meaning"},
-		{"Finding purpose", "🌱 Reflection & Meaning"},
-		{"Letting go", "🌱 Reflection & Meaning"},
-		{"Acceptance", "🌱 Reflection & Meaning"},
-		{"Mindfulness", "🌱 Reflection & Meaning"},
-		{"Understanding myself better", "🌱 Reflection & Meaning"},
-
-		// 🌙 Time & Energy States
-		{"Late-night thoughts", "🌙 Time & Energy States"},
-		{"Low-energy days", "🌙 Time & Energy States"},
-		{"Need encouragement", "🌙 Time & Energy States"},
-		{"Quiet reflection", "🌙 Time & Energy States"},
-		{"Morning motivation", "🌙 Time & Energy States"},
-	}
-
-	tx, err := config.DB.Begin(ctx)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback(ctx)
-
-	for _, interest := range interests {
-		// Check if interest already exists
-		var exists bool
-		err := tx.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM interests WHERE name = $1)", interest.name).Scan(&exists)
-		if err != nil {
-			return err
-		}
-		if !exists {
-			_, err = tx.Exec(ctx, "INSERT INTO interests (name, category, is_active) VALUES ($1, $2, true)", interest.name, interest.category)
-			if err != nil {
-				return err
-			}
-		}
-	}
-
-	return tx.Commit(ctx)
-}
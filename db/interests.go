@@ -0,0 +1,576 @@
+package db
+
+import (
+	"Remainwith/config"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Errors returned by the interest catalog functions.
+var (
+	ErrCategoryNotFound = errors.New("category not found")
+	ErrInterestNotFound = errors.New("interest not found")
+	ErrDuplicateName    = errors.New("name already in use")
+	ErrBadOrder         = errors.New("order must list every item exactly once")
+)
+
+// Interest is one option in the interest catalog.
+type Interest struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	CategoryID int    `json:"category_id"`
+	Category   string `json:"category"`
+	Position   int    `json:"position"`
+	Active     bool   `json:"active"`
+}
+
+// Category groups interests for onboarding and the profile page.
+type Category struct {
+	ID          int        `json:"id"`
+	Name        string     `json:"name"`
+	Emoji       string     `json:"emoji"`
+	Description string     `json:"description"`
+	Position    int        `json:"position"`
+	Interests   []Interest `json:"interests"`
+
+	// Weight scales how much sharing an interest from this category counts
+	// towards a match. 1 is neutral.
+	Weight float64 `json:"weight"`
+}
+
+// Bounds for Category.Weight.
+const (
+	MinCategoryWeight = 0.1
+	MaxCategoryWeight = 5.0
+)
+
+// Label is the category name with its emoji in front, as shown in the UI.
+func (c Category) Label() string {
+	if c.Emoji == "" {
+		return c.Name
+	}
+	return c.Emoji + " " + c.Name
+}
+
+// seedCatalog is the catalog a fresh database starts with. It also supplies
+// descriptions and order for categories migrated from the old
+// emoji-prefixed strings.
+var seedCatalog = []struct {
+	emoji       string
+	name        string
+	description string
+	weight      float64
+	interests   []string
+}{
+	{"🧠", "How You’ve Been Feeling", "The moods and states that have been around lately.", 1.5, []string{
+		"Anxiety", "Overthinking", "Stress", "Loneliness", "Emotional exhaustion", "Calm & clarity", "Gratitude",
+	}},
+	{"🎯", "What You’re Working On", "Habits and goals you are putting effort into.", 1, []string{
+		"Self-discipline", "Staying consistent", "Finding motivation", "Breaking a habit", "Improving focus", "Building confidence",
+	}},
+	{"🧍", "Life Situations", "What is going on around you.", 1.25, []string{
+		"Student life", "Career confusion", "Relationship struggles", "Family pressure", "Living alone", "Feeling stuck",
+	}},
+	{"🌱", "Reflection & Meaning", "The bigger questions you are sitting with.", 1, []string{
+		"Self-reflection", "Finding purpose", "Letting go", "Acceptance", "Mindfulness", "Understanding myself better",
+	}},
+	{"🌙", "Time & Energy States", "When you tend to show up and how much you have in the tank.", 0.75, []string{
+		"Late-night thoughts", "Low-energy days", "Need encouragement", "Quiet reflection", "Morning motivation",
+	}},
+}
+
+// SeedInterests creates the catalog tables, moves interests from the old
+// category strings onto the categories table and, on an empty database,
+// inserts the starter catalog.
+func SeedInterests(ctx context.Context) error {
+	if config.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	_, err := config.DB.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS categories (
+			id SERIAL PRIMARY KEY,
+			name TEXT NOT NULL UNIQUE,
+			emoji TEXT NOT NULL DEFAULT '',
+			description TEXT NOT NULL DEFAULT '',
+			position INT NOT NULL DEFAULT 0
+		);
+		CREATE TABLE IF NOT EXISTS interests (
+			id SERIAL PRIMARY KEY,
+			name TEXT NOT NULL,
+			category_id INT REFERENCES categories(id),
+			position INT NOT NULL DEFAULT 0,
+			is_active BOOLEAN DEFAULT TRUE
+		);
+		CREATE TABLE IF NOT EXISTS user_interests (
+			user_id INT NOT NULL,
+			interest_id INT NOT NULL,
+			PRIMARY KEY (user_id, interest_id)
+		);
+		ALTER TABLE interests ADD COLUMN IF NOT EXISTS category_id INT REFERENCES categories(id);
+		ALTER TABLE interests ADD COLUMN IF NOT EXISTS position INT NOT NULL DEFAULT 0;
+		ALTER TABLE categories ADD COLUMN IF NOT EXISTS weight REAL NOT NULL DEFAULT 1;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create interests tables: %w", err)
+	}
+
+	tx, err := config.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := migrateCategoryStrings(ctx, tx); err != nil {
+		return fmt.Errorf("failed to migrate interest categories: %w", err)
+	}
+
+	var empty bool
+	if err := tx.QueryRow(ctx, "SELECT NOT EXISTS(SELECT 1 FROM categories)").Scan(&empty); err != nil {
+		return err
+	}
+	if empty {
+		for pos, c := range seedCatalog {
+			var categoryID int
+			err := tx.QueryRow(ctx,
+				`INSERT INTO categories (name, emoji, description, position, weight) VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+				c.name, c.emoji, c.description, pos, c.weight).Scan(&categoryID)
+			if err != nil {
+				return err
+			}
+			for i, name := range c.interests {
+				_, err := tx.Exec(ctx,
+					`INSERT INTO interests (name, category_id, position, is_active) VALUES ($1, $2, $3, true)`,
+					name, categoryID, i)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// migrateCategoryStrings replaces the legacy interests.category column,
+// which held labels like "🧠 How You’ve Been Feeling", with rows in
+// categories. It does nothing once the column is gone.
+func migrateCategoryStrings(ctx context.Context, tx pgx.Tx) error {
+	var legacy bool
+	err := tx.QueryRow(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM information_schema.columns
+			WHERE table_name = 'interests' AND column_name = 'category'
+		)`).Scan(&legacy)
+	if err != nil || !legacy {
+		return err
+	}
+
+	rows, err := tx.Query(ctx, `SELECT DISTINCT category FROM interests WHERE category_id IS NULL`)
+	if err != nil {
+		return err
+	}
+	labels, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return err
+	}
+
+	for _, label := range labels {
+		emoji, name := SplitCategoryLabel(label)
+		description, pos, weight := "", len(seedCatalog), 1.0
+		for i, c := range seedCatalog {
+			if c.name == name {
+				description, pos, weight = c.description, i, c.weight
+				break
+			}
+		}
+
+		var categoryID int
+		err := tx.QueryRow(ctx, `
+			INSERT INTO categories (name, emoji, description, position, weight) VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+			RETURNING id`,
+			name, emoji, description, pos, weight).Scan(&categoryID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx,
+			`UPDATE interests SET category_id = $1, position = id WHERE category = $2 AND category_id IS NULL`,
+			categoryID, label)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(ctx, `ALTER TABLE interests DROP COLUMN category`)
+	return err
+}
+
+// SplitCategoryLabel separates a leading emoji from a category label, so
+// "🌱 Reflection & Meaning" becomes "🌱" and "Reflection & Meaning".
+func SplitCategoryLabel(label string) (emoji, name string) {
+	label = strings.TrimSpace(label)
+	first, rest, found := strings.Cut(label, " ")
+	if !found || strings.IndexFunc(first, func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}) >= 0 {
+		return "", label
+	}
+	return first, strings.TrimSpace(rest)
+}
+
+const interestColumns = `i.id, i.name, i.category_id, c.name, i.position, COALESCE(i.is_active, true)`
+
+func scanInterests(rows pgx.Rows) ([]Interest, error) {
+	defer rows.Close()
+	var interests []Interest
+	for rows.Next() {
+		var i Interest
+		if err := rows.Scan(&i.ID, &i.Name, &i.CategoryID, &i.Category, &i.Position, &i.Active); err != nil {
+			return nil, err
+		}
+		interests = append(interests, i)
+	}
+	return interests, rows.Err()
+}
+
+// GetAllInterests retrieves all active interests in display order.
+func GetAllInterests(ctx context.Context) ([]Interest, error) {
+	if config.DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	rows, err := config.DB.Query(ctx, `
+		SELECT `+interestColumns+`
+		FROM interests i
+		JOIN categories c ON c.id = i.category_id
+		WHERE COALESCE(i.is_active, true)
+		ORDER BY c.position, c.id, i.position, i.id`)
+	if err != nil {
+		return nil, err
+	}
+	return scanInterests(rows)
+}
+
+// GetInterest returns one interest, active or not.
+func GetInterest(ctx context.Context, id int) (*Interest, error) {
+	if config.DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	rows, err := config.DB.Query(ctx, `
+		SELECT `+interestColumns+`
+		FROM interests i
+		JOIN categories c ON c.id = i.category_id
+		WHERE i.id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	interests, err := scanInterests(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(interests) == 0 {
+		return nil, ErrInterestNotFound
+	}
+	return &interests[0], nil
+}
+
+// GetCategory returns one category without its interests.
+func GetCategory(ctx context.Context, id int) (*Category, error) {
+	if config.DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	var c Category
+	err := config.DB.QueryRow(ctx,
+		`SELECT id, name, emoji, description, position, weight FROM categories WHERE id = $1`, id).
+		Scan(&c.ID, &c.Name, &c.Emoji, &c.Description, &c.Position, &c.Weight)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrCategoryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// GetInterestCatalog returns categories in display order with their
+// interests. Unless includeInactive is set, hidden interests are left out
+// and so are categories left with nothing to show.
+func (p *Postgres) GetInterestCatalog(ctx context.Context, includeInactive bool) ([]Category, error) {
+	if p.pool == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	rows, err := p.pool.Query(ctx,
+		`SELECT id, name, emoji, description, position, weight FROM categories ORDER BY position, id`)
+	if err != nil {
+		return nil, err
+	}
+	categories := []Category{}
+	index := make(map[int]int)
+	for rows.Next() {
+		var c Category
+		if err := rows.Scan(&c.ID, &c.Name, &c.Emoji, &c.Description, &c.Position, &c.Weight); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		c.Interests = []Interest{}
+		index[c.ID] = len(categories)
+		categories = append(categories, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = p.pool.Query(ctx, `
+		SELECT `+interestColumns+`
+		FROM interests i
+		JOIN categories c ON c.id = i.category_id
+		WHERE $1 OR COALESCE(i.is_active, true)
+		ORDER BY i.position, i.id`, includeInactive)
+	if err != nil {
+		return nil, err
+	}
+	interests, err := scanInterests(rows)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range interests {
+		c := &categories[index[i.CategoryID]]
+		c.Interests = append(c.Interests, i)
+	}
+
+	if includeInactive {
+		return categories, nil
+	}
+	shown := categories[:0]
+	for _, c := range categories {
+		if len(c.Interests) > 0 {
+			shown = append(shown, c)
+		}
+	}
+	return shown, nil
+}
+
+// isUniqueViolation reports whether err is a Postgres unique constraint error.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	// 23505 = unique_violation
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// CreateCategory adds a category after the existing ones.
+func CreateCategory(ctx context.Context, name, emoji, description string) (int, error) {
+	if config.DB == nil {
+		return 0, fmt.Errorf("database not initialized")
+	}
+
+	var id int
+	err := config.DB.QueryRow(ctx, `
+		INSERT INTO categories (name, emoji, description, position)
+		VALUES ($1, $2, $3, (SELECT COALESCE(MAX(position) + 1, 0) FROM categories))
+		RETURNING id`,
+		name, emoji, description).Scan(&id)
+	if isUniqueViolation(err) {
+		return 0, ErrDuplicateName
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to create category: %w", err)
+	}
+	return id, nil
+}
+
+// UpdateCategory renames a category and replaces its emoji, description
+// and match weight.
+func UpdateCategory(ctx context.Context, id int, name, emoji, description string, weight float64) error {
+	if config.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	if weight < MinCategoryWeight || weight > MaxCategoryWeight {
+		return fmt.Errorf("weight must be between %g and %g", MinCategoryWeight, MaxCategoryWeight)
+	}
+
+	tag, err := config.DB.Exec(ctx,
+		`UPDATE categories SET name = $1, emoji = $2, description = $3, weight = $4 WHERE id = $5`,
+		name, emoji, description, weight, id)
+	if isUniqueViolation(err) {
+		return ErrDuplicateName
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update category: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrCategoryNotFound
+	}
+	return nil
+}
+
+// ReorderCategories puts categories in the order of ids, which must name
+// every category exactly once.
+func ReorderCategories(ctx context.Context, ids []int) error {
+	if config.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	return reorder(ctx, ids,
+		`SELECT id FROM categories`,
+		`UPDATE categories SET position = $1 WHERE id = $2`)
+}
+
+// ReorderInterests puts the interests of a category in the order of ids,
+// which must name every interest in it exactly once.
+func ReorderInterests(ctx context.Context, categoryID int, ids []int) error {
+	if config.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	return reorder(ctx, ids,
+		`SELECT id FROM interests WHERE category_id = $1`,
+		`UPDATE interests SET position = $1 WHERE id = $2`, categoryID)
+}
+
+// reorder checks ids against the rows selected by list and writes each
+// one's index with update.
+func reorder(ctx context.Context, ids []int, list, update string, args ...any) error {
+	tx, err := config.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, list, args...)
+	if err != nil {
+		return err
+	}
+	existing, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return err
+	}
+	if !sameIDs(existing, ids) {
+		return ErrBadOrder
+	}
+
+	for pos, id := range ids {
+		if _, err := tx.Exec(ctx, update, pos, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// sameIDs reports whether got is a permutation of want.
+func sameIDs(want, got []int) bool {
+	if len(want) != len(got) {
+		return false
+	}
+	seen := make(map[int]bool, len(want))
+	for _, id := range want {
+		seen[id] = true
+	}
+	for _, id := range got {
+		if !seen[id] {
+			return false
+		}
+		delete(seen, id)
+	}
+	return len(seen) == 0
+}
+
+// AddInterest adds a new, active interest at the end of a category.
+func AddInterest(ctx context.Context, name string, categoryID int) (int, error) {
+	if config.DB == nil {
+		return 0, fmt.Errorf("database not initialized")
+	}
+
+	var exists bool
+	if err := config.DB.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM interests WHERE name = $1)`, name).Scan(&exists); err != nil {
+		return 0, err
+	}
+	if exists {
+		return 0, ErrDuplicateName
+	}
+
+	var id int
+	err := config.DB.QueryRow(ctx, `
+		INSERT INTO interests (name, category_id, position, is_active)
+		SELECT $1, c.id, (SELECT COALESCE(MAX(position) + 1, 0) FROM interests WHERE category_id = c.id), true
+		FROM categories c WHERE c.id = $2
+		RETURNING id`,
+		name, categoryID).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrCategoryNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to add interest: %w", err)
+	}
+	return id, nil
+}
+
+// UpdateInterest renames an interest and moves it to categoryID. A moved
+// interest goes to the end of its new category. Users who chose it keep it.
+func UpdateInterest(ctx context.Context, id int, name string, categoryID int) error {
+	if config.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	var taken bool
+	if err := config.DB.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM interests WHERE name = $1 AND id <> $2)`, name, id).Scan(&taken); err != nil {
+		return err
+	}
+	if taken {
+		return ErrDuplicateName
+	}
+
+	var categoryExists bool
+	if err := config.DB.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM categories WHERE id = $1)`, categoryID).Scan(&categoryExists); err != nil {
+		return err
+	}
+	if !categoryExists {
+		return ErrCategoryNotFound
+	}
+
+	tag, err := config.DB.Exec(ctx, `
+		UPDATE interests SET
+			name = $1,
+			position = CASE WHEN category_id = $2 THEN position
+				ELSE (SELECT COALESCE(MAX(position) + 1, 0) FROM interests WHERE category_id = $2) END,
+			category_id = $2
+		WHERE id = $3`,
+		name, categoryID, id)
+	if err != nil {
+		return fmt.Errorf("failed to update interest: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrInterestNotFound
+	}
+	return nil
+}
+
+// SetInterestActive shows or hides an interest in onboarding. Users who
+// already chose it keep it.
+func SetInterestActive(ctx context.Context, id int, active bool) error {
+	if config.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	tag, err := config.DB.Exec(ctx, `UPDATE interests SET is_active = $1 WHERE id = $2`, active, id)
+	if err != nil {
+		return fmt.Errorf("failed to update interest: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrInterestNotFound
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package db
+
+import "testing"
+
+func TestSplitCategoryLabel(t *testing.T) {
+	tests := []struct {
+		label, emoji, name string
+	}{
+		{"🧠 How You’ve Been Feeling", "🧠", "How You’ve Been Feeling"},
+		{"🧍 Life Situations", "🧍", "Life Situations"},
+		{"  🌙  Time & Energy States ", "🌙", "Time & Energy States"},
+		{"Life Situations", "", "Life Situations"},
+		{"2024 Goals", "", "2024 Goals"},
+		{"Hobbies", "", "Hobbies"},
+	}
+	for _, tt := range tests {
+		emoji, name := SplitCategoryLabel(tt.label)
+		if emoji != tt.emoji || name != tt.name {
+			t.Errorf("SplitCategoryLabel(%q) = %q, %q; want %q, %q", tt.label, emoji, name, tt.emoji, tt.name)
+		}
+	}
+}
+
+func TestSameIDs(t *testing.T) {
+	if !sameIDs([]int{1, 2, 3}, []int{3, 1, 2}) {
+		t.Error("permutation rejected")
+	}
+	for _, got := range [][]int{{1, 2}, {1, 2, 2}, {1, 2, 4}, {1, 2, 3, 3}} {
+		if sameIDs([]int{1, 2, 3}, got) {
+			t.Errorf("sameIDs accepted %v", got)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package db
+
+import (
+	"Remainwith/config"
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// MatchCandidate is another user who shares at least one interest, with
+// what the matchmaker needs to score them. It carries no contact details.
+type MatchCandidate struct {
+	UserID int
+
+	// Name is the public display name
+	Name        string
+	InterestIDs []int
+	LastActive  time.Time
+}
+
+// GetUserInterestIDs returns the active interests a user has chosen.
+func (p *Postgres) GetUserInterestIDs(ctx context.Context, userID int) ([]int, error) {
+	if p.pool == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	rows, err := p.pool.Query(ctx, `
+		SELECT ui.interest_id
+		FROM user_interests ui
+		JOIN interests i ON i.id = ui.interest_id
+		WHERE ui.user_id = $1 AND COALESCE(i.is_active, true)`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetMatchCandidates returns up to limit users sharing an active interest
+// with userID, most shared interests first. Interests a candidate hid from
+// their profile are not matched on. It leaves out userID's blocks
+// in either direction, anyone they have already exchanged direct messages
+// with, suspended accounts and users who are not discoverable.
+//
+// LastActive is the latest of sign-up, last message sent and last presence
+// session.
+func GetMatchCandidates(ctx context.Context, userID int, limit int) ([]MatchCandidate, error) {
+	if config.DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	rows, err := config.DB.Query(ctx, `
+		WITH mine AS (
+			SELECT ui.interest_id
+			FROM user_interests ui
+			JOIN interests i ON i.id = ui.interest_id
+			WHERE ui.user_id = $1 AND COALESCE(i.is_active, true)
+		), theirs AS (
+			SELECT ui.user_id, ui.interest_id
+			FROM user_interests ui
+			JOIN interests i ON i.id = ui.interest_id
+			LEFT JOIN profiles hp ON hp.user_id = ui.user_id
+			WHERE ui.user_id <> $1 AND COALESCE(i.is_active, true)
+			AND NOT (ui.interest_id = ANY(COALESCE(hp.hidden_interests, '{}')))
+		)
+		SELECT u.id, u.name,
+			ARRAY_AGG(t.interest_id ORDER BY t.interest_id),
+			GREATEST(
+				u.created_at,
+				(SELECT MAX(m.created_at) FROM messages m WHERE m.sender_id = u.id::text),
+				(SELECT MAX(p.started_at + p.duration_seconds * INTERVAL '1 second')
+				 FROM presence_sessions p WHERE p.user_id = u.id)
+			)
+		FROM users u
+		JOIN theirs t ON t.user_id = u.id
+		LEFT JOIN profiles pr ON pr.user_id = u.id
+		WHERE (u.suspended_until IS NULL OR u.suspended_until <= NOW())
+		AND COALESCE(pr.discoverable, true)
+		AND NOT EXISTS (
+			SELECT 1 FROM blocks b
+			WHERE (b.blocker_id = $1 AND b.blocked_id = u.id)
+			   OR (b.blocker_id = u.id AND b.blocked_id = $1)
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM messages m
+			WHERE m.receiver_id <> ''
+			AND ((m.sender_id = $2 AND m.receiver_id = u.id::text)
+			  OR (m.sender_id = u.id::text AND m.receiver_id = $2))
+		)
+		GROUP BY u.id, u.name, u.created_at
+		HAVING COUNT(*) FILTER (WHERE t.interest_id IN (SELECT interest_id FROM mine)) > 0
+		ORDER BY COUNT(*) FILTER (WHERE t.interest_id IN (SELECT interest_id FROM mine)) DESC, u.id
+		LIMIT $3
+	`, userID, strconv.Itoa(userID), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []MatchCandidate
+	for rows.Next() {
+		var c MatchCandidate
+		var lastActive *time.Time
+		if err := rows.Scan(&c.UserID, &c.Name, &c.InterestIDs, &lastActive); err != nil {
+			return nil, err
+		}
+		if lastActive != nil {
+			c.LastActive = *lastActive
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, rows.Err()
+}
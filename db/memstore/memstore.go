@@ -0,0 +1,364 @@
+// Package memstore keeps accounts, journals and interests in memory, for
+// tests of handlers that take db stores. It follows the Postgres stores'
+// rules and errors closely enough for handlers not to notice.
+package memstore
+
+import (
+	"Remainwith/db"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Store implements db.UserStore, db.JournalStore and db.InterestStore.
+// The zero value is not usable; call New.
+type Store struct {
+	mu sync.Mutex
+
+	users        map[int]*db.Userinfo
+	bios         map[int]string
+	emailChanges map[string]emailChange
+	journals     map[int]*db.Journal
+	catalog      []db.Category
+	picks        map[int][]int // user ID to interest IDs
+	lastID       int
+}
+
+type emailChange struct {
+	userID   int
+	newEmail string
+	expires  time.Time
+}
+
+var (
+	_ db.UserStore     = (*Store)(nil)
+	_ db.JournalStore  = (*Store)(nil)
+	_ db.InterestStore = (*Store)(nil)
+)
+
+// New returns an empty store.
+func New() *Store {
+	return &Store{
+		users:        make(map[int]*db.Userinfo),
+		bios:         make(map[int]string),
+		emailChanges: make(map[string]emailChange),
+		journals:     make(map[int]*db.Journal),
+		picks:        make(map[int][]int),
+	}
+}
+
+func (s *Store) nextID() int {
+	s.lastID++
+	return s.lastID
+}
+
+// --- Users ---
+
+func (s *Store) GetUserByID(ctx context.Context, userID int) (*db.Userinfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[userID]
+	if !ok {
+		return nil, fmt.Errorf("user not found")
+	}
+	clone := *u
+	return &clone, nil
+}
+
+func (s *Store) GetUserByEmail(ctx context.Context, email string) (*db.Userinfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := s.userByEmail(email)
+	if u == nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	clone := *u
+	return &clone, nil
+}
+
+func (s *Store) userByEmail(email string) *db.Userinfo {
+	for _, u := range s.users {
+		if u.Email == email {
+			return u
+		}
+	}
+	return nil
+}
+
+func (s *Store) CheckUser(ctx context.Context, email string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.userByEmail(email) != nil, nil
+}
+
+func (s *Store) NewUser(ctx context.Context, name, email, password string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.userByEmail(email) != nil {
+		return fmt.Errorf("email already exists")
+	}
+	id := s.nextID()
+	s.users[id] = &db.Userinfo{ID: id, Name: name, Email: email, Password: password, Role: db.RoleUser}
+	return nil
+}
+
+func (s *Store) UpdateUserName(ctx context.Context, userID int, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[userID]
+	if !ok {
+		return fmt.Errorf("user not found")
+	}
+	u.Name = name
+	return nil
+}
+
+func (s *Store) SetBio(ctx context.Context, userID int, bio string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bios[userID] = bio
+	return nil
+}
+
+// Bio returns what SetBio last stored for userID.
+func (s *Store) Bio(userID int) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bios[userID]
+}
+
+// SetSuspended suspends userID until the given time, as the admin
+// console would.
+func (s *Store) SetSuspended(userID int, until time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if u, ok := s.users[userID]; ok {
+		u.SuspendedUntil = &until
+	}
+}
+
+func (s *Store) CreateEmailChange(ctx context.Context, userID int, newEmail string, ttl time.Duration) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.userByEmail(newEmail) != nil {
+		return "", db.ErrEmailTaken
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(raw)
+
+	for t, c := range s.emailChanges {
+		if c.userID == userID {
+			delete(s.emailChanges, t)
+		}
+	}
+	s.emailChanges[token] = emailChange{userID: userID, newEmail: newEmail, expires: time.Now().Add(ttl)}
+	return token, nil
+}
+
+func (s *Store) ConfirmEmailChange(ctx context.Context, token string) (*db.Userinfo, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.emailChanges[token]
+	delete(s.emailChanges, token)
+	if !ok || time.Now().After(c.expires) {
+		return nil, "", db.ErrEmailChangeInvalid
+	}
+	u, ok := s.users[c.userID]
+	if !ok {
+		return nil, "", db.ErrEmailChangeInvalid
+	}
+	if other := s.userByEmail(c.newEmail); other != nil && other.ID != u.ID {
+		return nil, "", db.ErrEmailTaken
+	}
+
+	oldEmail := u.Email
+	u.Email = c.newEmail
+	clone := *u
+	return &clone, oldEmail, nil
+}
+
+// --- Journals ---
+
+func (s *Store) GetJournal(ctx context.Context, id, userID int) (*db.Journal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.journals[id]
+	if !ok || j.UserID != userID {
+		return nil, db.ErrJournalNotFound
+	}
+	clone := *j
+	return &clone, nil
+}
+
+func (s *Store) GetJournalsByUserID(ctx context.Context, userID int) ([]db.Journal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var journals []db.Journal
+	for _, j := range s.journals {
+		if j.UserID == userID {
+			journals = append(journals, *j)
+		}
+	}
+	// Newest first; IDs break ties between entries written in the same instant
+	sort.Slice(journals, func(a, b int) bool {
+		if !journals[a].CreatedAt.Equal(journals[b].CreatedAt) {
+			return journals[a].CreatedAt.After(journals[b].CreatedAt)
+		}
+		return journals[a].ID > journals[b].ID
+	})
+	return journals, nil
+}
+
+func (s *Store) NewJournal(ctx context.Context, userID int, title, description string) (int, error) {
+	if title == "" || description == "" {
+		return 0, fmt.Errorf("title and description are required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.nextID()
+	s.journals[id] = &db.Journal{ID: id, UserID: userID, Title: title, Desc: description, CreatedAt: time.Now()}
+	return id, nil
+}
+
+func (s *Store) UpdateJournal(ctx context.Context, id, userID int, title, description string) error {
+	if title == "" || description == "" {
+		return fmt.Errorf("title and description are required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.journals[id]
+	if !ok || j.UserID != userID {
+		return db.ErrJournalNotFound
+	}
+	j.Title, j.Desc = title, description
+	return nil
+}
+
+func (s *Store) DeleteJournal(ctx context.Context, id, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.journals[id]
+	if !ok || j.UserID != userID {
+		return db.ErrJournalNotFound
+	}
+	delete(s.journals, id)
+	return nil
+}
+
+// --- Interests ---
+
+// SetCatalog replaces the interest catalog. Categories and their
+// interests are taken to be in display order.
+func (s *Store) SetCatalog(categories []db.Category) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.catalog = s.catalog[:0]
+	for _, c := range categories {
+		c.Interests = slices.Clone(c.Interests)
+		for i := range c.Interests {
+			c.Interests[i].CategoryID = c.ID
+			c.Interests[i].Category = c.Name
+		}
+		s.catalog = append(s.catalog, c)
+	}
+}
+
+func (s *Store) interest(id int) (db.Interest, bool) {
+	for _, c := range s.catalog {
+		for _, i := range c.Interests {
+			if i.ID == id {
+				return i, true
+			}
+		}
+	}
+	return db.Interest{}, false
+}
+
+func (s *Store) GetInterestCatalog(ctx context.Context, includeInactive bool) ([]db.Category, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	categories := []db.Category{}
+	for _, c := range s.catalog {
+		interests := []db.Interest{}
+		for _, i := range c.Interests {
+			if includeInactive || i.Active {
+				interests = append(interests, i)
+			}
+		}
+		if !includeInactive && len(interests) == 0 {
+			continue
+		}
+		c.Interests = interests
+		categories = append(categories, c)
+	}
+	return categories, nil
+}
+
+func (s *Store) GetUserInterests(ctx context.Context, userID int) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var names []string
+	for _, id := range s.picks[userID] {
+		if i, ok := s.interest(id); ok {
+			names = append(names, i.Name)
+		}
+	}
+	return names, nil
+}
+
+func (s *Store) GetUserInterestIDs(ctx context.Context, userID int) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ids []int
+	for _, id := range s.picks[userID] {
+		if i, ok := s.interest(id); ok && i.Active {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (s *Store) SaveUserInterests(ctx context.Context, userID int, interestIDs []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range interestIDs {
+		if _, ok := s.interest(id); !ok {
+			return fmt.Errorf("interest %d does not exist", id)
+		}
+	}
+	s.picks[userID] = slices.Clone(interestIDs)
+	return nil
+}
+
+func (s *Store) SaveUserInterestsByNames(ctx context.Context, userID int, interestNames []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Unknown names are skipped, as in Postgres
+	var ids []int
+	for _, name := range interestNames {
+		for _, c := range s.catalog {
+			for _, i := range c.Interests {
+				if i.Name == name {
+					ids = append(ids, i.ID)
+				}
+			}
+		}
+	}
+	s.picks[userID] = ids
+	return nil
+}
+
+func (s *Store) ShouldShowOnboarding(ctx context.Context, userID int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.picks[userID]) == 0, nil
+}
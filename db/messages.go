@@ -0,0 +1,112 @@
+package db
+
+import (
+	"Remainwith/config"
+	"Remainwith/internal/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Message delivery states, in the order a direct message moves through them.
+const (
+	MessageSent      = "sent"
+	MessageDelivered = "delivered"
+	MessageRead      = "read"
+)
+
+// InitMessages creates the messages table if it does not exist.
+func InitMessages(ctx context.Context) error {
+	if config.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	_, err := config.DB.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS messages (
+			id SERIAL PRIMARY KEY,
+			client_id TEXT NOT NULL,
+			sender_id TEXT NOT NULL,
+			receiver_id TEXT NOT NULL DEFAULT '',
+			content TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'sent',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			UNIQUE (sender_id, client_id)
+		);
+		ALTER TABLE messages ADD COLUMN IF NOT EXISTS room TEXT NOT NULL DEFAULT '';
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create messages table: %w", err)
+	}
+	return nil
+}
+
+// SaveMessage persists msg and fills in its ID, Status and CreatedAt.
+// CreatedAt comes from the database clock, which room visits also use.
+// A retry carrying a ClientID the sender already used does not insert a new
+// row; the stored message is loaded into msg instead and duplicate is true.
+func SaveMessage(ctx context.Context, msg *models.Message) (duplicate bool, err error) {
+	if config.DB == nil {
+		return false, fmt.Errorf("database not initialized")
+	}
+
+	var id int
+	err = config.DB.QueryRow(
+		ctx,
+		`INSERT INTO messages (client_id, room, sender_id, receiver_id, content, status)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 ON CONFLICT (sender_id, client_id) DO NOTHING
+		 RETURNING id, created_at`,
+		msg.ClientID, msg.Room, msg.SenderID, msg.ReceiverID, msg.Content, MessageSent,
+	).Scan(&id, &msg.CreatedAt)
+	if err == nil {
+		msg.ID = uint(id)
+		msg.Status = MessageSent
+		return false, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return false, fmt.Errorf("failed to insert message: %w", err)
+	}
+
+	err = config.DB.QueryRow(
+		ctx,
+		`SELECT id, room, receiver_id, content, status, created_at
+		 FROM messages
+		 WHERE sender_id = $1 AND client_id = $2`,
+		msg.SenderID, msg.ClientID,
+	).Scan(&id, &msg.Room, &msg.ReceiverID, &msg.Content, &msg.Status, &msg.CreatedAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to load duplicate message: %w", err)
+	}
+	msg.ID = uint(id)
+	return true, nil
+}
+
+// UpdateMessageStatus advances a direct message to status on behalf of its
+// receiver. Statuses never move backwards, so a late "delivered" cannot
+// overwrite "read". It returns the updated message, or nil if nothing changed.
+func UpdateMessageStatus(ctx context.Context, id uint, receiverID, status string) (*models.Message, error) {
+	if config.DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	msg := &models.Message{}
+	var msgID int
+	err := config.DB.QueryRow(
+		ctx,
+		`UPDATE messages SET status = $1
+		 WHERE id = $2 AND receiver_id = $3
+		 AND (status = 'sent' OR (status = 'delivered' AND $1 = 'read'))
+		 RETURNING id, client_id, room, sender_id, receiver_id, status, created_at`,
+		status, id, receiverID,
+	).Scan(&msgID, &msg.ClientID, &msg.Room, &msg.SenderID, &msg.ReceiverID, &msg.Status, &msg.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to update message status: %w", err)
+	}
+	msg.ID = uint(msgID)
+	return msg, nil
+}
@@ -0,0 +1,30 @@
+package db
+
+import "context"
+
+// migrations create and update the schema, in order: later steps add
+// columns and references to tables created by earlier ones.
+var migrations = []func(context.Context) error{
+	InitUsers,
+	SeedInterests,
+	InitMessages,
+	InitPresence,
+	InitCampfires,
+	InitModeration,
+	InitSafetyPlans,
+	InitProfiles,
+	InitEmailChanges,
+	InitAdmin,
+}
+
+// Migrate brings the database behind config.DB up to the current schema.
+// Every step is safe to repeat, so it runs on each startup. It stops at
+// the first step that fails.
+func Migrate(ctx context.Context) error {
+	for _, step := range migrations {
+		if err := step(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+package db
+
+import (
+	"Remainwith/config"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrMessageNotFound is returned by NewReport when the message does not
+// exist or the reporter could never have seen it.
+var ErrMessageNotFound = errors.New("message not found")
+
+// Report reasons accepted from users.
+var ReportReasons = map[string]string{
+	"spam":       "Spam or advertising",
+	"harassment": "Harassment or bullying",
+	"hate":       "Hateful or abusive language",
+	"self_harm":  "Someone may be at risk",
+	"other":      "Something else",
+}
+
+// Report is a user's complaint about a chat message.
+type Report struct {
+	ID             int       `json:"id"`
+	ReporterID     int       `json:"reporter_id"`
+	MessageID      int       `json:"message_id"`
+	ReportedUserID string    `json:"reported_user_id"`
+	Reason         string    `json:"reason"`
+	Details        string    `json:"details,omitempty"`
+	Content        string    `json:"content,omitempty"` // snapshot of the reported message
+	Status         string    `json:"status"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// InitModeration creates the blocks, reports and room visits tables if
+// they do not exist.
+func InitModeration(ctx context.Context) error {
+	if config.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	_, err := config.DB.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS blocks (
+			blocker_id INT NOT NULL,
+			blocked_id INT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (blocker_id, blocked_id)
+		);
+		CREATE INDEX IF NOT EXISTS blocks_blocked_idx ON blocks (blocked_id);
+		CREATE TABLE IF NOT EXISTS reports (
+			id SERIAL PRIMARY KEY,
+			reporter_id INT NOT NULL,
+			message_id INT NOT NULL,
+			reported_user_id TEXT NOT NULL,
+			reason TEXT NOT NULL,
+			details TEXT NOT NULL DEFAULT '',
+			content TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL DEFAULT 'open',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			UNIQUE (reporter_id, message_id)
+		);
+		CREATE TABLE IF NOT EXISTS room_visits (
+			id SERIAL PRIMARY KEY,
+			room TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			joined_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			left_at TIMESTAMPTZ
+		);
+		ALTER TABLE room_visits ADD COLUMN IF NOT EXISTS instance TEXT NOT NULL DEFAULT '';
+		CREATE INDEX IF NOT EXISTS room_visits_room_user_idx ON room_visits (room, user_id);
+		CREATE INDEX IF NOT EXISTS room_visits_left_at_idx ON room_visits (left_at);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create moderation tables: %w", err)
+	}
+	return nil
+}
+
+// BlockUser records that blocker no longer wants contact with blocked.
+func BlockUser(ctx context.Context, blockerID, blockedID int) error {
+	if config.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	if blockerID == blockedID {
+		return fmt.Errorf("you cannot block yourself")
+	}
+
+	_, err := config.DB.Exec(ctx,
+		`INSERT INTO blocks (blocker_id, blocked_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+		blockerID, blockedID)
+	if err != nil {
+		return fmt.Errorf("failed to block user: %w", err)
+	}
+	return nil
+}
+
+// UnblockUser removes a block.
+func UnblockUser(ctx context.Context, blockerID, blockedID int) error {
+	if config.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	_, err := config.DB.Exec(ctx,
+		`DELETE FROM blocks WHERE blocker_id = $1 AND blocked_id = $2`, blockerID, blockedID)
+	if err != nil {
+		return fmt.Errorf("failed to unblock user: %w", err)
+	}
+	return nil
+}
+
+// GetBlockedUsers returns the users blocked by userID.
+func GetBlockedUsers(ctx context.Context, userID int) ([]Userinfo, error) {
+	if config.DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	rows, err := config.DB.Query(ctx,
+		`SELECT u.id, u.name
+		 FROM blocks b JOIN users u ON u.id = b.blocked_id
+		 WHERE b.blocker_id = $1
+		 ORDER BY b.created_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []Userinfo
+	for rows.Next() {
+		var u Userinfo
+		if err := rows.Scan(&u.ID, &u.Name); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// GetBlockRelations returns every user that userID has blocked or been
+// blocked by. Either direction stops all contact between the two, so the
+// chat hub treats them the same. IDs are returned as hub user IDs.
+func GetBlockRelations(ctx context.Context, userID string) ([]string, error) {
+	if config.DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	id, err := strconv.Atoi(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user id %q", userID)
+	}
+
+	rows, err := config.DB.Query(ctx,
+		`SELECT blocked_id FROM blocks WHERE blocker_id = $1
+		 UNION
+		 SELECT blocker_id FROM blocks WHERE blocked_id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var other int
+		if err := rows.Scan(&other); err != nil {
+			return nil, err
+		}
+		ids = append(ids, strconv.Itoa(other))
+	}
+	return ids, rows.Err()
+}
+
+// NewReport files a report against a stored message. The sender and a
+// snapshot of the content are taken from the message itself so reports
+// stay meaningful even if the message is later removed.
+//
+// Only messages the reporter could have seen can be reported: their own,
+// direct messages to them, and room messages sent while they were in the
+// room, for as long as the visit is kept (RoomVisitRetention). Anything
+// else is ErrMessageNotFound, so message IDs can't be used to pull other
+// people's conversations into the moderation queue.
+func NewReport(ctx context.Context, r *Report) error {
+	if config.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	if _, ok := ReportReasons[r.Reason]; !ok {
+		return fmt.Errorf("unknown report reason")
+	}
+
+	err := config.DB.QueryRow(ctx,
+		`INSERT INTO reports (reporter_id, message_id, reported_user_id, reason, details, content)
+		 SELECT $1, m.id, m.sender_id, $3, $4, m.content
+		 FROM messages m
+		 WHERE m.id = $2 AND (
+			m.sender_id = $5 OR m.receiver_id = $5
+			OR (m.receiver_id = '' AND EXISTS (
+				SELECT 1 FROM room_visits v
+				WHERE v.room = m.room AND v.user_id = $5
+				AND v.joined_at <= m.created_at AND (v.left_at IS NULL OR v.left_at >= m.created_at)
+			))
+		 )
+		 ON CONFLICT (reporter_id, message_id) DO UPDATE SET reason = EXCLUDED.reason, details = EXCLUDED.details
+		 RETURNING id, reported_user_id, content, status, created_at`,
+		r.ReporterID, r.MessageID, r.Reason, r.Details, strconv.Itoa(r.ReporterID),
+	).Scan(&r.ID, &r.ReportedUserID, &r.Content, &r.Status, &r.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrMessageNotFound
+		}
+		return fmt.Errorf("failed to file report: %w", err)
+	}
+	return nil
+}
+
+// RoomVisitRetention is how long ended room visits are kept. Room
+// messages older than this can no longer be reported by people who only
+// saw them in the room.
+const RoomVisitRetention = 30 * 24 * time.Hour
+
+// StartRoomVisit records that userID has joined room through the server
+// called instance and returns the visit for EndRoomVisit. Visits decide
+// which room messages a user may report. Visits that ended more than
+// RoomVisitRetention ago are pruned on the way.
+//
+// Visit times come from the database clock, like message times, so the
+// two can be compared.
+func StartRoomVisit(ctx context.Context, instance, room, userID string) (int, error) {
+	if config.DB == nil {
+		return 0, fmt.Errorf("database not initialized")
+	}
+
+	_, err := config.DB.Exec(ctx,
+		`DELETE FROM room_visits WHERE left_at < NOW() - make_interval(secs => $1)`,
+		RoomVisitRetention.Seconds(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune room visits: %w", err)
+	}
+
+	var id int
+	err = config.DB.QueryRow(ctx,
+		`INSERT INTO room_visits (instance, room, user_id) VALUES ($1, $2, $3) RETURNING id`,
+		instance, room, userID,
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record room visit: %w", err)
+	}
+	return id, nil
+}
+
+// EndRoomVisit records that the visit has ended.
+func EndRoomVisit(ctx context.Context, id int) error {
+	if config.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	_, err := config.DB.Exec(ctx, `UPDATE room_visits SET left_at = NOW() WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to end room visit: %w", err)
+	}
+	return nil
+}
+
+// EndInstanceVisits ends the visits instance left open, which happens when
+// it stops without closing its connections. Connections don't survive a
+// restart, so a server calls this for itself on startup; other servers'
+// visits are left alone.
+func EndInstanceVisits(ctx context.Context, instance string) error {
+	if config.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	_, err := config.DB.Exec(ctx,
+		`UPDATE room_visits SET left_at = NOW() WHERE instance = $1 AND left_at IS NULL`, instance)
+	if err != nil {
+		return fmt.Errorf("failed to end room visits: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"Remainwith/config"
+	"context"
+	"fmt"
+	"time"
+)
+
+// PresenceSession is a completed stay in a presence room or a solo session.
+type PresenceSession struct {
+	ID        int
+	UserID    int
+	Mode      string // "solo" or "room"
+	RoomID    string
+	StartedAt time.Time
+	Duration  time.Duration
+}
+
+// InitPresence creates the presence_sessions table if it does not exist.
+func InitPresence(ctx context.Context) error {
+	if config.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	_, err := config.DB.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS presence_sessions (
+			id SERIAL PRIMARY KEY,
+			user_id INT NOT NULL,
+			mode TEXT NOT NULL,
+			room_id TEXT NOT NULL DEFAULT '',
+			started_at TIMESTAMPTZ NOT NULL,
+			duration_seconds INT NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS presence_sessions_user_idx ON presence_sessions (user_id, started_at DESC);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create presence tables: %w", err)
+	}
+	return nil
+}
+
+// LogPresenceSession records a finished session in the user's history.
+func LogPresenceSession(ctx context.Context, s PresenceSession) error {
+	if config.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	_, err := config.DB.Exec(
+		ctx,
+		`INSERT INTO presence_sessions (user_id, mode, room_id, started_at, duration_seconds)
+		 VALUES ($1, $2, $3, $4, $5)`,
+		s.UserID, s.Mode, s.RoomID, s.StartedAt, int(s.Duration.Seconds()),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to log presence session: %w", err)
+	}
+	return nil
+}
+
+// GetPresenceSessions returns a user's most recent sessions, newest first.
+func GetPresenceSessions(ctx context.Context, userID, limit int) ([]PresenceSession, error) {
+	if config.DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	rows, err := config.DB.Query(
+		ctx,
+		`SELECT id, user_id, mode, room_id, started_at, duration_seconds
+		 FROM presence_sessions
+		 WHERE user_id = $1
+		 ORDER BY started_at DESC
+		 LIMIT $2`,
+		userID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query presence sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []PresenceSession
+	for rows.Next() {
+		var s PresenceSession
+		var seconds int
+		if err := rows.Scan(&s.ID, &s.UserID, &s.Mode, &s.RoomID, &s.StartedAt, &seconds); err != nil {
+			return nil, fmt.Errorf("failed to scan presence session: %w", err)
+		}
+		s.Duration = time.Duration(seconds) * time.Second
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
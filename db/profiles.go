@@ -0,0 +1,304 @@
+package db
+
+import (
+	"Remainwith/config"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Who may start a direct message with a user.
+const (
+	DMEveryone = "everyone"
+	DMMatches  = "matches" // people sharing at least one interest
+	DMNobody   = "nobody"
+)
+
+// ValidDMPolicy reports whether policy is one of the DM settings.
+func ValidDMPolicy(policy string) bool {
+	return policy == DMEveryone || policy == DMMatches || policy == DMNobody
+}
+
+// ErrProfileHidden is returned when a profile does not exist or the viewer
+// may not see it. The two are deliberately indistinguishable.
+var ErrProfileHidden = errors.New("profile not found")
+
+// Profile is a user's public face and privacy settings, as they edit it.
+// Others see the user under their account name; there is no separate
+// display name.
+type Profile struct {
+	UserID    int
+	Bio       string
+	AvatarURL string
+
+	// HiddenInterests are interest IDs left off the public profile.
+	HiddenInterests []int
+
+	// Discoverable users appear in suggestions and their profile can be
+	// viewed by anyone.
+	Discoverable bool
+
+	// AllowDMs is one of DMEveryone, DMMatches or DMNobody.
+	AllowDMs string
+
+	// AnonymousCampfires shows an alias instead of the name in campfires.
+	AnonymousCampfires bool
+
+	UpdatedAt time.Time
+}
+
+// PublicProfile is what other users may see. It never carries contact
+// details. DisplayName is the account name.
+type PublicProfile struct {
+	ID          int      `json:"id"`
+	DisplayName string   `json:"display_name"`
+	AvatarURL   string   `json:"avatar_url,omitempty"`
+	Bio         string   `json:"bio,omitempty"`
+	Interests   []string `json:"interests"`
+}
+
+// ChatIdentity is how a user appears in chat. DisplayName is the account
+// name.
+type ChatIdentity struct {
+	DisplayName        string
+	AnonymousCampfires bool
+}
+
+// InitProfiles creates the profiles table if it does not exist. Profiles
+// used to carry a display name shown in place of the account name; where
+// one is left over it becomes the account name, since that is what others
+// knew the user by, and the column is dropped.
+func InitProfiles(ctx context.Context) error {
+	if config.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	_, err := config.DB.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS profiles (
+			user_id INT PRIMARY KEY,
+			bio TEXT NOT NULL DEFAULT '',
+			avatar_url TEXT NOT NULL DEFAULT '',
+			hidden_interests INT[] NOT NULL DEFAULT '{}',
+			discoverable BOOLEAN NOT NULL DEFAULT TRUE,
+			allow_dms TEXT NOT NULL DEFAULT 'everyone',
+			anonymous_campfires BOOLEAN NOT NULL DEFAULT FALSE,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+		DO $$
+		BEGIN
+			IF EXISTS (
+				SELECT 1 FROM information_schema.columns
+				WHERE table_schema = current_schema() AND table_name = 'profiles' AND column_name = 'display_name'
+			) THEN
+				UPDATE users u SET name = pr.display_name
+				FROM profiles pr
+				WHERE pr.user_id = u.id AND pr.display_name <> '';
+				ALTER TABLE profiles DROP COLUMN display_name;
+			END IF;
+		END
+		$$;
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create profiles table: %w", err)
+	}
+	return nil
+}
+
+// GetProfile returns a user's profile settings, or the defaults if they
+// have never saved any.
+func GetProfile(ctx context.Context, userID int) (*Profile, error) {
+	if config.DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	p := &Profile{UserID: userID, Discoverable: true, AllowDMs: DMEveryone, HiddenInterests: []int{}}
+	err := config.DB.QueryRow(ctx, `
+		SELECT bio, avatar_url, hidden_interests, discoverable, allow_dms, anonymous_campfires, updated_at
+		FROM profiles WHERE user_id = $1`, userID).
+		Scan(&p.Bio, &p.AvatarURL, &p.HiddenInterests, &p.Discoverable, &p.AllowDMs, &p.AnonymousCampfires, &p.UpdatedAt)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+	return p, nil
+}
+
+// SaveProfile stores the editable fields of p. The avatar is managed
+// separately.
+func SaveProfile(ctx context.Context, p *Profile) error {
+	if config.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	if !ValidDMPolicy(p.AllowDMs) {
+		return fmt.Errorf("invalid DM setting %q", p.AllowDMs)
+	}
+
+	hidden := p.HiddenInterests
+	if hidden == nil {
+		hidden = []int{}
+	}
+	_, err := config.DB.Exec(ctx, `
+		INSERT INTO profiles (user_id, bio, hidden_interests, discoverable, allow_dms, anonymous_campfires, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (user_id) DO UPDATE SET
+			bio = EXCLUDED.bio,
+			hidden_interests = EXCLUDED.hidden_interests,
+			discoverable = EXCLUDED.discoverable,
+			allow_dms = EXCLUDED.allow_dms,
+			anonymous_campfires = EXCLUDED.anonymous_campfires,
+			updated_at = NOW()`,
+		p.UserID, p.Bio, hidden, p.Discoverable, p.AllowDMs, p.AnonymousCampfires)
+	if err != nil {
+		return fmt.Errorf("failed to save profile: %w", err)
+	}
+	return nil
+}
+
+// SetAvatar sets the URL of a user's avatar. An empty url removes it.
+func SetAvatar(ctx context.Context, userID int, url string) error {
+	if config.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	_, err := config.DB.Exec(ctx, `
+		INSERT INTO profiles (user_id, avatar_url, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (user_id) DO UPDATE SET
+			avatar_url = EXCLUDED.avatar_url,
+			updated_at = NOW()`, userID, url)
+	if err != nil {
+		return fmt.Errorf("failed to set avatar: %w", err)
+	}
+	return nil
+}
+
+// GetPublicProfile returns userID's profile as viewerID may see it. Hidden
+// interests are left out. Others see a profile only when its owner is
+// discoverable or the two have already exchanged direct messages, and
+// never across a block.
+func GetPublicProfile(ctx context.Context, viewerID, userID int) (*PublicProfile, error) {
+	if config.DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	p := &PublicProfile{ID: userID}
+	var hidden []int
+	err := config.DB.QueryRow(ctx, `
+		SELECT u.name,
+			COALESCE(pr.avatar_url, ''), COALESCE(pr.bio, ''),
+			COALESCE(pr.hidden_interests, '{}')
+		FROM users u
+		LEFT JOIN profiles pr ON pr.user_id = u.id
+		WHERE u.id = $1
+		AND (
+			u.id = $2
+			OR (
+				NOT EXISTS (
+					SELECT 1 FROM blocks b
+					WHERE (b.blocker_id = $2 AND b.blocked_id = u.id)
+					   OR (b.blocker_id = u.id AND b.blocked_id = $2)
+				)
+				AND (
+					COALESCE(pr.discoverable, true)
+					OR EXISTS (
+						SELECT 1 FROM messages m
+						WHERE m.receiver_id <> ''
+						AND ((m.sender_id = $3 AND m.receiver_id = $4)
+						  OR (m.sender_id = $4 AND m.receiver_id = $3))
+					)
+				)
+			)
+		)`,
+		userID, viewerID, strconv.Itoa(viewerID), strconv.Itoa(userID)).
+		Scan(&p.DisplayName, &p.AvatarURL, &p.Bio, &hidden)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrProfileHidden
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := config.DB.Query(ctx, `
+		SELECT i.name
+		FROM user_interests ui
+		JOIN interests i ON i.id = ui.interest_id
+		JOIN categories c ON c.id = i.category_id
+		WHERE ui.user_id = $1 AND COALESCE(i.is_active, true) AND NOT (i.id = ANY($2))
+		ORDER BY c.position, i.position, i.id`, userID, hidden)
+	if err != nil {
+		return nil, err
+	}
+	p.Interests, err = pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+	if p.Interests == nil {
+		p.Interests = []string{}
+	}
+	return p, nil
+}
+
+// GetChatIdentity returns how userID appears in chat. userID is a hub user
+// ID, the decimal form of users.id.
+func GetChatIdentity(ctx context.Context, userID string) (ChatIdentity, error) {
+	if config.DB == nil {
+		return ChatIdentity{}, fmt.Errorf("database not initialized")
+	}
+	id, err := strconv.Atoi(userID)
+	if err != nil {
+		return ChatIdentity{}, fmt.Errorf("invalid user id %q", userID)
+	}
+
+	var identity ChatIdentity
+	err = config.DB.QueryRow(ctx, `
+		SELECT u.name, COALESCE(pr.anonymous_campfires, false)
+		FROM users u
+		LEFT JOIN profiles pr ON pr.user_id = u.id
+		WHERE u.id = $1`, id).Scan(&identity.DisplayName, &identity.AnonymousCampfires)
+	return identity, err
+}
+
+// CanDirectMessage reports whether senderID may send receiverID a direct
+// message under the receiver's DM setting. Blocks in either direction
+// always refuse. Both are hub user IDs.
+func CanDirectMessage(ctx context.Context, senderID, receiverID string) (bool, error) {
+	if config.DB == nil {
+		return false, fmt.Errorf("database not initialized")
+	}
+	sender, err := strconv.Atoi(senderID)
+	if err != nil {
+		return false, fmt.Errorf("invalid user id %q", senderID)
+	}
+	receiver, err := strconv.Atoi(receiverID)
+	if err != nil {
+		return false, fmt.Errorf("invalid user id %q", receiverID)
+	}
+
+	var allowed bool
+	err = config.DB.QueryRow(ctx, `
+		SELECT NOT EXISTS (
+			SELECT 1 FROM blocks b
+			WHERE (b.blocker_id = $1 AND b.blocked_id = $2)
+			   OR (b.blocker_id = $2 AND b.blocked_id = $1)
+		)
+		AND CASE COALESCE((SELECT allow_dms FROM profiles WHERE user_id = $2), 'everyone')
+			WHEN 'everyone' THEN true
+			WHEN 'matches' THEN EXISTS (
+				SELECT 1
+				FROM user_interests a
+				JOIN user_interests b ON b.interest_id = a.interest_id
+				JOIN interests i ON i.id = a.interest_id
+				WHERE a.user_id = $1 AND b.user_id = $2 AND COALESCE(i.is_active, true)
+			)
+			ELSE false
+		END
+		AND EXISTS (SELECT 1 FROM users WHERE id = $2)`,
+		sender, receiver).Scan(&allowed)
+	if err != nil {
+		return false, err
+	}
+	return allowed, nil
+}
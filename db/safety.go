@@ -0,0 +1,102 @@
+package db
+
+import (
+	"Remainwith/config"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SafetyPlan is a user's private plan for getting through a crisis. The
+// sections follow the Stanley-Brown safety planning intervention.
+type SafetyPlan struct {
+	UserID           int
+	WarningSigns     string // thoughts, moods or situations that signal a crisis
+	CopingStrategies string // things I can do on my own to take my mind off it
+	Distractions     string // people and places that help me feel better
+	Contacts         string // people I can ask for help
+	Professionals    string // professionals or services I can contact
+	SafeEnvironment  string // making my surroundings safer
+	ReasonsToLive    string // what matters most to me
+	Pinned           bool   // shown on the dashboard
+	UpdatedAt        time.Time
+}
+
+// InitSafetyPlans creates the safety_plans table if it does not exist.
+func InitSafetyPlans(ctx context.Context) error {
+	if config.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	_, err := config.DB.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS safety_plans (
+			user_id INT PRIMARY KEY,
+			warning_signs TEXT NOT NULL DEFAULT '',
+			coping_strategies TEXT NOT NULL DEFAULT '',
+			distractions TEXT NOT NULL DEFAULT '',
+			contacts TEXT NOT NULL DEFAULT '',
+			professionals TEXT NOT NULL DEFAULT '',
+			safe_environment TEXT NOT NULL DEFAULT '',
+			reasons_to_live TEXT NOT NULL DEFAULT '',
+			pinned BOOLEAN NOT NULL DEFAULT FALSE,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create safety_plans table: %w", err)
+	}
+	return nil
+}
+
+// GetSafetyPlan returns userID's plan, or an empty plan if they have not
+// written one yet.
+func GetSafetyPlan(ctx context.Context, userID int) (*SafetyPlan, error) {
+	if config.DB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	p := &SafetyPlan{UserID: userID}
+	err := config.DB.QueryRow(ctx,
+		`SELECT warning_signs, coping_strategies, distractions, contacts,
+		        professionals, safe_environment, reasons_to_live, pinned, updated_at
+		 FROM safety_plans WHERE user_id = $1`, userID,
+	).Scan(&p.WarningSigns, &p.CopingStrategies, &p.Distractions, &p.Contacts,
+		&p.Professionals, &p.SafeEnvironment, &p.ReasonsToLive, &p.Pinned, &p.UpdatedAt)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("failed to load safety plan: %w", err)
+	}
+	return p, nil
+}
+
+// SaveSafetyPlan creates or replaces a user's plan.
+func SaveSafetyPlan(ctx context.Context, p *SafetyPlan) error {
+	if config.DB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	err := config.DB.QueryRow(ctx,
+		`INSERT INTO safety_plans (user_id, warning_signs, coping_strategies, distractions,
+		        contacts, professionals, safe_environment, reasons_to_live, pinned, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())
+		 ON CONFLICT (user_id) DO UPDATE SET
+		        warning_signs = EXCLUDED.warning_signs,
+		        coping_strategies = EXCLUDED.coping_strategies,
+		        distractions = EXCLUDED.distractions,
+		        contacts = EXCLUDED.contacts,
+		        professionals = EXCLUDED.professionals,
+		        safe_environment = EXCLUDED.safe_environment,
+		        reasons_to_live = EXCLUDED.reasons_to_live,
+		        pinned = EXCLUDED.pinned,
+		        updated_at = NOW()
+		 RETURNING updated_at`,
+		p.UserID, p.WarningSigns, p.CopingStrategies, p.Distractions,
+		p.Contacts, p.Professionals, p.SafeEnvironment, p.ReasonsToLive, p.Pinned,
+	).Scan(&p.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save safety plan: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"Remainwith/config"
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// UserStore holds accounts and pending email changes.
+type UserStore interface {
+	GetUserByID(ctx context.Context, userID int) (*Userinfo, error)
+	GetUserByEmail(ctx context.Context, email string) (*Userinfo, error)
+
+	// CheckUser reports whether an account uses email.
+	CheckUser(ctx context.Context, email string) (bool, error)
+
+	// NewUser creates an account; password must already be hashed.
+	NewUser(ctx context.Context, name, email, password string) error
+
+	UpdateUserName(ctx context.Context, userID int, name string) error
+	SetBio(ctx context.Context, userID int, bio string) error
+	CreateEmailChange(ctx context.Context, userID int, newEmail string, ttl time.Duration) (string, error)
+	ConfirmEmailChange(ctx context.Context, token string) (*Userinfo, string, error)
+}
+
+// JournalStore holds journal entries. Every method is scoped to the
+// owning user; entries of other users are reported as ErrJournalNotFound.
+type JournalStore interface {
+	GetJournal(ctx context.Context, id, userID int) (*Journal, error)
+
+	// GetJournalsByUserID lists entries newest first.
+	GetJournalsByUserID(ctx context.Context, userID int) ([]Journal, error)
+
+	NewJournal(ctx context.Context, userID int, title, description string) (int, error)
+	UpdateJournal(ctx context.Context, id, userID int, title, description string) error
+	DeleteJournal(ctx context.Context, id, userID int) error
+}
+
+// InterestStore holds the interest catalog as users see it and each
+// user's picks from it.
+type InterestStore interface {
+	GetInterestCatalog(ctx context.Context, includeInactive bool) ([]Category, error)
+
+	// GetUserInterests returns the names of the user's interests.
+	GetUserInterests(ctx context.Context, userID int) ([]string, error)
+
+	// GetUserInterestIDs returns the IDs of the user's active interests.
+	GetUserInterestIDs(ctx context.Context, userID int) ([]int, error)
+
+	SaveUserInterests(ctx context.Context, userID int, interestIDs []int) error
+	SaveUserInterestsByNames(ctx context.Context, userID int, interestNames []string) error
+	ShouldShowOnboarding(ctx context.Context, userID int) (bool, error)
+}
+
+// Postgres implements the stores on a connection pool.
+type Postgres struct {
+	pool *pgxpool.Pool
+}
+
+var (
+	_ UserStore     = (*Postgres)(nil)
+	_ JournalStore  = (*Postgres)(nil)
+	_ InterestStore = (*Postgres)(nil)
+)
+
+// NewPostgres returns stores backed by pool.
+func NewPostgres(pool *pgxpool.Pool) *Postgres {
+	return &Postgres{pool: pool}
+}
+
+// defaultStore is the pool from config.DB, for the package functions
+// below that code outside the stores still calls.
+func defaultStore() *Postgres {
+	return &Postgres{pool: config.DB}
+}
+
+// GetInterestCatalog is Postgres.GetInterestCatalog on config.DB.
+func GetInterestCatalog(ctx context.Context, includeInactive bool) ([]Category, error) {
+	return defaultStore().GetInterestCatalog(ctx, includeInactive)
+}
+
+// GetUserInterests is Postgres.GetUserInterests on config.DB.
+func GetUserInterests(ctx context.Context, userID int) ([]string, error) {
+	return defaultStore().GetUserInterests(ctx, userID)
+}
+
+// GetUserInterestIDs is Postgres.GetUserInterestIDs on config.DB.
+func GetUserInterestIDs(ctx context.Context, userID int) ([]int, error) {
+	return defaultStore().GetUserInterestIDs(ctx, userID)
+}
@@ -0,0 +1,249 @@
+package db_test
+
+import (
+	"Remainwith/db"
+	"Remainwith/internal/models"
+	"Remainwith/internal/testdb"
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) { testdb.Main(m) }
+
+func TestUsers(t *testing.T) {
+	store := testdb.New(t)
+	ctx := context.Background()
+	testdb.CreateUser(t, store, "Ana", "ana@example.com", "secret")
+
+	if err := store.NewUser(ctx, "Ana", "ana@example.com", "x"); err == nil || err.Error() != "email already exists" {
+		t.Errorf("duplicate email: %v", err)
+	}
+
+	tests := []struct {
+		email  string
+		exists bool
+	}{
+		{"ana@example.com", true},
+		{"ben@example.com", false},
+	}
+	for _, tt := range tests {
+		if exists, err := store.CheckUser(ctx, tt.email); err != nil || exists != tt.exists {
+			t.Errorf("CheckUser(%q) = %v, %v; want %v", tt.email, exists, err, tt.exists)
+		}
+	}
+
+	user, err := store.GetUserByEmail(ctx, "ana@example.com")
+	if err != nil || user.Role != "user" || user.SuspendedUntil != nil {
+		t.Errorf("GetUserByEmail = %+v, %v", user, err)
+	}
+}
+
+func TestJournalOwnership(t *testing.T) {
+	store := testdb.New(t)
+	ctx := context.Background()
+	ana := testdb.CreateUser(t, store, "Ana", "ana@example.com", "secret")
+	ben := testdb.CreateUser(t, store, "Ben", "ben@example.com", "secret")
+
+	id, err := store.NewJournal(ctx, ana.ID, "Monday", "Slept well")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		userID int
+		call   func(userID int) error
+	}{
+		{"get", ben.ID, func(userID int) error {
+			_, err := store.GetJournal(ctx, id, userID)
+			return err
+		}},
+		{"update", ben.ID, func(userID int) error {
+			return store.UpdateJournal(ctx, id, userID, "Mine now", "x")
+		}},
+		{"delete", ben.ID, func(userID int) error {
+			return store.DeleteJournal(ctx, id, userID)
+		}},
+		{"delete missing", ana.ID, func(userID int) error {
+			return store.DeleteJournal(ctx, id+1, userID)
+		}},
+	}
+	for _, tt := range tests {
+		if err := tt.call(tt.userID); !errors.Is(err, db.ErrJournalNotFound) {
+			t.Errorf("%s: %v, want ErrJournalNotFound", tt.name, err)
+		}
+	}
+
+	if j, err := store.GetJournal(ctx, id, ana.ID); err != nil || j.Title != "Monday" {
+		t.Fatalf("entry changed by another user: %+v, %v", j, err)
+	}
+	if err := store.UpdateJournal(ctx, id, ana.ID, "Monday", "Slept badly"); err != nil {
+		t.Fatal(err)
+	}
+	if list, err := store.GetJournalsByUserID(ctx, ana.ID); err != nil || len(list) != 1 || list[0].Desc != "Slept badly" {
+		t.Errorf("GetJournalsByUserID = %+v, %v", list, err)
+	}
+	if err := store.DeleteJournal(ctx, id, ana.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetJournal(ctx, id, ana.ID); !errors.Is(err, db.ErrJournalNotFound) {
+		t.Errorf("get after delete: %v", err)
+	}
+}
+
+func TestSaveUserInterests(t *testing.T) {
+	store := testdb.New(t)
+	ctx := context.Background()
+	ana := testdb.CreateUser(t, store, "Ana", "ana@example.com", "secret")
+
+	if show, err := store.ShouldShowOnboarding(ctx, ana.ID); err != nil || !show {
+		t.Errorf("new user: ShouldShowOnboarding = %v, %v", show, err)
+	}
+
+	tests := []struct {
+		names []string
+		want  int
+	}{
+		{[]string{"Anxiety", "Mindfulness"}, 2},
+		// Unknown names are dropped rather than failing the save
+		{[]string{"Gratitude", "Not an interest"}, 1},
+		{nil, 0},
+	}
+	for _, tt := range tests {
+		if err := store.SaveUserInterestsByNames(ctx, ana.ID, tt.names); err != nil {
+			t.Fatalf("saving %q: %v", tt.names, err)
+		}
+		names, err := store.GetUserInterests(ctx, ana.ID)
+		if err != nil || len(names) != tt.want {
+			t.Errorf("after saving %q: GetUserInterests = %q, %v; want %d", tt.names, names, err, tt.want)
+		}
+		ids, err := store.GetUserInterestIDs(ctx, ana.ID)
+		if err != nil || len(ids) != tt.want {
+			t.Errorf("after saving %q: GetUserInterestIDs = %v, %v; want %d", tt.names, ids, err, tt.want)
+		}
+		if show, _ := store.ShouldShowOnboarding(ctx, ana.ID); show != (tt.want == 0) {
+			t.Errorf("after saving %q: ShouldShowOnboarding = %v", tt.names, show)
+		}
+	}
+}
+
+func TestEmailChange(t *testing.T) {
+	store := testdb.New(t)
+	ctx := context.Background()
+	ana := testdb.CreateUser(t, store, "Ana", "ana@example.com", "secret")
+	testdb.CreateUser(t, store, "Ben", "ben@example.com", "secret")
+
+	if _, err := store.CreateEmailChange(ctx, ana.ID, "ben@example.com", time.Hour); !errors.Is(err, db.ErrEmailTaken) {
+		t.Errorf("change to a taken address: %v", err)
+	}
+
+	expired, err := store.CreateEmailChange(ctx, ana.ID, "old@example.com", -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.ConfirmEmailChange(ctx, expired); !errors.Is(err, db.ErrEmailChangeInvalid) {
+		t.Errorf("expired token: %v", err)
+	}
+
+	token, err := store.CreateEmailChange(ctx, ana.ID, "ana@new.example", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, old, err := store.ConfirmEmailChange(ctx, token)
+	if err != nil || user.Email != "ana@new.example" || old != "ana@example.com" {
+		t.Fatalf("ConfirmEmailChange = %+v, %q, %v", user, old, err)
+	}
+	if _, _, err := store.ConfirmEmailChange(ctx, token); !errors.Is(err, db.ErrEmailChangeInvalid) {
+		t.Errorf("second use: %v", err)
+	}
+}
+
+func TestAccountNameIsWhatOthersSee(t *testing.T) {
+	store := testdb.New(t)
+	ctx := context.Background()
+	ana := testdb.CreateUser(t, store, "Ana", "ana@example.com", "secret")
+	ben := testdb.CreateUser(t, store, "Ben", "ben@example.com", "secret")
+
+	if err := store.UpdateUserName(ctx, ana.ID, "Sky"); err != nil {
+		t.Fatal(err)
+	}
+	identity, err := db.GetChatIdentity(ctx, strconv.Itoa(ana.ID))
+	if err != nil || identity.DisplayName != "Sky" {
+		t.Errorf("chat identity = %+v, %v; want the new name", identity, err)
+	}
+	profile, err := db.GetPublicProfile(ctx, ben.ID, ana.ID)
+	if err != nil || profile.DisplayName != "Sky" {
+		t.Errorf("public profile = %+v, %v; want the new name", profile, err)
+	}
+}
+
+func TestReportOnlyVisibleMessages(t *testing.T) {
+	testdb.New(t)
+	ctx := context.Background()
+
+	// 1 sends; 2 receives the DM; 3 is in the room; 4 left before the
+	// room message; 5 was never anywhere; 6 is in the room through
+	// another server
+	visit, err := db.StartRoomVisit(ctx, "test", "campfire:1", "4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.EndRoomVisit(ctx, visit); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.StartRoomVisit(ctx, "test", "campfire:1", "3"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.StartRoomVisit(ctx, "other", "campfire:1", "6"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A skewed app clock doesn't matter; messages are stamped by the database
+	skewed := time.Now().Add(-time.Hour)
+	dm := &models.Message{ClientID: "a", Room: "lobby", SenderID: "1", ReceiverID: "2", Content: "private", CreatedAt: skewed}
+	room := &models.Message{ClientID: "b", Room: "campfire:1", SenderID: "1", Content: "public", CreatedAt: skewed}
+	for _, msg := range []*models.Message{dm, room} {
+		if _, err := db.SaveMessage(ctx, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// This server restarts: its visits end, the other server's don't
+	if err := db.EndInstanceVisits(ctx, "test"); err != nil {
+		t.Fatal(err)
+	}
+	after := &models.Message{ClientID: "c", Room: "campfire:1", SenderID: "1", Content: "after restart"}
+	if _, err := db.SaveMessage(ctx, after); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		reporter int
+		msg      *models.Message
+		allowed  bool
+	}{
+		{"own DM", 1, dm, true},
+		{"received DM", 2, dm, true},
+		{"someone else's DM", 3, dm, false},
+		{"room message while there", 3, room, true},
+		{"room message after leaving", 4, room, false},
+		{"room never visited", 5, room, false},
+		{"room message through another server", 6, room, true},
+		{"room message after a restart", 3, after, false},
+		{"room message another server still serves", 6, after, true},
+	}
+	for _, tt := range tests {
+		r := &db.Report{ReporterID: tt.reporter, MessageID: int(tt.msg.ID), Reason: "spam"}
+		err := db.NewReport(ctx, r)
+		switch {
+		case tt.allowed && (err != nil || r.Content != tt.msg.Content):
+			t.Errorf("%s: NewReport = %v, content %q", tt.name, err, r.Content)
+		case !tt.allowed && !errors.Is(err, db.ErrMessageNotFound):
+			t.Errorf("%s: NewReport = %v, want ErrMessageNotFound", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"Remainwith/internal/static"
+	"embed"
+	"io/fs"
+	"os"
+)
+
+// web holds the page templates, static files and chat safety lists, so the
+// binary runs from any working directory.
+//
+//go:embed frontend/*.tmpl frontend/layouts frontend/partials frontend/static assets
+//go:embed config/wordlists/*.txt config/crisis_resources.json
+var web embed.FS
+
+// webRoot returns the embedded files or, if dir is set, that checkout of
+// the repository.
+func webRoot(dir string) fs.FS {
+	if dir != "" {
+		return os.DirFS(dir)
+	}
+	return web
+}
+
+// safetyFiles returns the directory holding the wordlists and crisis
+// resources: safetyDir if set, else config/ in the binary or, if assetsDir
+// is set, in that checkout. The built-in slur list is empty; deployments
+// supply theirs through safetyDir.
+func safetyFiles(safetyDir, assetsDir string) (fs.FS, error) {
+	if safetyDir != "" {
+		return os.DirFS(safetyDir), nil
+	}
+	return fs.Sub(webRoot(assetsDir), "config")
+}
+
+// webFiles returns the page templates and the static files, read from the
+// binary or, if dir is set, from that checkout of the repository. With
+// reload set, static files from a checkout are reloaded when they change.
+func webFiles(dir string, reload bool) (templates fs.FS, files *static.Files, err error) {
+	root := webRoot(dir)
+	templates, err = fs.Sub(root, "frontend")
+	if err != nil {
+		return nil, nil, err
+	}
+	staticDir, err := fs.Sub(root, "frontend/static")
+	if err != nil {
+		return nil, nil, err
+	}
+	assetsDir, err := fs.Sub(root, "assets")
+	if err != nil {
+		return nil, nil, err
+	}
+	files, err = static.New(reload && dir != "",
+		static.Mount{Prefix: "/static/", FS: staticDir},
+		static.Mount{Prefix: "/assets/", FS: assetsDir},
+	)
+	return templates, files, err
+}
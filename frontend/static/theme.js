@@ -0,0 +1,29 @@
+(function() {
+    const htmlElement = document.documentElement;
+    const themeSelect = document.getElementById('theme-select');
+    const storageKey = 'remainwith-theme';
+
+    function loadTheme() {
+        const savedTheme = localStorage.getItem(storageKey);
+        if (savedTheme) {
+            setTheme(savedTheme);
+        } else {
+            const systemDark = window.matchMedia('(prefers-color-scheme: dark)').matches;
+            setTheme(systemDark ? 'dark' : 'light');
+        }
+    }
+
+    function setTheme(theme) {
+        htmlElement.setAttribute('data-theme', theme);
+        if(themeSelect) themeSelect.value = theme;
+        localStorage.setItem(storageKey, theme);
+    }
+
+    loadTheme();
+
+    if (themeSelect) {
+        themeSelect.addEventListener('change', (e) => {
+            setTheme(e.target.value);
+        });
+    }
+})();
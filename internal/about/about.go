@@ -1,7 +1,7 @@
 package about
 
 import (
-	"html/template"
+	"Remainwith/internal/handler"
 	"net/http"
 )
 
@@ -10,11 +10,5 @@ func AboutpageHandler(w http.ResponseWriter, r *http.Request) {
 		http.NotFound(w, r)
 		return
 	}
-	tmpl, err := template.ParseFiles("frontend/about.tmpl")
-	if err != nil {
-		http.Error(w, "issue faced for parsing about", http.StatusInternalServerError)
-		return
-	}
-
-	tmpl.Execute(w, nil)
+	handler.Render(w, r, http.StatusOK, "about", nil)
 }
@@ -0,0 +1,554 @@
+package admin
+
+import (
+	"Remainwith/db"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxCatalogName   = 60
+	maxCategoryEmoji = 8
+	maxCategoryDesc  = 200
+)
+
+// inputError is a validation failure worth showing to the admin as is.
+type inputError string
+
+func (e inputError) Error() string { return string(e) }
+
+// catalogStatus picks the response code for an error from a catalog change.
+func catalogStatus(err error) int {
+	var input inputError
+	switch {
+	case errors.As(err, &input), errors.Is(err, db.ErrBadOrder):
+		return http.StatusBadRequest
+	case errors.Is(err, db.ErrCategoryNotFound), errors.Is(err, db.ErrInterestNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, db.ErrDuplicateName):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// catalogError writes err as plain text, hiding unexpected errors.
+func catalogError(w http.ResponseWriter, err error) {
+	status := catalogStatus(err)
+	if status == http.StatusInternalServerError {
+		log.Printf("Interest catalog: %v", err)
+		http.Error(w, "Failed to update the interest catalog", status)
+		return
+	}
+	http.Error(w, err.Error(), status)
+}
+
+func catalogName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", inputError("name is required")
+	}
+	if utf8.RuneCountInString(name) > maxCatalogName {
+		return "", inputError("name is too long")
+	}
+	return name, nil
+}
+
+func categoryFields(name, emoji, description string) (string, string, string, error) {
+	name, err := catalogName(name)
+	if err != nil {
+		return "", "", "", err
+	}
+	emoji = strings.TrimSpace(emoji)
+	if utf8.RuneCountInString(emoji) > maxCategoryEmoji {
+		return "", "", "", inputError("emoji is too long")
+	}
+	description = strings.TrimSpace(description)
+	if utf8.RuneCountInString(description) > maxCategoryDesc {
+		return "", "", "", inputError("description is too long")
+	}
+	return name, emoji, description, nil
+}
+
+// The catalog operations below are shared by the console forms and the
+// JSON API. Each validates its input, applies it and writes the audit log.
+
+func (c *Console) addCategory(r *http.Request, name, emoji, description string) (int, error) {
+	name, emoji, description, err := categoryFields(name, emoji, description)
+	if err != nil {
+		return 0, err
+	}
+	id, err := db.CreateCategory(r.Context(), name, emoji, description)
+	if err != nil {
+		return 0, err
+	}
+	c.audit(r, "category.add", "category", strconv.Itoa(id), name)
+	return id, nil
+}
+
+func (c *Console) updateCategory(r *http.Request, id int, name, emoji, description string, weight float64) error {
+	name, emoji, description, err := categoryFields(name, emoji, description)
+	if err != nil {
+		return err
+	}
+	if weight < db.MinCategoryWeight || weight > db.MaxCategoryWeight {
+		return inputError(fmt.Sprintf("weight must be between %g and %g", db.MinCategoryWeight, db.MaxCategoryWeight))
+	}
+	if err := db.UpdateCategory(r.Context(), id, name, emoji, description, weight); err != nil {
+		return err
+	}
+	c.audit(r, "category.update", "category", strconv.Itoa(id), name)
+	return nil
+}
+
+func (c *Console) reorderCategories(r *http.Request, ids []int) error {
+	if err := db.ReorderCategories(r.Context(), ids); err != nil {
+		return err
+	}
+	c.audit(r, "category.reorder", "category", "", joinIDs(ids))
+	return nil
+}
+
+func (c *Console) addInterest(r *http.Request, name string, categoryID int) (int, error) {
+	name, err := catalogName(name)
+	if err != nil {
+		return 0, err
+	}
+	id, err := db.AddInterest(r.Context(), name, categoryID)
+	if err != nil {
+		return 0, err
+	}
+	c.audit(r, "interest.add", "interest", strconv.Itoa(id), name)
+	return id, nil
+}
+
+func (c *Console) updateInterest(r *http.Request, current *db.Interest, name string, categoryID int) error {
+	name, err := catalogName(name)
+	if err != nil {
+		return err
+	}
+	if err := db.UpdateInterest(r.Context(), current.ID, name, categoryID); err != nil {
+		return err
+	}
+
+	var changes []string
+	if name != current.Name {
+		changes = append(changes, current.Name+" → "+name)
+	}
+	if categoryID != current.CategoryID {
+		changes = append(changes, "category "+strconv.Itoa(current.CategoryID)+" → "+strconv.Itoa(categoryID))
+	}
+	if len(changes) > 0 {
+		c.audit(r, "interest.update", "interest", strconv.Itoa(current.ID), strings.Join(changes, ", "))
+	}
+	return nil
+}
+
+func (c *Console) setInterestActive(r *http.Request, id int, active bool) error {
+	if err := db.SetInterestActive(r.Context(), id, active); err != nil {
+		return err
+	}
+	action := "interest.deactivate"
+	if active {
+		action = "interest.activate"
+	}
+	c.audit(r, action, "interest", strconv.Itoa(id), "")
+	return nil
+}
+
+func (c *Console) reorderInterests(r *http.Request, categoryID int, ids []int) error {
+	if err := db.ReorderInterests(r.Context(), categoryID, ids); err != nil {
+		return err
+	}
+	c.audit(r, "interest.reorder", "category", strconv.Itoa(categoryID), joinIDs(ids))
+	return nil
+}
+
+func joinIDs(ids []int) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(id)
+	}
+	return strings.Join(parts, ",")
+}
+
+// moveID returns ids with id swapped one place towards the front (up) or
+// back. The order is unchanged when id is already at that end.
+func moveID(ids []int, id int, up bool) []int {
+	moved := append([]int(nil), ids...)
+	for i, v := range moved {
+		if v != id {
+			continue
+		}
+		j := i + 1
+		if up {
+			j = i - 1
+		}
+		if j >= 0 && j < len(moved) {
+			moved[i], moved[j] = moved[j], moved[i]
+		}
+		break
+	}
+	return moved
+}
+
+// --- Console pages ---
+
+// InterestsHandler shows the whole interest catalog. Admins only.
+func (c *Console) InterestsHandler(w http.ResponseWriter, r *http.Request) {
+	categories, err := db.GetInterestCatalog(r.Context(), true)
+	if err != nil {
+		log.Printf("GetInterestCatalog: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	c.render(w, r, page{Section: "interests", Categories: categories})
+}
+
+// AddCategoryHandler adds a category from the console. Admins only.
+func (c *Console) AddCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	if _, err := c.addCategory(r, r.FormValue("name"), r.FormValue("emoji"), r.FormValue("description")); err != nil {
+		catalogError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/admin/interests", http.StatusSeeOther)
+}
+
+// UpdateCategoryHandler edits a category from the console. Admins only.
+func (c *Console) UpdateCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		http.Error(w, "Invalid category id", http.StatusBadRequest)
+		return
+	}
+	weight, err := strconv.ParseFloat(r.FormValue("weight"), 64)
+	if err != nil {
+		http.Error(w, "Invalid weight", http.StatusBadRequest)
+		return
+	}
+	if err := c.updateCategory(r, id, r.FormValue("name"), r.FormValue("emoji"), r.FormValue("description"), weight); err != nil {
+		catalogError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/admin/interests", http.StatusSeeOther)
+}
+
+// MoveCategoryHandler moves a category one place up or down. Admins only.
+func (c *Console) MoveCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		http.Error(w, "Invalid category id", http.StatusBadRequest)
+		return
+	}
+
+	categories, err := db.GetInterestCatalog(r.Context(), true)
+	if err != nil {
+		catalogError(w, err)
+		return
+	}
+	ids := make([]int, len(categories))
+	for i, cat := range categories {
+		ids[i] = cat.ID
+	}
+
+	if err := c.reorderCategories(r, moveID(ids, id, r.FormValue("dir") == "up")); err != nil {
+		catalogError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/admin/interests", http.StatusSeeOther)
+}
+
+// AddInterestHandler adds an interest from the console. Admins only.
+func (c *Console) AddInterestHandler(w http.ResponseWriter, r *http.Request) {
+	categoryID, _ := strconv.Atoi(r.FormValue("category_id"))
+	if _, err := c.addInterest(r, r.FormValue("name"), categoryID); err != nil {
+		catalogError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/admin/interests", http.StatusSeeOther)
+}
+
+// UpdateInterestHandler renames or recategorizes an interest from the
+// console. Admins only.
+func (c *Console) UpdateInterestHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		http.Error(w, "Invalid interest id", http.StatusBadRequest)
+		return
+	}
+	current, err := db.GetInterest(r.Context(), id)
+	if err != nil {
+		catalogError(w, err)
+		return
+	}
+
+	categoryID, _ := strconv.Atoi(r.FormValue("category_id"))
+	if err := c.updateInterest(r, current, r.FormValue("name"), categoryID); err != nil {
+		catalogError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/admin/interests", http.StatusSeeOther)
+}
+
+// MoveInterestHandler moves an interest one place up or down within its
+// category. Admins only.
+func (c *Console) MoveInterestHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		http.Error(w, "Invalid interest id", http.StatusBadRequest)
+		return
+	}
+	current, err := db.GetInterest(r.Context(), id)
+	if err != nil {
+		catalogError(w, err)
+		return
+	}
+
+	categories, err := db.GetInterestCatalog(r.Context(), true)
+	if err != nil {
+		catalogError(w, err)
+		return
+	}
+	var ids []int
+	for _, cat := range categories {
+		if cat.ID == current.CategoryID {
+			for _, i := range cat.Interests {
+				ids = append(ids, i.ID)
+			}
+		}
+	}
+
+	if err := c.reorderInterests(r, current.CategoryID, moveID(ids, id, r.FormValue("dir") == "up")); err != nil {
+		catalogError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/admin/interests", http.StatusSeeOther)
+}
+
+// SetInterestActiveHandler shows or hides an interest. Admins only.
+func (c *Console) SetInterestActiveHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		http.Error(w, "Invalid interest id", http.StatusBadRequest)
+		return
+	}
+	if err := c.setInterestActive(r, id, r.FormValue("active") == "true"); err != nil {
+		catalogError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/admin/interests", http.StatusSeeOther)
+}
+
+// --- JSON API ---
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// CatalogAPIHandler serves GET /api/admin/interests: every category with
+// all of its interests, hidden ones included.
+func (c *Console) CatalogAPIHandler(w http.ResponseWriter, r *http.Request) {
+	categories, err := db.GetInterestCatalog(r.Context(), true)
+	if err != nil {
+		catalogError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, categories)
+}
+
+// CreateCategoryAPIHandler serves POST /api/admin/categories with a JSON
+// body of {"name": "...", "emoji": "...", "description": "..."}.
+func (c *Console) CreateCategoryAPIHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name        string `json:"name"`
+		Emoji       string `json:"emoji"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	id, err := c.addCategory(r, req.Name, req.Emoji, req.Description)
+	if err != nil {
+		catalogError(w, err)
+		return
+	}
+	category, err := db.GetCategory(r.Context(), id)
+	if err != nil {
+		catalogError(w, err)
+		return
+	}
+	category.Interests = []db.Interest{}
+	writeJSON(w, http.StatusCreated, category)
+}
+
+// UpdateCategoryAPIHandler serves PATCH /api/admin/categories/{id}. Fields
+// left out of the body keep their current value.
+func (c *Console) UpdateCategoryAPIHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		http.Error(w, "Invalid category id", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		Name        *string  `json:"name"`
+		Emoji       *string  `json:"emoji"`
+		Description *string  `json:"description"`
+		Weight      *float64 `json:"weight"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	category, err := db.GetCategory(r.Context(), id)
+	if err != nil {
+		catalogError(w, err)
+		return
+	}
+	if req.Name != nil {
+		category.Name = *req.Name
+	}
+	if req.Emoji != nil {
+		category.Emoji = *req.Emoji
+	}
+	if req.Description != nil {
+		category.Description = *req.Description
+	}
+	if req.Weight != nil {
+		category.Weight = *req.Weight
+	}
+
+	if err := c.updateCategory(r, id, category.Name, category.Emoji, category.Description, category.Weight); err != nil {
+		catalogError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReorderCategoriesAPIHandler serves PUT /api/admin/categories/order with
+// a JSON body of {"ids": [...]} listing every category in its new order.
+func (c *Console) ReorderCategoriesAPIHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		IDs []int `json:"ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if err := c.reorderCategories(r, req.IDs); err != nil {
+		catalogError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReorderInterestsAPIHandler serves PUT /api/admin/categories/{id}/order
+// with a JSON body of {"ids": [...]} listing every interest in the
+// category in its new order.
+func (c *Console) ReorderInterestsAPIHandler(w http.ResponseWriter, r *http.Request) {
+	categoryID, ok := pathID(r)
+	if !ok {
+		http.Error(w, "Invalid category id", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		IDs []int `json:"ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if err := c.reorderInterests(r, categoryID, req.IDs); err != nil {
+		catalogError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateInterestAPIHandler serves POST /api/admin/interests with a JSON
+// body of {"name": "...", "category_id": n}.
+func (c *Console) CreateInterestAPIHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name       string `json:"name"`
+		CategoryID int    `json:"category_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	id, err := c.addInterest(r, req.Name, req.CategoryID)
+	if err != nil {
+		catalogError(w, err)
+		return
+	}
+	interest, err := db.GetInterest(r.Context(), id)
+	if err != nil {
+		catalogError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, interest)
+}
+
+// UpdateInterestAPIHandler serves PATCH /api/admin/interests/{id} with any
+// of {"name": "...", "category_id": n, "active": bool}. Setting active to
+// false deactivates the interest.
+func (c *Console) UpdateInterestAPIHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		http.Error(w, "Invalid interest id", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		Name       *string `json:"name"`
+		CategoryID *int    `json:"category_id"`
+		Active     *bool   `json:"active"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	current, err := db.GetInterest(r.Context(), id)
+	if err != nil {
+		catalogError(w, err)
+		return
+	}
+
+	if req.Name != nil || req.CategoryID != nil {
+		name, categoryID := current.Name, current.CategoryID
+		if req.Name != nil {
+			name = *req.Name
+		}
+		if req.CategoryID != nil {
+			categoryID = *req.CategoryID
+		}
+		if err := c.updateInterest(r, current, name, categoryID); err != nil {
+			catalogError(w, err)
+			return
+		}
+	}
+	if req.Active != nil && *req.Active != current.Active {
+		if err := c.setInterestActive(r, id, *req.Active); err != nil {
+			catalogError(w, err)
+			return
+		}
+	}
+
+	updated, err := db.GetInterest(r.Context(), id)
+	if err != nil {
+		catalogError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, updated)
+}
@@ -0,0 +1,51 @@
+package admin
+
+import (
+	"Remainwith/db"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestMoveID(t *testing.T) {
+	ids := []int{4, 7, 9}
+	tests := []struct {
+		id   int
+		up   bool
+		want []int
+	}{
+		{7, true, []int{7, 4, 9}},
+		{7, false, []int{4, 9, 7}},
+		{4, true, []int{4, 7, 9}},
+		{9, false, []int{4, 7, 9}},
+		{5, true, []int{4, 7, 9}},
+	}
+	for _, tt := range tests {
+		if got := moveID(ids, tt.id, tt.up); !slices.Equal(got, tt.want) {
+			t.Errorf("moveID(%v, %d, %v) = %v, want %v", ids, tt.id, tt.up, got, tt.want)
+		}
+	}
+	if !slices.Equal(ids, []int{4, 7, 9}) {
+		t.Errorf("moveID modified its input: %v", ids)
+	}
+}
+
+func TestCatalogStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{inputError("name is required"), http.StatusBadRequest},
+		{db.ErrBadOrder, http.StatusBadRequest},
+		{fmt.Errorf("wrapped: %w", db.ErrInterestNotFound), http.StatusNotFound},
+		{db.ErrCategoryNotFound, http.StatusNotFound},
+		{db.ErrDuplicateName, http.StatusConflict},
+		{fmt.Errorf("connection reset"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := catalogStatus(tt.err); got != tt.want {
+			t.Errorf("catalogStatus(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
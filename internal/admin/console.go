@@ -0,0 +1,296 @@
+// Package admin serves the /admin console used by moderators and admins to
+// review reports, suspend users, close campfires and manage the interest
+// catalog. Every action is written to the audit log.
+package admin
+
+import (
+	"Remainwith/db"
+	"Remainwith/internal/chat"
+	"Remainwith/internal/handler"
+	"Remainwith/internal/ws"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	listLimit         = 100
+	maxSuspensionDays = 365
+	maxReasonLength   = 500
+)
+
+// Console holds what admin actions need to reach beyond the database.
+type Console struct {
+	hub       *ws.Hub
+	campfires *chat.Campfires
+}
+
+// NewConsole returns a console that disconnects suspended users from hub
+// and closes campfires through campfires.
+func NewConsole(hub *ws.Hub, campfires *chat.Campfires) *Console {
+	return &Console{hub: hub, campfires: campfires}
+}
+
+// page is the data for admin.tmpl. Only the fields for Section are set.
+type page struct {
+	handler.Page
+	Section string
+	IsAdmin bool
+	Now     time.Time
+
+	Status        string
+	Reports       []db.Report
+	ReportReasons map[string]string
+
+	Query string
+	Users []db.UserAccount
+	Roles []string
+
+	Campfires []campfireRow
+
+	Categories []db.Category
+
+	Audit []db.AuditEntry
+}
+
+type campfireRow struct {
+	db.Campfire
+	Participants int
+}
+
+func (c *Console) render(w http.ResponseWriter, r *http.Request, p page) {
+	p.IsAdmin = handler.GetRoleFromContext(r) == db.RoleAdmin
+	p.Now = time.Now()
+
+	w.Header().Set("Cache-Control", "no-store")
+	handler.Render(w, r, http.StatusOK, "admin", &p)
+}
+
+// audit records an action taken by the requesting user.
+func (c *Console) audit(r *http.Request, action, targetType, targetID, details string) {
+	actorID := handler.GetUserIDFromContext(r)
+	if err := db.LogAudit(r.Context(), actorID, action, targetType, targetID, details); err != nil {
+		log.Printf("Audit %s by %d: %v", action, actorID, err)
+	}
+}
+
+func pathID(r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	return id, err == nil && id > 0
+}
+
+func formReason(r *http.Request) string {
+	reason := strings.TrimSpace(r.FormValue("reason"))
+	if len(reason) > maxReasonLength {
+		reason = reason[:maxReasonLength]
+	}
+	return reason
+}
+
+// IndexHandler serves GET /admin.
+func (c *Console) IndexHandler(w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, "/admin/reports", http.StatusSeeOther)
+}
+
+// ReportsHandler lists reports, open ones by default.
+func (c *Console) ReportsHandler(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = db.ReportOpen
+	case "all":
+		status = ""
+	}
+
+	reports, err := db.ListReports(r.Context(), status, listLimit)
+	if err != nil {
+		log.Printf("ListReports: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if status == "" {
+		status = "all"
+	}
+	c.render(w, r, page{Section: "reports", Status: status, Reports: reports, ReportReasons: db.ReportReasons})
+}
+
+// ResolveReportHandler marks a report actioned or dismissed.
+func (c *Console) ResolveReportHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		http.Error(w, "Invalid report id", http.StatusBadRequest)
+		return
+	}
+
+	status := r.FormValue("status")
+	if err := db.ResolveReport(r.Context(), id, status); err != nil {
+		log.Printf("ResolveReport: %v", err)
+		http.Error(w, "Failed to resolve report", http.StatusBadRequest)
+		return
+	}
+	c.audit(r, "report."+status, "report", strconv.Itoa(id), formReason(r))
+
+	http.Redirect(w, r, "/admin/reports", http.StatusSeeOther)
+}
+
+// UsersHandler searches accounts by name or email.
+func (c *Console) UsersHandler(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	users, err := db.SearchUsers(r.Context(), query, listLimit)
+	if err != nil {
+		log.Printf("SearchUsers: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	c.render(w, r, page{
+		Section: "users",
+		Query:   query,
+		Users:   users,
+		Roles:   []string{db.RoleUser, db.RoleModerator, db.RoleAdmin},
+	})
+}
+
+// target loads the account named in the path and checks the requester
+// outranks it. Staff cannot act on themselves or their peers.
+func (c *Console) target(w http.ResponseWriter, r *http.Request) (*db.UserAccount, bool) {
+	id, ok := pathID(r)
+	if !ok {
+		http.Error(w, "Invalid user id", http.StatusBadRequest)
+		return nil, false
+	}
+	if id == handler.GetUserIDFromContext(r) {
+		http.Error(w, "You cannot do that to your own account", http.StatusBadRequest)
+		return nil, false
+	}
+
+	account, err := db.GetUserAccount(r.Context(), id)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	role := handler.GetRoleFromContext(r)
+	if role != db.RoleAdmin && db.RoleAtLeast(account.Role, role) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return nil, false
+	}
+	return account, true
+}
+
+// SuspendUserHandler suspends an account for the submitted number of days
+// and disconnects it.
+func (c *Console) SuspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	account, ok := c.target(w, r)
+	if !ok {
+		return
+	}
+
+	days, err := strconv.Atoi(r.FormValue("days"))
+	if err != nil || days < 1 || days > maxSuspensionDays {
+		http.Error(w, "Suspension must be between 1 and 365 days", http.StatusBadRequest)
+		return
+	}
+	reason := formReason(r)
+	until := time.Now().Add(time.Duration(days) * 24 * time.Hour)
+
+	if err := db.SuspendUser(r.Context(), account.ID, until, reason); err != nil {
+		log.Printf("SuspendUser: %v", err)
+		http.Error(w, "Failed to suspend user", http.StatusInternalServerError)
+		return
+	}
+	handler.SetSuspension(account.ID, until)
+	c.hub.DisconnectUser(strconv.Itoa(account.ID), "account suspended")
+	c.audit(r, "user.suspend", "user", strconv.Itoa(account.ID), strconv.Itoa(days)+" days: "+reason)
+
+	http.Redirect(w, r, "/admin/users?q="+url.QueryEscape(account.Email), http.StatusSeeOther)
+}
+
+// UnsuspendUserHandler lifts a suspension.
+func (c *Console) UnsuspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	account, ok := c.target(w, r)
+	if !ok {
+		return
+	}
+
+	if err := db.UnsuspendUser(r.Context(), account.ID); err != nil {
+		log.Printf("UnsuspendUser: %v", err)
+		http.Error(w, "Failed to lift suspension", http.StatusInternalServerError)
+		return
+	}
+	handler.SetSuspension(account.ID, time.Time{})
+	c.audit(r, "user.unsuspend", "user", strconv.Itoa(account.ID), "")
+
+	http.Redirect(w, r, "/admin/users?q="+url.QueryEscape(account.Email), http.StatusSeeOther)
+}
+
+// SetRoleHandler changes an account's role. Admins only.
+func (c *Console) SetRoleHandler(w http.ResponseWriter, r *http.Request) {
+	account, ok := c.target(w, r)
+	if !ok {
+		return
+	}
+
+	role := r.FormValue("role")
+	if err := db.SetUserRole(r.Context(), account.ID, role); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.audit(r, "user.role", "user", strconv.Itoa(account.ID), account.Role+" → "+role)
+
+	http.Redirect(w, r, "/admin/users?q="+url.QueryEscape(account.Email), http.StatusSeeOther)
+}
+
+// CampfiresHandler lists open and scheduled campfires.
+func (c *Console) CampfiresHandler(w http.ResponseWriter, r *http.Request) {
+	campfires, err := db.ListCampfires(r.Context(), listLimit)
+	if err != nil {
+		log.Printf("ListCampfires: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	rows := make([]campfireRow, 0, len(campfires))
+	for _, cf := range campfires {
+		rows = append(rows, campfireRow{cf, c.hub.ConnectedUsers(chat.HubRoom(cf.ID))})
+	}
+	c.render(w, r, page{Section: "campfires", Campfires: rows})
+}
+
+// CloseCampfireHandler closes a campfire and disconnects its members.
+func (c *Console) CloseCampfireHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		http.Error(w, "Invalid campfire id", http.StatusBadRequest)
+		return
+	}
+
+	reason := formReason(r)
+	notice := "This campfire was closed by a moderator."
+	if reason != "" {
+		notice += " " + reason
+	}
+	if err := c.campfires.Close(r.Context(), id, notice); err != nil {
+		log.Printf("CloseCampfire: %v", err)
+		http.Error(w, "Failed to close campfire", http.StatusInternalServerError)
+		return
+	}
+	c.audit(r, "campfire.close", "campfire", strconv.Itoa(id), reason)
+
+	http.Redirect(w, r, "/admin/campfires", http.StatusSeeOther)
+}
+
+// AuditHandler shows recent staff actions. Admins only.
+func (c *Console) AuditHandler(w http.ResponseWriter, r *http.Request) {
+	entries, err := db.ListAuditLog(r.Context(), listLimit)
+	if err != nil {
+		log.Printf("ListAuditLog: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	c.render(w, r, page{Section: "audit", Audit: entries})
+}
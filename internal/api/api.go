@@ -0,0 +1,148 @@
+// Package api serves the versioned JSON API under /api/v1, for clients
+// such as the mobile app that can't use the HTML forms. Requests and
+// responses are JSON, callers authenticate with a bearer token from
+// /api/v1/auth/login, and every error has the shape
+//
+//	{"error": {"code": "not_found", "message": "Journal entry not found"}}
+//
+// where code is stable and meant for programs, message for people.
+package api
+
+import (
+	"Remainwith/db"
+	"Remainwith/internal/handler"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Handlers serves the endpoints that need storage.
+type Handlers struct {
+	users     db.UserStore
+	journals  db.JournalStore
+	interests db.InterestStore
+	accounts  *handler.Accounts
+}
+
+// NewHandlers returns API handlers over the given stores. Login and
+// account changes go through accounts, as they do in the web app.
+func NewHandlers(users db.UserStore, journals db.JournalStore, interests db.InterestStore, accounts *handler.Accounts) *Handlers {
+	return &Handlers{users: users, journals: journals, interests: interests, accounts: accounts}
+}
+
+// maxBodyBytes caps request bodies.
+const maxBodyBytes = 1 << 20
+
+// Error codes shared across endpoints. Account changes use the codes of
+// handler.AccountError.
+const (
+	CodeBadRequest   = "bad_request"
+	CodeInvalidJSON  = "invalid_json"
+	CodeValidation   = "validation_failed"
+	CodeUnauthorized = "unauthorized"
+	CodeInvalidToken = "invalid_token"
+	CodeSuspended    = "account_suspended"
+	CodeCredentials  = "invalid_credentials"
+	CodeNotFound     = "not_found"
+	CodeInternal     = "internal_error"
+)
+
+type errorBody struct {
+	Error errorObject `json:"error"`
+}
+
+type errorObject struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// WriteError writes an error object with the given status.
+func WriteError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, errorBody{Error: errorObject{Code: code, Message: message}})
+}
+
+// writeInternal reports an unexpected failure without leaking its detail.
+func writeInternal(w http.ResponseWriter) {
+	WriteError(w, http.StatusInternalServerError, CodeInternal, "Something went wrong")
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// decodeJSON reads a single JSON object into v, rejecting unknown fields
+// so typos in client code surface early. On failure it writes the error
+// response and returns false.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err == nil && dec.Decode(&struct{}{}) != io.EOF {
+		err = errors.New("body must contain a single JSON object")
+	}
+	if err != nil {
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			WriteError(w, http.StatusRequestEntityTooLarge, CodeBadRequest, "Request body is too large")
+			return false
+		}
+		WriteError(w, http.StatusBadRequest, CodeInvalidJSON, fmt.Sprintf("Invalid JSON body: %v", err))
+		return false
+	}
+	return true
+}
+
+// BearerAuth authenticates requests with an "Authorization: Bearer" token.
+// It stands in for JWTMiddleware, so handlers read the caller with the
+// usual handler context helpers. Bearer requests carry no cookies and so
+// need no CSRF token.
+func BearerAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+			WriteError(w, http.StatusUnauthorized, CodeUnauthorized, "Missing bearer token")
+			return
+		}
+
+		ctx, err := handler.Authenticate(r.Context(), token)
+		if errors.Is(err, handler.ErrSuspended) {
+			WriteError(w, http.StatusForbidden, CodeSuspended, "This account is suspended")
+			return
+		}
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+			WriteError(w, http.StatusUnauthorized, CodeInvalidToken, "Token is invalid or has expired")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// NotFoundHandler answers unknown /api/v1 paths with a JSON error rather
+// than the HTML site's 404.
+func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	WriteError(w, http.StatusNotFound, CodeNotFound, "No such endpoint")
+}
+
+// writeAccountError maps a handler.AccountError to a 400 or 409 with its
+// code, and anything else to a 500.
+func writeAccountError(w http.ResponseWriter, err error) {
+	var accountErr *handler.AccountError
+	if !errors.As(err, &accountErr) {
+		log.Printf("API account change failed: %v", err)
+		writeInternal(w)
+		return
+	}
+	status := http.StatusBadRequest
+	if accountErr.Code == "email_taken" {
+		status = http.StatusConflict
+	}
+	WriteError(w, status, accountErr.Code, accountErr.Error())
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"Remainwith/db"
+	"Remainwith/internal/handler"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func decodeError(t *testing.T, rec *httptest.ResponseRecorder) errorObject {
+	t.Helper()
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	var body errorBody
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("error body is not JSON: %v", err)
+	}
+	return body.Error
+}
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name, body string
+		ok         bool
+	}{
+		{"valid", `{"title":"a","entry":"b"}`, true},
+		{"unknown field", `{"title":"a","body":"b"}`, false},
+		{"trailing data", `{"title":"a"} {"title":"b"}`, false},
+		{"not json", `title=a`, false},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+		var req journalRequest
+		if got := decodeJSON(rec, r, &req); got != tt.ok {
+			t.Errorf("%s: decodeJSON = %v, want %v", tt.name, got, tt.ok)
+			continue
+		}
+		if !tt.ok {
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("%s: status %d, want 400", tt.name, rec.Code)
+			}
+			if e := decodeError(t, rec); e.Code != CodeInvalidJSON || e.Message == "" {
+				t.Errorf("%s: error %+v", tt.name, e)
+			}
+		}
+	}
+}
+
+func TestBearerAuth(t *testing.T) {
+	handler.JWTKey = []byte("test-key")
+	token, _, err := handler.SignToken(&db.Userinfo{ID: 42, Name: "Ana", Email: "ana@example.com"}, "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	protected := BearerAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]int{"user_id": handler.GetUserIDFromContext(r)})
+	}))
+
+	tests := []struct {
+		name, header string
+		status       int
+		code         string
+	}{
+		{"missing", "", http.StatusUnauthorized, CodeUnauthorized},
+		{"wrong scheme", "Basic " + token, http.StatusUnauthorized, CodeUnauthorized},
+		{"garbage", "Bearer nope", http.StatusUnauthorized, CodeInvalidToken},
+		{"valid", "Bearer " + token, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/me", nil)
+		if tt.header != "" {
+			r.Header.Set("Authorization", tt.header)
+		}
+		protected.ServeHTTP(rec, r)
+
+		if rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.status)
+			continue
+		}
+		if tt.code != "" {
+			if e := decodeError(t, rec); e.Code != tt.code {
+				t.Errorf("%s: code %q, want %q", tt.name, e.Code, tt.code)
+			}
+			continue
+		}
+		var got map[string]int
+		json.NewDecoder(rec.Body).Decode(&got)
+		if got["user_id"] != 42 {
+			t.Errorf("%s: handler saw user %d, want 42", tt.name, got["user_id"])
+		}
+	}
+}
+
+func TestWriteAccountError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeAccountError(rec, &handler.AccountError{Code: "email_taken"})
+	if rec.Code != http.StatusConflict {
+		t.Errorf("status %d, want 409", rec.Code)
+	}
+	if e := decodeError(t, rec); e.Code != "email_taken" || e.Message == "" {
+		t.Errorf("error %+v", e)
+	}
+}
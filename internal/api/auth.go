@@ -0,0 +1,140 @@
+package api
+
+import (
+	"Remainwith/db"
+	"Remainwith/internal/handler"
+	"errors"
+	"log"
+	"net/http"
+	netmail "net/mail"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// minPassword is the shortest password accepted at sign-up.
+const minPassword = 8
+
+// User is the signed-in account.
+type User struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+func userFrom(u *db.Userinfo) User {
+	role := u.Role
+	if !db.ValidRole(role) {
+		role = db.RoleUser
+	}
+	return User{ID: u.ID, Name: u.Name, Email: u.Email, Role: role}
+}
+
+type tokenResponse struct {
+	Token     string    `json:"token"`
+	TokenType string    `json:"token_type"`
+	ExpiresAt time.Time `json:"expires_at"`
+	User      User      `json:"user"`
+}
+
+// SignupHandler serves POST /api/v1/auth/signup with
+// {"name", "email", "password"}. It creates the account and signs it in.
+func (h *Handlers) SignupHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name     string `json:"name"`
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	req.Email = strings.TrimSpace(req.Email)
+	switch addr, err := netmail.ParseAddress(req.Email); {
+	case req.Name == "" || utf8.RuneCountInString(req.Name) > handler.MaxDisplayName:
+		WriteError(w, http.StatusBadRequest, CodeValidation, "Name is required and can be at most 40 characters")
+		return
+	case err != nil || addr.Address != req.Email:
+		WriteError(w, http.StatusBadRequest, CodeValidation, "A valid email address is required")
+		return
+	case len(req.Password) < minPassword || len(req.Password) > 72:
+		// bcrypt ignores anything past 72 bytes
+		WriteError(w, http.StatusBadRequest, CodeValidation, "Passwords must be 8 to 72 characters")
+		return
+	}
+
+	exists, err := h.users.CheckUser(r.Context(), req.Email)
+	if err != nil {
+		log.Printf("API signup: %v", err)
+		writeInternal(w)
+		return
+	}
+	if exists {
+		WriteError(w, http.StatusConflict, "email_taken", "That email address is already in use")
+		return
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Printf("API signup: %v", err)
+		writeInternal(w)
+		return
+	}
+	if err := h.users.NewUser(r.Context(), req.Name, req.Email, string(hashed)); err != nil {
+		log.Printf("API signup: %v", err)
+		writeInternal(w)
+		return
+	}
+
+	user, err := h.users.GetUserByEmail(r.Context(), req.Email)
+	if err != nil {
+		log.Printf("API signup: %v", err)
+		writeInternal(w)
+		return
+	}
+	writeToken(w, http.StatusCreated, user)
+}
+
+// LoginHandler serves POST /api/v1/auth/login with {"email", "password"}
+// and returns a bearer token.
+func (h *Handlers) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	user, err := h.accounts.CheckLogin(r.Context(), strings.TrimSpace(req.Email), req.Password)
+	var suspended *handler.SuspendedError
+	switch {
+	case errors.As(err, &suspended):
+		WriteError(w, http.StatusForbidden, CodeSuspended, suspended.Error())
+		return
+	case err != nil:
+		WriteError(w, http.StatusUnauthorized, CodeCredentials, "Invalid email or password")
+		return
+	}
+	writeToken(w, http.StatusOK, user)
+}
+
+// writeToken signs a new session for user and writes it.
+func writeToken(w http.ResponseWriter, status int, user *db.Userinfo) {
+	sessionID, err := handler.NewSessionID()
+	if err != nil {
+		writeInternal(w)
+		return
+	}
+	token, expires, err := handler.SignToken(user, sessionID)
+	if err != nil {
+		log.Printf("API token: %v", err)
+		writeInternal(w)
+		return
+	}
+	writeJSON(w, status, tokenResponse{Token: token, TokenType: "Bearer", ExpiresAt: expires, User: userFrom(user)})
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"Remainwith/internal/openapi"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// TestResponsesMatchDocument runs the v1 endpoints through the OpenAPI
+// validator, so a handler and openapi.json drifting apart fails here: an
+// undocumented status, or a body that doesn't match its schema.
+func TestResponsesMatchDocument(t *testing.T) {
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := openapi.NewValidator(doc)
+	v.Logf = t.Errorf
+	h := v.Middleware(newTestAPI(t))
+
+	ana := signup(t, h, "ana@example.com")
+	ben := signup(t, h, "ben@example.com")
+
+	rec := call(t, h, http.MethodPost, "/api/v1/journals", ana, `{"title":"Monday","entry":"Slept well"}`)
+	var created Journal
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil || rec.Code != http.StatusCreated {
+		t.Fatalf("create: status %d, %v", rec.Code, err)
+	}
+	entry := fmt.Sprintf("/api/v1/journals/%d", created.ID)
+
+	steps := []struct {
+		method, path, token, body string
+		status                    int
+	}{
+		{http.MethodPost, "/api/v1/auth/signup", "", `{"name":"Ana","email":"ana@example.com","password":"correct horse"}`, http.StatusConflict},
+		{http.MethodPost, "/api/v1/auth/login", "", `{"email":"ana@example.com","password":"correct horse"}`, http.StatusOK},
+		{http.MethodPost, "/api/v1/auth/login", "", `{"email":"ana@example.com","password":"wrong"}`, http.StatusUnauthorized},
+		{http.MethodGet, "/api/v1/journals", "", "", http.StatusUnauthorized},
+		{http.MethodPost, "/api/v1/journals", ana, `{"title":"","entry":"x"}`, http.StatusBadRequest},
+		{http.MethodGet, "/api/v1/journals", ana, "", http.StatusOK},
+		{http.MethodGet, entry, ana, "", http.StatusOK},
+		{http.MethodPut, entry, ana, `{"title":"Monday","entry":"Slept badly"}`, http.StatusOK},
+		{http.MethodGet, entry, ben, "", http.StatusNotFound},
+		{http.MethodDelete, entry, ana, "", http.StatusNoContent},
+		{http.MethodGet, entry, ana, "", http.StatusNotFound},
+	}
+	for _, s := range steps {
+		rec := call(t, h, s.method, s.path, s.token, s.body)
+		if rec.Code != s.status {
+			t.Errorf("%s %s: status %d, want %d: %s", s.method, s.path, rec.Code, s.status, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"Remainwith/db"
+	"Remainwith/internal/handler"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+)
+
+// maxInterests is how many interests a user may pick, as in onboarding.
+const maxInterests = 5
+
+// CatalogHandler serves GET /api/v1/interests with the active interests
+// grouped by category.
+func (h *Handlers) CatalogHandler(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.interests.GetInterestCatalog(r.Context(), false)
+	if err != nil {
+		log.Printf("API interests: %v", err)
+		writeInternal(w)
+		return
+	}
+	writeJSON(w, http.StatusOK, categories)
+}
+
+// MyInterestsHandler serves GET /api/v1/me/interests.
+func (h *Handlers) MyInterestsHandler(w http.ResponseWriter, r *http.Request) {
+	h.writeMyInterests(w, r, handler.GetUserIDFromContext(r))
+}
+
+// SetMyInterestsHandler serves PUT /api/v1/me/interests with
+// {"interest_ids": [...]}, replacing the user's interests.
+func (h *Handlers) SetMyInterestsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := handler.GetUserIDFromContext(r)
+
+	var req struct {
+		InterestIDs []int `json:"interest_ids"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var ids []int
+	for _, id := range req.InterestIDs {
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) > maxInterests {
+		WriteError(w, http.StatusBadRequest, CodeValidation, fmt.Sprintf("Choose at most %d interests", maxInterests))
+		return
+	}
+
+	active, err := h.activeInterests(r)
+	if err != nil {
+		log.Printf("API interests: %v", err)
+		writeInternal(w)
+		return
+	}
+	for _, id := range ids {
+		if !active[id] {
+			WriteError(w, http.StatusBadRequest, CodeValidation, fmt.Sprintf("Unknown interest %d", id))
+			return
+		}
+	}
+
+	if err := h.interests.SaveUserInterests(r.Context(), userID, ids); err != nil {
+		log.Printf("API interests: %v", err)
+		writeInternal(w)
+		return
+	}
+	h.writeMyInterests(w, r, userID)
+}
+
+// writeMyInterests writes the user's active interests in catalog order.
+func (h *Handlers) writeMyInterests(w http.ResponseWriter, r *http.Request, userID int) {
+	ids, err := h.interests.GetUserInterestIDs(r.Context(), userID)
+	if err != nil {
+		log.Printf("API interests: %v", err)
+		writeInternal(w)
+		return
+	}
+	categories, err := h.interests.GetInterestCatalog(r.Context(), false)
+	if err != nil {
+		log.Printf("API interests: %v", err)
+		writeInternal(w)
+		return
+	}
+
+	mine := []db.Interest{}
+	for _, c := range categories {
+		for _, i := range c.Interests {
+			if slices.Contains(ids, i.ID) {
+				mine = append(mine, i)
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, mine)
+}
+
+// activeInterests returns the IDs of the active interests.
+func (h *Handlers) activeInterests(r *http.Request) (map[int]bool, error) {
+	categories, err := h.interests.GetInterestCatalog(r.Context(), false)
+	if err != nil {
+		return nil, err
+	}
+	active := make(map[int]bool)
+	for _, c := range categories {
+		for _, i := range c.Interests {
+			active[i.ID] = true
+		}
+	}
+	return active, nil
+}
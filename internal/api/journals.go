@@ -0,0 +1,169 @@
+package api
+
+import (
+	"Remainwith/db"
+	"Remainwith/internal/handler"
+	"Remainwith/internal/safety"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Journal is one journal entry.
+type Journal struct {
+	ID        int       `json:"id"`
+	Title     string    `json:"title"`
+	Entry     string    `json:"entry"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// SupportURL is set when the entry mentions a crisis, where the HTML
+	// flow would redirect to the support page.
+	SupportURL string `json:"support_url,omitempty"`
+}
+
+func journalFrom(j *db.Journal) Journal {
+	return Journal{ID: j.ID, Title: j.Title, Entry: j.Desc, CreatedAt: j.CreatedAt}
+}
+
+type journalRequest struct {
+	Title string `json:"title"`
+	Entry string `json:"entry"`
+}
+
+// valid trims the request and reports whether both fields are set,
+// writing the error response if not.
+func (req *journalRequest) valid(w http.ResponseWriter) bool {
+	req.Title = strings.TrimSpace(req.Title)
+	req.Entry = strings.TrimSpace(req.Entry)
+	if req.Title == "" || req.Entry == "" {
+		WriteError(w, http.StatusBadRequest, CodeValidation, "Title and entry are required")
+		return false
+	}
+	return true
+}
+
+// journalID reads the {id} path value, writing a 404 if it is malformed.
+func journalID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		WriteError(w, http.StatusNotFound, CodeNotFound, "Journal entry not found")
+		return 0, false
+	}
+	return id, true
+}
+
+// ListJournalsHandler serves GET /api/v1/journals, newest first.
+func (h *Handlers) ListJournalsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := handler.GetUserIDFromContext(r)
+
+	journals, err := h.journals.GetJournalsByUserID(r.Context(), userID)
+	if err != nil {
+		log.Printf("API journals: %v", err)
+		writeInternal(w)
+		return
+	}
+
+	out := make([]Journal, 0, len(journals))
+	for i := range journals {
+		out = append(out, journalFrom(&journals[i]))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// CreateJournalHandler serves POST /api/v1/journals with {"title", "entry"}.
+func (h *Handlers) CreateJournalHandler(w http.ResponseWriter, r *http.Request) {
+	userID := handler.GetUserIDFromContext(r)
+
+	var req journalRequest
+	if !decodeJSON(w, r, &req) || !req.valid(w) {
+		return
+	}
+
+	id, err := h.journals.NewJournal(r.Context(), userID, req.Title, req.Entry)
+	if err != nil {
+		log.Printf("API journals: %v", err)
+		writeInternal(w)
+		return
+	}
+	h.writeJournal(w, r, http.StatusCreated, id, userID)
+}
+
+// GetJournalHandler serves GET /api/v1/journals/{id}.
+func (h *Handlers) GetJournalHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := journalID(w, r)
+	if !ok {
+		return
+	}
+	h.writeJournal(w, r, http.StatusOK, id, handler.GetUserIDFromContext(r))
+}
+
+// UpdateJournalHandler serves PUT /api/v1/journals/{id} with
+// {"title", "entry"}.
+func (h *Handlers) UpdateJournalHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := journalID(w, r)
+	if !ok {
+		return
+	}
+	userID := handler.GetUserIDFromContext(r)
+
+	var req journalRequest
+	if !decodeJSON(w, r, &req) || !req.valid(w) {
+		return
+	}
+
+	err := h.journals.UpdateJournal(r.Context(), id, userID, req.Title, req.Entry)
+	if errors.Is(err, db.ErrJournalNotFound) {
+		WriteError(w, http.StatusNotFound, CodeNotFound, "Journal entry not found")
+		return
+	}
+	if err != nil {
+		log.Printf("API journals: %v", err)
+		writeInternal(w)
+		return
+	}
+	h.writeJournal(w, r, http.StatusOK, id, userID)
+}
+
+// DeleteJournalHandler serves DELETE /api/v1/journals/{id}.
+func (h *Handlers) DeleteJournalHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := journalID(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.journals.DeleteJournal(r.Context(), id, handler.GetUserIDFromContext(r))
+	if errors.Is(err, db.ErrJournalNotFound) {
+		WriteError(w, http.StatusNotFound, CodeNotFound, "Journal entry not found")
+		return
+	}
+	if err != nil {
+		log.Printf("API journals: %v", err)
+		writeInternal(w)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeJournal loads and writes entry id. After a write it flags crisis
+// language the way the HTML journal does.
+func (h *Handlers) writeJournal(w http.ResponseWriter, r *http.Request, status, id, userID int) {
+	j, err := h.journals.GetJournal(r.Context(), id, userID)
+	if errors.Is(err, db.ErrJournalNotFound) {
+		WriteError(w, http.StatusNotFound, CodeNotFound, "Journal entry not found")
+		return
+	}
+	if err != nil {
+		log.Printf("API journals: %v", err)
+		writeInternal(w)
+		return
+	}
+
+	out := journalFrom(j)
+	if r.Method != http.MethodGet && safety.MentionsCrisis(j.Title+"\n"+j.Desc) {
+		out.SupportURL = safety.SupportURL("journal")
+	}
+	writeJSON(w, status, out)
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"Remainwith/db/memstore"
+	"Remainwith/internal/handler"
+	"Remainwith/internal/mail"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestAPI returns a router over the v1 journal and auth endpoints,
+// backed by an in-memory store.
+func newTestAPI(t *testing.T) http.Handler {
+	t.Helper()
+	handler.JWTKey = []byte("test-key")
+	store := memstore.New()
+	h := NewHandlers(store, store, store, handler.NewAccounts(store, mail.LogSender{}))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/auth/signup", h.SignupHandler)
+	mux.HandleFunc("POST /api/v1/auth/login", h.LoginHandler)
+	mux.Handle("GET /api/v1/journals", BearerAuth(http.HandlerFunc(h.ListJournalsHandler)))
+	mux.Handle("POST /api/v1/journals", BearerAuth(http.HandlerFunc(h.CreateJournalHandler)))
+	mux.Handle("GET /api/v1/journals/{id}", BearerAuth(http.HandlerFunc(h.GetJournalHandler)))
+	mux.Handle("PUT /api/v1/journals/{id}", BearerAuth(http.HandlerFunc(h.UpdateJournalHandler)))
+	mux.Handle("DELETE /api/v1/journals/{id}", BearerAuth(http.HandlerFunc(h.DeleteJournalHandler)))
+	return mux
+}
+
+func call(t *testing.T, h http.Handler, method, path, token, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+// signup creates an account and returns its token.
+func signup(t *testing.T, h http.Handler, email string) string {
+	t.Helper()
+	rec := call(t, h, http.MethodPost, "/api/v1/auth/signup", "",
+		fmt.Sprintf(`{"name":"Ana","email":%q,"password":"correct horse"}`, email))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("signup: status %d: %s", rec.Code, rec.Body)
+	}
+	var tok tokenResponse
+	if err := json.NewDecoder(rec.Body).Decode(&tok); err != nil {
+		t.Fatal(err)
+	}
+	return tok.Token
+}
+
+func TestSignupAndLogin(t *testing.T) {
+	h := newTestAPI(t)
+	signup(t, h, "ana@example.com")
+
+	rec := call(t, h, http.MethodPost, "/api/v1/auth/signup", "", `{"name":"Ana","email":"ana@example.com","password":"correct horse"}`)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("duplicate signup: status %d, want 409", rec.Code)
+	}
+
+	rec = call(t, h, http.MethodPost, "/api/v1/auth/login", "", `{"email":"ana@example.com","password":"wrong"}`)
+	if e := decodeError(t, rec); rec.Code != http.StatusUnauthorized || e.Code != CodeCredentials {
+		t.Errorf("bad password: status %d, error %+v", rec.Code, e)
+	}
+
+	rec = call(t, h, http.MethodPost, "/api/v1/auth/login", "", `{"email":"ana@example.com","password":"correct horse"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login: status %d: %s", rec.Code, rec.Body)
+	}
+	var tok tokenResponse
+	json.NewDecoder(rec.Body).Decode(&tok)
+	if tok.Token == "" || tok.User.Email != "ana@example.com" || tok.User.Role != "user" {
+		t.Errorf("login returned %+v", tok)
+	}
+}
+
+func TestJournalLifecycle(t *testing.T) {
+	h := newTestAPI(t)
+	ana := signup(t, h, "ana@example.com")
+	ben := signup(t, h, "ben@example.com")
+
+	rec := call(t, h, http.MethodPost, "/api/v1/journals", ana, `{"title":" Monday ","entry":"Slept well"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: status %d: %s", rec.Code, rec.Body)
+	}
+	var created Journal
+	json.NewDecoder(rec.Body).Decode(&created)
+	if created.Title != "Monday" || created.Entry != "Slept well" || created.SupportURL != "" {
+		t.Errorf("created %+v", created)
+	}
+	path := fmt.Sprintf("/api/v1/journals/%d", created.ID)
+
+	if rec := call(t, h, http.MethodPost, "/api/v1/journals", ana, `{"title":"","entry":"x"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("create without title: status %d, want 400", rec.Code)
+	}
+
+	rec = call(t, h, http.MethodPut, path, ana, `{"title":"Monday","entry":"Slept badly"}`)
+	var updated Journal
+	json.NewDecoder(rec.Body).Decode(&updated)
+	if rec.Code != http.StatusOK || updated.Entry != "Slept badly" {
+		t.Errorf("update: status %d, %+v", rec.Code, updated)
+	}
+
+	rec = call(t, h, http.MethodGet, "/api/v1/journals", ana, "")
+	var list []Journal
+	json.NewDecoder(rec.Body).Decode(&list)
+	if len(list) != 1 || list[0].ID != created.ID {
+		t.Errorf("list: %+v", list)
+	}
+
+	// Other people's entries don't exist as far as the API is concerned
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		rec := call(t, h, method, path, ben, "")
+		if e := decodeError(t, rec); rec.Code != http.StatusNotFound || e.Code != CodeNotFound {
+			t.Errorf("%s as another user: status %d, error %+v", method, rec.Code, e)
+		}
+	}
+	rec = call(t, h, http.MethodGet, "/api/v1/journals", ben, "")
+	if body := strings.TrimSpace(rec.Body.String()); body != "[]" {
+		t.Errorf("another user's list = %s, want []", body)
+	}
+
+	if rec := call(t, h, http.MethodDelete, path, ana, ""); rec.Code != http.StatusNoContent {
+		t.Errorf("delete: status %d, want 204", rec.Code)
+	}
+	if rec := call(t, h, http.MethodGet, path, ana, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("get after delete: status %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"Remainwith/db"
+	"Remainwith/internal/handler"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Me is the signed-in account with its profile settings.
+type Me struct {
+	User
+	Profile Profile `json:"profile"`
+}
+
+// Profile is the editable public profile and privacy settings.
+// DisplayName repeats the account name, which is what others see, for
+// clients from before the two were merged.
+type Profile struct {
+	DisplayName        string `json:"display_name"`
+	Bio                string `json:"bio"`
+	AvatarURL          string `json:"avatar_url"`
+	HiddenInterests    []int  `json:"hidden_interests"`
+	Discoverable       bool   `json:"discoverable"`
+	AllowDMs           string `json:"allow_dms"`
+	AnonymousCampfires bool   `json:"anonymous_campfires"`
+}
+
+// MeHandler serves GET /api/v1/me.
+func (h *Handlers) MeHandler(w http.ResponseWriter, r *http.Request) {
+	h.writeMe(w, r, handler.GetUserIDFromContext(r))
+}
+
+// UpdateMeHandler serves PATCH /api/v1/me. Fields left out are unchanged.
+// A new name shows in tokens issued from the next login. display_name is
+// an older spelling of name, used when name is left out.
+func (h *Handlers) UpdateMeHandler(w http.ResponseWriter, r *http.Request) {
+	userID := handler.GetUserIDFromContext(r)
+
+	var req struct {
+		Name               *string `json:"name"`
+		Bio                *string `json:"bio"`
+		DisplayName        *string `json:"display_name"`
+		HiddenInterests    *[]int  `json:"hidden_interests"`
+		Discoverable       *bool   `json:"discoverable"`
+		AllowDMs           *string `json:"allow_dms"`
+		AnonymousCampfires *bool   `json:"anonymous_campfires"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	user, err := h.users.GetUserByID(r.Context(), userID)
+	if err != nil {
+		log.Printf("API me: %v", err)
+		writeInternal(w)
+		return
+	}
+	profile, err := db.GetProfile(r.Context(), userID)
+	if err != nil {
+		log.Printf("API me: %v", err)
+		writeInternal(w)
+		return
+	}
+
+	if req.Name == nil {
+		req.Name = req.DisplayName
+	}
+	if req.AllowDMs != nil {
+		if !db.ValidDMPolicy(*req.AllowDMs) {
+			WriteError(w, http.StatusBadRequest, CodeValidation, `allow_dms must be "everyone", "matches" or "nobody"`)
+			return
+		}
+		profile.AllowDMs = *req.AllowDMs
+	}
+	if req.Discoverable != nil {
+		profile.Discoverable = *req.Discoverable
+	}
+	if req.AnonymousCampfires != nil {
+		profile.AnonymousCampfires = *req.AnonymousCampfires
+	}
+	if req.HiddenInterests != nil {
+		// Only the user's own interests can be hidden
+		ids, err := h.interests.GetUserInterestIDs(r.Context(), userID)
+		if err != nil {
+			log.Printf("API me: %v", err)
+			writeInternal(w)
+			return
+		}
+		profile.HiddenInterests = []int{}
+		for _, id := range *req.HiddenInterests {
+			if slices.Contains(ids, id) && !slices.Contains(profile.HiddenInterests, id) {
+				profile.HiddenInterests = append(profile.HiddenInterests, id)
+			}
+		}
+	}
+
+	if req.Name != nil || req.Bio != nil {
+		name, bio := user.Name, profile.Bio
+		if req.Name != nil {
+			name = *req.Name
+		}
+		if req.Bio != nil {
+			bio = *req.Bio
+		}
+		if err := h.accounts.UpdateAccount(r.Context(), userID, name, bio); err != nil {
+			writeAccountError(w, err)
+			return
+		}
+		// SaveProfile below writes the bio too
+		profile.Bio = strings.TrimSpace(bio)
+	}
+
+	if err := db.SaveProfile(r.Context(), profile); err != nil {
+		log.Printf("API me: %v", err)
+		writeInternal(w)
+		return
+	}
+	h.writeMe(w, r, userID)
+}
+
+// ChangeEmailHandler serves POST /api/v1/me/email with
+// {"new_email", "password"}. It mails a confirmation link to the new
+// address and answers 202; the email changes once the link is opened.
+func (h *Handlers) ChangeEmailHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		NewEmail string `json:"new_email"`
+		Password string `json:"password"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	err := h.accounts.StartEmailChange(r.Context(), handler.BaseURL(), handler.GetUserIDFromContext(r), req.NewEmail, req.Password)
+	if err != nil {
+		writeAccountError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, struct {
+		PendingEmail string `json:"pending_email"`
+	}{strings.TrimSpace(req.NewEmail)})
+}
+
+func (h *Handlers) writeMe(w http.ResponseWriter, r *http.Request, userID int) {
+	user, err := h.users.GetUserByID(r.Context(), userID)
+	if err != nil {
+		log.Printf("API me: %v", err)
+		writeInternal(w)
+		return
+	}
+	p, err := db.GetProfile(r.Context(), userID)
+	if err != nil {
+		log.Printf("API me: %v", err)
+		writeInternal(w)
+		return
+	}
+
+	hidden := p.HiddenInterests
+	if hidden == nil {
+		hidden = []int{}
+	}
+	writeJSON(w, http.StatusOK, Me{
+		User: userFrom(user),
+		Profile: Profile{
+			DisplayName:        user.Name,
+			Bio:                p.Bio,
+			AvatarURL:          p.AvatarURL,
+			HiddenInterests:    hidden,
+			Discoverable:       p.Discoverable,
+			AllowDMs:           p.AllowDMs,
+			AnonymousCampfires: p.AnonymousCampfires,
+		},
+	})
+}
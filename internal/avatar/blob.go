@@ -0,0 +1,98 @@
+package avatar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrBlobNotFound is returned by BlobStore.Open for unknown keys.
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore keeps immutable blobs under slash-separated keys. Keys are
+// derived from content, so a key is never rewritten with different data.
+type BlobStore interface {
+	// Put stores data under key. Storing an existing key is a no-op.
+	Put(ctx context.Context, key string, data []byte) error
+
+	// Open returns the blob stored under key and when it was written.
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, time.Time, error)
+}
+
+// FileStore is a BlobStore on the local filesystem.
+type FileStore struct {
+	root string
+}
+
+// NewFileStore returns a store rooted at dir, creating it if needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create blob directory: %w", err)
+	}
+	return &FileStore{root: dir}, nil
+}
+
+// path maps key into the store, refusing keys that would escape it.
+func (s *FileStore) path(key string) (string, error) {
+	if !fs.ValidPath(key) || strings.Contains(key, "\\") {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+// Put writes data to a temporary file and renames it into place, so
+// readers never see a partial blob.
+func (s *FileStore) Put(ctx context.Context, key string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Open opens the file for key.
+func (s *FileStore) Open(ctx context.Context, key string) (io.ReadSeekCloser, time.Time, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, time.Time{}, ErrBlobNotFound
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, time.Time{}, ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		f.Close()
+		return nil, time.Time{}, ErrBlobNotFound
+	}
+	return f, info.ModTime(), nil
+}
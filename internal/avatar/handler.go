@@ -0,0 +1,165 @@
+package avatar
+
+import (
+	"Remainwith/db"
+	"Remainwith/internal/handler"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// URLPrefix is where avatars are served from.
+const URLPrefix = "/avatars/"
+
+// Handlers serves avatar uploads and the stored images.
+type Handlers struct {
+	store BlobStore
+}
+
+// NewHandlers returns handlers that keep avatars in store.
+func NewHandlers(store BlobStore) *Handlers {
+	return &Handlers{store: store}
+}
+
+// URL returns where the size×size rendition of the avatar with the given
+// content hash is served.
+func URL(hash string, size int) string {
+	return fmt.Sprintf("%s%s/%d.jpg", URLPrefix, hash, size)
+}
+
+type uploadResponse struct {
+	AvatarURL string         `json:"avatar_url"`
+	Sizes     map[int]string `json:"sizes"`
+}
+
+// UploadHandler serves POST /api/profile/avatar with a multipart "avatar"
+// file. The image is normalized, stored under the hash of the upload and
+// set as the caller's avatar.
+func (h *Handlers) UploadHandler(w http.ResponseWriter, r *http.Request) {
+	userID := handler.GetUserIDFromContext(r)
+	if userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Leave room for the multipart framing around the file
+	r.Body = http.MaxBytesReader(w, r.Body, MaxUploadBytes+64<<10)
+	file, _, err := r.FormFile("avatar")
+	if err != nil {
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			http.Error(w, ErrTooLarge.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Missing avatar file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, MaxUploadBytes+1))
+	if err != nil {
+		http.Error(w, "Failed to read upload", http.StatusBadRequest)
+		return
+	}
+
+	images, err := Process(data)
+	switch {
+	case errors.Is(err, ErrTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	case errors.Is(err, ErrUnsupportedType), errors.Is(err, ErrTooSmall):
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	case err != nil:
+		log.Printf("Avatar processing failed: %v", err)
+		http.Error(w, "Failed to process image", http.StatusInternalServerError)
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	resp := uploadResponse{Sizes: make(map[int]string, len(images))}
+	for size, img := range images {
+		if err := h.store.Put(r.Context(), fmt.Sprintf("%s/%d.jpg", hash, size), img); err != nil {
+			log.Printf("Avatar store failed: %v", err)
+			http.Error(w, "Failed to store image", http.StatusInternalServerError)
+			return
+		}
+		resp.Sizes[size] = URL(hash, size)
+	}
+	resp.AvatarURL = URL(hash, Sizes[0])
+
+	if err := db.SetAvatar(r.Context(), userID, resp.AvatarURL); err != nil {
+		log.Printf("SetAvatar: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// DeleteHandler serves DELETE /api/profile/avatar, going back to initials.
+// The stored images are left in place since other uploads may share them.
+func (h *Handlers) DeleteHandler(w http.ResponseWriter, r *http.Request) {
+	userID := handler.GetUserIDFromContext(r)
+	if userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := db.SetAvatar(r.Context(), userID, ""); err != nil {
+		log.Printf("SetAvatar: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ServeHandler serves GET /avatars/{hash}/{file}. Paths name their content,
+// so responses may be cached forever.
+func (h *Handlers) ServeHandler(w http.ResponseWriter, r *http.Request) {
+	hash, file := r.PathValue("hash"), r.PathValue("file")
+	size, err := strconv.Atoi(strings.TrimSuffix(file, ".jpg"))
+	if !validHash(hash) || !strings.HasSuffix(file, ".jpg") || err != nil || !slices.Contains(Sizes, size) {
+		http.NotFound(w, r)
+		return
+	}
+
+	blob, modTime, err := h.store.Open(r.Context(), hash+"/"+file)
+	if errors.Is(err, ErrBlobNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Avatar open failed: %v", err)
+		http.Error(w, "Failed to read image", http.StatusInternalServerError)
+		return
+	}
+	defer blob.Close()
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, file, modTime, blob)
+}
+
+// validHash reports whether s is a lowercase hex SHA-256.
+func validHash(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
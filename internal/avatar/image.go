@@ -0,0 +1,239 @@
+// Package avatar accepts profile picture uploads, normalizes them into a
+// few square JPEG sizes and serves them from a BlobStore.
+package avatar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"net/http"
+
+	// Registered for image.Decode
+	_ "image/gif"
+	_ "image/png"
+)
+
+// Upload limits.
+const (
+	MaxUploadBytes = 5 << 20
+	maxPixels      = 40_000_000 // guards against decompression bombs
+	minSide        = 64
+	jpegQuality    = 85
+)
+
+// Sizes are the square edge lengths every avatar is stored at.
+var Sizes = []int{256, 128, 64}
+
+// allowedTypes are the sniffed content types accepted for upload.
+var allowedTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+// Upload errors, shown to the user as is.
+var (
+	ErrUnsupportedType = errors.New("please upload a JPEG, PNG or GIF image")
+	ErrTooLarge        = fmt.Errorf("images must be smaller than %d MB", MaxUploadBytes>>20)
+	ErrTooSmall        = fmt.Errorf("images must be at least %d×%d pixels", minSide, minSide)
+)
+
+// Process decodes an uploaded image, applies its EXIF orientation, crops
+// it to a centered square and encodes it as JPEG at each of Sizes.
+// Re-encoding drops all metadata, EXIF location included.
+func Process(data []byte) (map[int][]byte, error) {
+	if len(data) > MaxUploadBytes {
+		return nil, ErrTooLarge
+	}
+	if !allowedTypes[http.DetectContentType(data)] {
+		return nil, ErrUnsupportedType
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+	if cfg.Width < minSide || cfg.Height < minSide {
+		return nil, ErrTooSmall
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, ErrTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+
+	square := cropSquare(orient(flatten(src), exifOrientation(data)))
+
+	out := make(map[int][]byte, len(Sizes))
+	for _, size := range Sizes {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, resize(square, size), &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, fmt.Errorf("failed to encode avatar: %w", err)
+		}
+		out[size] = buf.Bytes()
+	}
+	return out, nil
+}
+
+// flatten draws src onto white, so transparent areas don't turn black in
+// the JPEG output.
+func flatten(src image.Image) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Over)
+	return dst
+}
+
+// cropSquare returns the largest centered square of src.
+func cropSquare(src *image.RGBA) *image.RGBA {
+	b := src.Bounds()
+	side := min(b.Dx(), b.Dy())
+	x0 := b.Min.X + (b.Dx()-side)/2
+	y0 := b.Min.Y + (b.Dy()-side)/2
+	return src.SubImage(image.Rect(x0, y0, x0+side, y0+side)).(*image.RGBA)
+}
+
+// resize scales a square image to size×size by averaging the source
+// pixels under each destination pixel.
+func resize(src *image.RGBA, size int) *image.RGBA {
+	b := src.Bounds()
+	side := b.Dx()
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+
+	for y := 0; y < size; y++ {
+		sy0 := y * side / size
+		sy1 := max((y+1)*side/size, sy0+1)
+		for x := 0; x < size; x++ {
+			sx0 := x * side / size
+			sx1 := max((x+1)*side/size, sx0+1)
+
+			var r, g, bl, a, n uint32
+			for sy := sy0; sy < sy1; sy++ {
+				i := src.PixOffset(b.Min.X+sx0, b.Min.Y+sy)
+				for sx := sx0; sx < sx1; sx++ {
+					r += uint32(src.Pix[i])
+					g += uint32(src.Pix[i+1])
+					bl += uint32(src.Pix[i+2])
+					a += uint32(src.Pix[i+3])
+					n++
+					i += 4
+				}
+			}
+			o := dst.PixOffset(x, y)
+			dst.Pix[o] = uint8(r / n)
+			dst.Pix[o+1] = uint8(g / n)
+			dst.Pix[o+2] = uint8(bl / n)
+			dst.Pix[o+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// orient rotates and flips src so it displays upright for the given EXIF
+// orientation (1-8). Other values leave it unchanged.
+func orient(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if orientation >= 5 {
+		w, h = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored
+				dx, dy = b.Dx()-1-x, y
+			case 3: // rotated 180°
+				dx, dy = b.Dx()-1-x, b.Dy()-1-y
+			case 4: // mirrored vertically
+				dx, dy = x, b.Dy()-1-y
+			case 5: // mirrored along the main diagonal
+				dx, dy = y, x
+			case 6: // rotated 90° clockwise
+				dx, dy = b.Dy()-1-y, x
+			case 7: // mirrored along the anti-diagonal
+				dx, dy = b.Dy()-1-y, b.Dx()-1-x
+			case 8: // rotated 90° counter-clockwise
+				dx, dy = y, b.Dx()-1-x
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):][:4], src.Pix[src.PixOffset(b.Min.X+x, b.Min.Y+y):][:4])
+		}
+	}
+	return dst
+}
+
+// exifOrientation returns the orientation tag from a JPEG's EXIF block, or
+// 1 if there is none.
+func exifOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	// Walk the markers up to the start of scan looking for APP1 Exif
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 {
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return 1
+		}
+		segment := data[i+4 : end]
+		if marker == 0xE1 && len(segment) > 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(segment[6:])
+		}
+		i = end
+	}
+	return 1
+}
+
+// tiffOrientation reads tag 0x0112 from the first IFD of a TIFF block.
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for n := 0; n < count; n++ {
+		entry := ifd + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+	return 1
+}
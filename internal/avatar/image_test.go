@@ -0,0 +1,183 @@
+package avatar
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"testing"
+)
+
+// withOrientation inserts an APP1 Exif segment carrying the orientation tag
+// right after the SOI marker of a JPEG.
+func withOrientation(t *testing.T, data []byte, orientation uint16) []byte {
+	t.Helper()
+	var tiff bytes.Buffer
+	tiff.WriteString("MM")
+	binary.Write(&tiff, binary.BigEndian, uint16(42))
+	binary.Write(&tiff, binary.BigEndian, uint32(8))
+	binary.Write(&tiff, binary.BigEndian, uint16(1))
+	binary.Write(&tiff, binary.BigEndian, []uint16{0x0112, 3})
+	binary.Write(&tiff, binary.BigEndian, uint32(1))
+	binary.Write(&tiff, binary.BigEndian, []uint16{orientation, 0})
+	binary.Write(&tiff, binary.BigEndian, uint32(0))
+
+	segment := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	var out bytes.Buffer
+	out.Write(data[:2])
+	out.Write([]byte{0xFF, 0xE1})
+	binary.Write(&out, binary.BigEndian, uint16(len(segment)+2))
+	out.Write(segment)
+	out.Write(data[2:])
+	return out.Bytes()
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExifOrientation(t *testing.T) {
+	plain := encodeJPEG(t, image.NewRGBA(image.Rect(0, 0, 8, 8)))
+	if got := exifOrientation(plain); got != 1 {
+		t.Errorf("no exif: got %d, want 1", got)
+	}
+	if got := exifOrientation(withOrientation(t, plain, 6)); got != 6 {
+		t.Errorf("got %d, want 6", got)
+	}
+	if got := exifOrientation([]byte("not a jpeg")); got != 1 {
+		t.Errorf("garbage: got %d, want 1", got)
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// 2×1 image: red on the left, blue on the right
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	src.Set(0, 0, red)
+	src.Set(1, 0, blue)
+
+	tests := []struct {
+		orientation int
+		w, h        int
+		top         color.RGBA // pixel at (0, 0) afterwards
+	}{
+		{1, 2, 1, red},
+		{2, 2, 1, blue},
+		{3, 2, 1, blue},
+		{6, 1, 2, red},
+		{8, 1, 2, blue},
+	}
+	for _, tt := range tests {
+		got := orient(src, tt.orientation)
+		if got.Bounds().Dx() != tt.w || got.Bounds().Dy() != tt.h {
+			t.Errorf("orientation %d: size %v, want %dx%d", tt.orientation, got.Bounds().Size(), tt.w, tt.h)
+			continue
+		}
+		if c := got.RGBAAt(0, 0); c != tt.top {
+			t.Errorf("orientation %d: top-left %v, want %v", tt.orientation, c, tt.top)
+		}
+	}
+}
+
+func TestCropAndResize(t *testing.T) {
+	// 300×100 with a white centre third between black sides
+	src := image.NewRGBA(image.Rect(0, 0, 300, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 300; x++ {
+			c := color.RGBA{0, 0, 0, 255}
+			if x >= 100 && x < 200 {
+				c = color.RGBA{255, 255, 255, 255}
+			}
+			src.SetRGBA(x, y, c)
+		}
+	}
+
+	square := cropSquare(src)
+	if b := square.Bounds(); b.Dx() != 100 || b.Dy() != 100 {
+		t.Fatalf("crop size %v, want 100x100", b.Size())
+	}
+
+	small := resize(square, 10)
+	if b := small.Bounds(); b.Dx() != 10 || b.Dy() != 10 {
+		t.Fatalf("resize size %v, want 10x10", b.Size())
+	}
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			if c := small.RGBAAt(x, y); c.R != 255 {
+				t.Fatalf("pixel (%d,%d) = %v, want white", x, y, c)
+			}
+		}
+	}
+}
+
+func TestProcess(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	out, err := Process(withOrientation(t, encodeJPEG(t, src), 6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range Sizes {
+		data, ok := out[size]
+		if !ok {
+			t.Fatalf("missing size %d", size)
+		}
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+		if err != nil || cfg.Width != size || cfg.Height != size {
+			t.Errorf("size %d: got %dx%d (%v)", size, cfg.Width, cfg.Height, err)
+		}
+		if bytes.Contains(data, []byte("Exif")) {
+			t.Errorf("size %d: EXIF survived re-encoding", size)
+		}
+	}
+
+	var tiny bytes.Buffer
+	png.Encode(&tiny, image.NewRGBA(image.Rect(0, 0, 10, 10)))
+	if _, err := Process(tiny.Bytes()); !errors.Is(err, ErrTooSmall) {
+		t.Errorf("tiny image: got %v, want ErrTooSmall", err)
+	}
+	if _, err := Process([]byte("<svg xmlns='http://www.w3.org/2000/svg'/>")); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("svg: got %v, want ErrUnsupportedType", err)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "abc/64.jpg", []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	// Content addressed: a second Put of the same key keeps the original
+	if err := store.Put(ctx, "abc/64.jpg", []byte("second")); err != nil {
+		t.Fatal(err)
+	}
+	f, _, err := store.Open(ctx, "abc/64.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(f)
+	f.Close()
+	if string(data) != "first" {
+		t.Errorf("got %q, want %q", data, "first")
+	}
+
+	if _, _, err := store.Open(ctx, "abc/128.jpg"); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("missing key: got %v, want ErrBlobNotFound", err)
+	}
+	if err := store.Put(ctx, "../escape", []byte("x")); err == nil {
+		t.Error("Put accepted a key outside the store")
+	}
+}
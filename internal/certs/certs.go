@@ -0,0 +1,117 @@
+// Package certs serves a TLS certificate from files on disk and picks up
+// renewals without a restart.
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Reloader holds the certificate loaded from a cert and key file pair.
+// Watch reloads it when either file changes; until a new pair loads
+// cleanly the previous certificate keeps being served, so a renewal
+// caught halfway through writing its files does no harm.
+type Reloader struct {
+	certFile string
+	keyFile  string
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+	// loaded is the newer of the two files' modification times when cert
+	// was read
+	loaded time.Time
+
+	// logf controls where logs are sent.
+	// Defaults to log.Printf.
+	logf func(f string, v ...any)
+}
+
+// New loads the certificate, failing if the pair can't be used.
+func New(certFile, keyFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile, logf: log.Printf}
+	if _, err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate is for tls.Config.GetCertificate.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// TLSConfig returns a server config that always presents the current
+// certificate.
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: r.GetCertificate,
+	}
+}
+
+// Watch checks the files every interval until ctx is done.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			changed, err := r.reload()
+			if err != nil {
+				r.logf("Keeping current TLS certificate: %v", err)
+			} else if changed {
+				r.logf("Reloaded TLS certificate from %s", r.certFile)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// reload reads the pair again if either file is newer than the loaded
+// certificate, and reports whether it did.
+func (r *Reloader) reload() (bool, error) {
+	modified, err := r.modTime()
+	if err != nil {
+		return false, err
+	}
+	r.mu.RLock()
+	current := !r.loaded.IsZero() && !modified.After(r.loaded)
+	r.mu.RUnlock()
+	if current {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("loading TLS certificate: %w", err)
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.loaded = modified
+	r.mu.Unlock()
+	return true, nil
+}
+
+// modTime is the newer of the two files' modification times.
+func (r *Reloader) modTime() (time.Time, error) {
+	var newest time.Time
+	for _, name := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+	}
+	return newest, nil
+}
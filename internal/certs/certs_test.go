@@ -0,0 +1,111 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writePair writes a self-signed certificate for name, with both files
+// stamped modified at mtime.
+func writePair(t *testing.T, dir, name string, mtime time.Time) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	files := map[string][]byte{
+		certFile: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyFile:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+	for path, data := range files {
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return certFile, keyFile
+}
+
+func servedName(t *testing.T, r *Reloader) string {
+	t.Helper()
+	cert, err := r.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Now().Add(-time.Hour)
+	certFile, keyFile := writePair(t, dir, "old.example", start)
+
+	r, err := New(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.logf = t.Logf
+
+	if changed, err := r.reload(); changed || err != nil {
+		t.Errorf("unchanged files: reload = %v, %v", changed, err)
+	}
+
+	// A renewal caught with only the certificate written keeps the old pair
+	if err := os.WriteFile(keyFile, []byte("half written"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.reload(); err == nil {
+		t.Error("broken key loaded without error")
+	}
+	if name := servedName(t, r); name != "old.example" {
+		t.Errorf("serving %s after a failed reload", name)
+	}
+
+	writePair(t, dir, "new.example", start.Add(time.Minute))
+	if changed, err := r.reload(); !changed || err != nil {
+		t.Fatalf("renewed files: reload = %v, %v", changed, err)
+	}
+	if name := servedName(t, r); name != "new.example" {
+		t.Errorf("serving %s after renewal", name)
+	}
+}
+
+func TestNewRejectsBadPair(t *testing.T) {
+	dir := t.TempDir()
+	certFile, _ := writePair(t, dir, "a.example", time.Now())
+	if _, err := New(certFile, filepath.Join(dir, "missing.pem")); err == nil {
+		t.Error("missing key accepted")
+	}
+}
@@ -1,62 +1,437 @@
 package chat
 
 import (
+	"Remainwith/db"
+	"Remainwith/internal/handler"
+	"Remainwith/internal/models"
+	"Remainwith/internal/ws"
 	"context"
-	"html/template"
+	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+)
+
+// CampfireRoomPrefix namespaces campfires inside the websocket hub.
+const CampfireRoomPrefix = "campfire:"
 
-	"github.com/coder/websocket"
-	"github.com/coder/websocket/wsjson"
+const (
+	defaultCampfireCapacity = 12
+	minCampfireCapacity     = 2
+	maxCampfireCapacity     = 50
+	maxTopicLength          = 120
 )
 
-func CampfirePageHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.NotFound(w, r)
+// Campfires runs the lifecycle of user-created campfires: it mirrors them
+// into hub rooms, opens scheduled ones on time and closes idle ones.
+type Campfires struct {
+	// idleTimeout closes open campfires that have had no messages for
+	// this long.
+	//
+	// Defaults to 30 minutes.
+	idleTimeout time.Duration
+
+	// touchEvery throttles how often activity is written to the database
+	// for a single campfire.
+	//
+	// Defaults to 30 seconds.
+	touchEvery time.Duration
+
+	hub *ws.Hub
+
+	touchedMu sync.Mutex
+	touched   map[int]time.Time
+}
+
+// NewCampfires constructs the campfire service with the defaults and starts
+// its lifecycle loop.
+func NewCampfires(hub *ws.Hub) *Campfires {
+	cf := &Campfires{
+		idleTimeout: 30 * time.Minute,
+		touchEvery:  30 * time.Second,
+		hub:         hub,
+		touched:     make(map[int]time.Time),
+	}
+	hub.OnRoomMessage(cf.onMessage)
+	go cf.run()
+	return cf
+}
+
+// HubRoom is the name of the hub room backing campfire id.
+func HubRoom(id int) string {
+	return CampfireRoomPrefix + strconv.Itoa(id)
+}
+
+// Restore configures hub rooms for every campfire that is not closed, so
+// they survive a server restart.
+func (cf *Campfires) Restore(ctx context.Context) error {
+	campfires, err := db.ListCampfires(ctx, 0)
+	if err != nil {
+		return err
+	}
+	for _, c := range campfires {
+		cf.configure(c)
+	}
+	return nil
+}
+
+// configure mirrors a campfire's state into its hub room.
+func (cf *Campfires) configure(c db.Campfire) {
+	cf.hub.ConfigureRoom(HubRoom(c.ID), ws.RoomOptions{
+		Capacity: c.MaxParticipants,
+		HostID:   strconv.Itoa(c.HostID),
+		Closed:   c.Status != db.CampfireOpen,
+
+		AllowAnonymous: true,
+	})
+}
+
+// onMessage records activity for campfire rooms, at most once per
+// touchEvery per campfire.
+func (cf *Campfires) onMessage(room string, msg models.Message) {
+	idStr, ok := strings.CutPrefix(room, CampfireRoomPrefix)
+	if !ok {
 		return
 	}
-	tmpl, err := template.ParseFiles("frontend/campfire.tmpl")
+	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "issue faced for parsing about", http.StatusInternalServerError)
 		return
 	}
 
-	tmpl.Execute(w, nil)
+	cf.touchedMu.Lock()
+	last := cf.touched[id]
+	if msg.CreatedAt.Sub(last) < cf.touchEvery {
+		cf.touchedMu.Unlock()
+		return
+	}
+	cf.touched[id] = msg.CreatedAt
+	cf.touchedMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := db.TouchCampfire(ctx, id, msg.CreatedAt); err != nil {
+		log.Printf("Campfire %d: failed to record activity: %v", id, err)
+	}
 }
 
-func campfireHandler(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+// tick opens campfires that are due and closes idle ones.
+func (cf *Campfires) tick(now time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	c, _, err := websocket.Dial(ctx, "ws://localhost:8080/ws", nil)
+	opened, err := db.OpenDueCampfires(ctx, now)
+	if err != nil {
+		log.Printf("Campfires: failed to open scheduled campfires: %v", err)
+	}
+	for _, id := range opened {
+		c, err := db.GetCampfire(ctx, id)
+		if err != nil {
+			log.Printf("Campfire %d: failed to load: %v", id, err)
+			continue
+		}
+		cf.configure(*c)
+		log.Printf("Campfire %d opened", id)
+	}
+
+	closed, err := db.CloseIdleCampfires(ctx, now.Add(-cf.idleTimeout))
 	if err != nil {
-		log.Printf("Failed to connect to websocket: %v", err)
-		http.Error(w, "Failed to connect to chat", http.StatusInternalServerError)
+		log.Printf("Campfires: failed to close idle campfires: %v", err)
+	}
+	for _, id := range closed {
+		// Someone is still sitting there quietly; give them another round
+		if cf.hub.ConnectedUsers(HubRoom(id)) > 0 {
+			if err := db.ReopenCampfire(ctx, id, now); err != nil {
+				log.Printf("Campfire %d: failed to keep open: %v", id, err)
+			}
+			continue
+		}
+		cf.forget(id)
+		cf.hub.CloseRoom(HubRoom(id), "This campfire has closed after a quiet spell.")
+		log.Printf("Campfire %d closed after inactivity", id)
+	}
+}
+
+// Close shuts a campfire down immediately.
+func (cf *Campfires) Close(ctx context.Context, id int, reason string) error {
+	if err := db.CloseCampfire(ctx, id); err != nil {
+		return err
+	}
+	cf.forget(id)
+	cf.hub.CloseRoom(HubRoom(id), reason)
+	return nil
+}
+
+func (cf *Campfires) forget(id int) {
+	cf.touchedMu.Lock()
+	delete(cf.touched, id)
+	cf.touchedMu.Unlock()
+}
+
+func (cf *Campfires) run() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		cf.tick(now)
+	}
+}
+
+// campfireRequest is the body accepted when creating a campfire.
+type campfireRequest struct {
+	Topic           string `json:"topic"`
+	InterestID      int    `json:"interest_id"`
+	StartsAt        string `json:"starts_at"` // RFC 3339; empty starts now
+	MaxParticipants int    `json:"max_participants"`
+}
+
+// toCampfire validates req and converts it into a campfire hosted by hostID.
+// Form submissions use datetime-local, which is parsed in loc.
+func (req campfireRequest) toCampfire(hostID int, loc *time.Location) (*db.Campfire, string) {
+	topic := strings.TrimSpace(req.Topic)
+	if topic == "" {
+		return nil, "A topic is required"
+	}
+	if len([]rune(topic)) > maxTopicLength {
+		return nil, "Topic is too long"
+	}
+
+	capacity := req.MaxParticipants
+	if capacity == 0 {
+		capacity = defaultCampfireCapacity
+	}
+	if capacity < minCampfireCapacity || capacity > maxCampfireCapacity {
+		return nil, "Participants must be between 2 and 50"
+	}
+
+	var startsAt time.Time
+	if req.StartsAt != "" {
+		var err error
+		startsAt, err = time.Parse(time.RFC3339, req.StartsAt)
+		if err != nil {
+			startsAt, err = time.ParseInLocation("2006-01-02T15:04", req.StartsAt, loc)
+		}
+		if err != nil {
+			return nil, "Invalid start time"
+		}
+	}
+
+	return &db.Campfire{
+		Topic:           topic,
+		InterestID:      req.InterestID,
+		HostID:          hostID,
+		StartsAt:        startsAt,
+		MaxParticipants: capacity,
+	}, ""
+}
+
+// errUnknownInterest is returned by create for an interest that doesn't
+// exist or has been hidden.
+var errUnknownInterest = errors.New("unknown interest")
+
+// create stores a campfire and mirrors it into the hub.
+func (cf *Campfires) create(ctx context.Context, c *db.Campfire) error {
+	if c.InterestID != 0 {
+		interest, err := db.GetInterest(ctx, c.InterestID)
+		if errors.Is(err, db.ErrInterestNotFound) || err == nil && !interest.Active {
+			return errUnknownInterest
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := db.NewCampfire(ctx, c); err != nil {
+		return err
+	}
+	cf.configure(*c)
+	return nil
+}
+
+// hideAnonymousHost shows the host of c under their campfire alias, and
+// without their user ID, if they chose to be anonymous in campfires.
+func (cf *Campfires) hideAnonymousHost(c *db.Campfire) {
+	if !c.HostAnonymous {
 		return
 	}
-	defer c.Close(websocket.StatusNormalClosure, "")
+	c.HostName = cf.hub.AnonymousName(strconv.Itoa(c.HostID), HubRoom(c.ID))
+	c.HostID = 0
+}
 
-	// Send a test message
-	testMsg := map[string]interface{}{
-		"senderID":   "system",
-		"receiverID": "all",
-		"content":    "Welcome to the campfire chat!",
+// campfireListing is a campfire plus how many people are in it right now.
+type campfireListing struct {
+	db.Campfire
+	Participants int `json:"participants"`
+}
+
+// ListHandler serves GET /api/campfires with campfires that are open or
+// scheduled.
+func (cf *Campfires) ListHandler(w http.ResponseWriter, r *http.Request) {
+	campfires, err := db.ListCampfires(r.Context(), 50)
+	if err != nil {
+		log.Printf("ListCampfires: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	listings := make([]campfireListing, 0, len(campfires))
+	for _, c := range campfires {
+		cf.hideAnonymousHost(&c)
+		listings = append(listings, campfireListing{c, cf.hub.ConnectedUsers(HubRoom(c.ID))})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(listings)
+}
+
+// CreateHandler serves POST /api/campfires.
+func (cf *Campfires) CreateHandler(w http.ResponseWriter, r *http.Request) {
+	userID := handler.GetUserIDFromContext(r)
+	if userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req campfireRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	c, problem := req.toCampfire(userID, time.Local)
+	if problem != "" {
+		http.Error(w, problem, http.StatusBadRequest)
+		return
+	}
+	switch err := cf.create(r.Context(), c); {
+	case errors.Is(err, errUnknownInterest):
+		http.Error(w, "Unknown interest", http.StatusBadRequest)
+		return
+	case err != nil:
+		log.Printf("CreateCampfire: %v", err)
+		http.Error(w, "Failed to create campfire", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/campfire/"+strconv.Itoa(c.ID))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(c)
+}
+
+// NewPageHandler renders the form for starting a campfire.
+func (cf *Campfires) NewPageHandler(w http.ResponseWriter, r *http.Request) {
+	cf.renderNewPage(w, r, "")
+}
+
+func (cf *Campfires) renderNewPage(w http.ResponseWriter, r *http.Request, problem string) {
+	interests, err := db.GetAllInterests(r.Context())
+	if err != nil {
+		log.Printf("NewCampfirePage: failed to get interests: %v", err)
+	}
+
+	data := struct {
+		handler.Page
+		Error     string
+		Interests []db.Interest
+	}{
+		Error:     problem,
+		Interests: interests,
+	}
+
+	handler.Render(w, r, http.StatusOK, "campfire_new", &data)
+}
+
+// CreateFormHandler handles the new campfire form and redirects into it.
+func (cf *Campfires) CreateFormHandler(w http.ResponseWriter, r *http.Request) {
+	userID := handler.GetUserIDFromContext(r)
+	if userID == 0 {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Unable to parse form", http.StatusBadRequest)
+		return
+	}
+
+	interestID, _ := strconv.Atoi(r.FormValue("interest_id"))
+	capacity, _ := strconv.Atoi(r.FormValue("max_participants"))
+	req := campfireRequest{
+		Topic:           r.FormValue("topic"),
+		InterestID:      interestID,
+		StartsAt:        r.FormValue("starts_at"),
+		MaxParticipants: capacity,
+	}
+
+	c, problem := req.toCampfire(userID, time.Local)
+	if problem != "" {
+		cf.renderNewPage(w, r, problem)
+		return
+	}
+	switch err := cf.create(r.Context(), c); {
+	case errors.Is(err, errUnknownInterest):
+		cf.renderNewPage(w, r, "Choose an interest from the list")
+		return
+	case err != nil:
+		log.Printf("CreateCampfire: %v", err)
+		cf.renderNewPage(w, r, "We couldn't start your campfire. Please try again.")
+		return
 	}
 
-	err = wsjson.Write(ctx, c, testMsg)
+	http.Redirect(w, r, "/campfire/"+strconv.Itoa(c.ID), http.StatusSeeOther)
+}
+
+// RoomHandler renders a campfire's chat room.
+func (cf *Campfires) RoomHandler(w http.ResponseWriter, r *http.Request) {
+	userID := handler.GetUserIDFromContext(r)
+	if userID == 0 {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		log.Printf("Failed to send message: %v", err)
+		http.NotFound(w, r)
 		return
 	}
 
-	// Read response
-	var response interface{}
-	err = wsjson.Read(ctx, c, &response)
+	c, err := db.GetCampfire(r.Context(), id)
 	if err != nil {
-		log.Printf("Failed to read response: %v", err)
+		http.NotFound(w, r)
 		return
 	}
 
-	log.Printf("Received response: %v", response)
+	isHost := c.HostID == userID
+	cf.hideAnonymousHost(c)
+	data := ChatPageData{
+		CurrentUserID: userID,
+		Room:          HubRoom(c.ID),
+		Title:         c.Topic,
+		Subtitle:      "Hosted by " + c.HostName,
+		HostID:        c.HostID,
+		IsHost:        isHost,
+	}
+	if c.Interest != "" {
+		data.Subtitle += " · " + c.Interest
+	}
+	switch c.Status {
+	case db.CampfireScheduled:
+		data.Scheduled = true
+		data.Notice = "This campfire starts " + c.StartsAt.Format("Mon Jan 2 at 15:04") + ". Stay here and it will open on its own."
+	case db.CampfireClosed:
+		data.Closed = true
+		data.Notice = "This campfire has closed."
+	}
+
+	renderChat(w, r, data)
+}
+
+func CampfirePageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+	handler.Render(w, r, http.StatusOK, "campfire", nil)
 }
@@ -0,0 +1,41 @@
+package chat
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCampfireRequestValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     campfireRequest
+		problem string
+	}{
+		{"defaults", campfireRequest{Topic: "  Exam week  "}, ""},
+		{"missing topic", campfireRequest{Topic: "   "}, "A topic is required"},
+		{"long topic", campfireRequest{Topic: strings.Repeat("a", maxTopicLength+1)}, "Topic is too long"},
+		{"too small", campfireRequest{Topic: "x", MaxParticipants: 1}, "Participants must be between 2 and 50"},
+		{"too large", campfireRequest{Topic: "x", MaxParticipants: 51}, "Participants must be between 2 and 50"},
+		{"rfc3339 start", campfireRequest{Topic: "x", StartsAt: "2030-01-02T15:04:05Z"}, ""},
+		{"form start", campfireRequest{Topic: "x", StartsAt: "2030-01-02T15:04"}, ""},
+		{"bad start", campfireRequest{Topic: "x", StartsAt: "tomorrow"}, "Invalid start time"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, problem := tt.req.toCampfire(7, time.UTC)
+			if problem != tt.problem {
+				t.Fatalf("problem = %q, want %q", problem, tt.problem)
+			}
+			if problem != "" {
+				return
+			}
+			if c.HostID != 7 || c.Topic != strings.TrimSpace(tt.req.Topic) {
+				t.Fatalf("got host %d topic %q", c.HostID, c.Topic)
+			}
+			if tt.req.MaxParticipants == 0 && c.MaxParticipants != defaultCampfireCapacity {
+				t.Fatalf("capacity = %d, want default %d", c.MaxParticipants, defaultCampfireCapacity)
+			}
+		})
+	}
+}
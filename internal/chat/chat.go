@@ -1,15 +1,33 @@
 package chat
 
 import (
-	"Remainwith/db"
 	"Remainwith/internal/handler"
-	"html/template"
+	"Remainwith/internal/match"
 	"log"
 	"net/http"
 )
 
 type ChatPageData struct {
-	SuggestedUsers []db.Userinfo
+	handler.Page
+
+	SuggestedUsers []match.Suggestion
+	CurrentUserID  int
+
+	// Room is the hub room to join; empty joins the default lobby.
+	Room     string
+	Title    string
+	Subtitle string
+
+	// HostID marks the host's messages; it is left out when the host is
+	// anonymous. IsHost shows the viewer the host's moderation tools.
+	HostID int
+	IsHost bool
+
+	// Notice is shown above the messages, e.g. for scheduled or closed
+	// campfires. Closed rooms do not connect at all.
+	Notice    string
+	Scheduled bool
+	Closed    bool
 }
 
 func ChatPageHandler(w http.ResponseWriter, r *http.Request) {
@@ -25,7 +43,7 @@ func ChatPageHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	suggestedUsers, err := db.GetSuggestedUsers(r.Context(), userID, 10)
+	suggestedUsers, err := match.Suggest(r.Context(), userID, 10)
 	if err != nil {
 		log.Printf("ChatPage: Failed to get suggested users for user %d: %v", userID, err)
 		http.Error(w, "Database error", http.StatusInternalServerError)
@@ -34,17 +52,15 @@ func ChatPageHandler(w http.ResponseWriter, r *http.Request) {
 
 	data := ChatPageData{
 		SuggestedUsers: suggestedUsers,
+		CurrentUserID:  userID,
+		Title:          "Campfire Chat",
+		Subtitle:       "Connect with like-minded people",
 	}
 
-	tmpl, err := template.ParseFiles("frontend/chat.tmpl")
-	if err != nil {
-		http.Error(w, "issue faced for parsing about", http.StatusInternalServerError)
-		return
-	}
-
-	tmpl.Execute(w, data)
+	renderChat(w, r, data)
 }
 
-func ChatHandler(w http.ResponseWriter, r *http.Request) {
-
+// renderChat renders chat.tmpl for a room.
+func renderChat(w http.ResponseWriter, r *http.Request, data ChatPageData) {
+	handler.Render(w, r, http.StatusOK, "chat", &data)
 }
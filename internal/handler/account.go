@@ -0,0 +1,241 @@
+package handler
+
+import (
+	"Remainwith/db"
+	"Remainwith/internal/mail"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	netmail "net/mail"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// emailChangeTTL is how long an email confirmation link stays valid.
+const emailChangeTTL = 24 * time.Hour
+
+// Accounts serves sign-up, login and account changes.
+type Accounts struct {
+	users  db.UserStore
+	mailer mail.Sender
+}
+
+// NewAccounts returns account handlers that keep accounts in users and
+// send account emails through mailer.
+func NewAccounts(users db.UserStore, mailer mail.Sender) *Accounts {
+	return &Accounts{users: users, mailer: mailer}
+}
+
+// profileErrors are the messages for the error codes account handlers
+// redirect back to the profile page with.
+var profileErrors = map[string]string{
+	"name_required":  "Please enter a name.",
+	"name_long":      fmt.Sprintf("Names can be at most %d characters.", MaxDisplayName),
+	"bio_long":       fmt.Sprintf("Your bio can be at most %d characters.", MaxBio),
+	"email_invalid":  "Please enter a valid email address.",
+	"email_same":     "That's already your email address.",
+	"email_taken":    "That email address is already in use.",
+	"password":       "Your password was incorrect.",
+	"email_link":     "That confirmation link is invalid or has expired. Please request a new one.",
+	"email_send":     "We couldn't send the confirmation email. Please try again later.",
+	"account_failed": "Something went wrong saving your changes. Please try again.",
+}
+
+// profileNotices are the messages for successful account changes.
+var profileNotices = map[string]string{
+	"account":       "Your name and bio have been updated.",
+	"email_sent":    "We sent a confirmation link to your new address. Your email changes once you open it.",
+	"email_changed": "Your email address has been updated.",
+}
+
+// AccountError is an account change refused for a reason the user can fix.
+// Code is one of the keys of profileErrors.
+type AccountError struct {
+	Code string
+}
+
+func (e *AccountError) Error() string {
+	return profileErrors[e.Code]
+}
+
+func redirectProfile(w http.ResponseWriter, r *http.Request, key, code string) {
+	http.Redirect(w, r, "/profile?"+key+"="+url.QueryEscape(code), http.StatusSeeOther)
+}
+
+// redirectAccountError sends the user back to the profile page with err,
+// logging it first unless the user can fix it.
+func redirectAccountError(w http.ResponseWriter, r *http.Request, err error) {
+	var accountErr *AccountError
+	if !errors.As(err, &accountErr) {
+		log.Printf("Account change failed: %v", err)
+		accountErr = &AccountError{Code: "account_failed"}
+	}
+	redirectProfile(w, r, "error", accountErr.Code)
+}
+
+// UpdateAccount changes userID's name and bio.
+func (a *Accounts) UpdateAccount(ctx context.Context, userID int, name, bio string) error {
+	name = strings.TrimSpace(name)
+	bio = strings.TrimSpace(bio)
+	switch {
+	case name == "":
+		return &AccountError{Code: "name_required"}
+	case utf8.RuneCountInString(name) > MaxDisplayName:
+		return &AccountError{Code: "name_long"}
+	case utf8.RuneCountInString(bio) > MaxBio:
+		return &AccountError{Code: "bio_long"}
+	}
+
+	if err := a.users.UpdateUserName(ctx, userID, name); err != nil {
+		return err
+	}
+	return a.users.SetBio(ctx, userID, bio)
+}
+
+// StartEmailChange checks password and mails a link confirming newEmail
+// to that address. Links point at base, the site's origin.
+func (a *Accounts) StartEmailChange(ctx context.Context, base string, userID int, newEmail, password string) error {
+	if base == "" {
+		return errors.New("no base URL for emailed links")
+	}
+	newEmail = strings.TrimSpace(newEmail)
+	addr, err := netmail.ParseAddress(newEmail)
+	if err != nil || addr.Address != newEmail {
+		return &AccountError{Code: "email_invalid"}
+	}
+
+	user, err := a.users.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		return &AccountError{Code: "password"}
+	}
+	if strings.EqualFold(newEmail, user.Email) {
+		return &AccountError{Code: "email_same"}
+	}
+
+	token, err := a.users.CreateEmailChange(ctx, userID, newEmail, emailChangeTTL)
+	if errors.Is(err, db.ErrEmailTaken) {
+		return &AccountError{Code: "email_taken"}
+	}
+	if err != nil {
+		return err
+	}
+
+	link := base + "/profile/email/confirm?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("Hi %s,\n\nOpen this link to use this address for your Remainwith account:\n\n%s\n\nThe link expires in %d hours. If you didn't ask for this, you can ignore this email.\n",
+		user.Name, link, int(emailChangeTTL.Hours()))
+	if err := a.mailer.Send(ctx, newEmail, "Confirm your new email address", body); err != nil {
+		log.Printf("Sending email confirmation: %v", err)
+		return &AccountError{Code: "email_send"}
+	}
+	return nil
+}
+
+// SaveAccountHandler serves POST /profile/account, updating the user's
+// name and bio. The session token is re-issued so the new name shows
+// everywhere straight away.
+func (a *Accounts) SaveAccountHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := UserFromContext(r.Context())
+	userID := GetUserIDFromContext(r)
+	if !ok || userID == 0 {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	if err := a.UpdateAccount(r.Context(), userID, r.FormValue("name"), r.FormValue("bio")); err != nil {
+		redirectAccountError(w, r, err)
+		return
+	}
+
+	sessionID, _ := claims["session_id"].(string)
+	if err := a.reissueSession(w, r, userID, sessionID); err != nil {
+		log.Printf("Re-issuing session for user %d: %v", userID, err)
+	}
+	redirectProfile(w, r, "notice", "account")
+}
+
+// RequestEmailChangeHandler serves POST /profile/email. It checks the
+// current password and mails a confirmation link to the new address; the
+// email only changes once that link is opened.
+func (a *Accounts) RequestEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserIDFromContext(r)
+	if userID == 0 {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	if err := a.StartEmailChange(r.Context(), BaseURL(), userID, r.FormValue("new_email"), r.FormValue("password")); err != nil {
+		redirectAccountError(w, r, err)
+		return
+	}
+	redirectProfile(w, r, "notice", "email_sent")
+}
+
+// ConfirmEmailChangeHandler serves GET /profile/email/confirm?token=. The
+// token alone authorizes the change, so the link works from any browser;
+// when it is opened where the user is signed in, their session is
+// re-issued with the new address.
+func (a *Accounts) ConfirmEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	user, oldEmail, err := a.users.ConfirmEmailChange(r.Context(), r.URL.Query().Get("token"))
+	switch {
+	case errors.Is(err, db.ErrEmailChangeInvalid):
+		redirectProfile(w, r, "error", "email_link")
+		return
+	case errors.Is(err, db.ErrEmailTaken):
+		redirectProfile(w, r, "error", "email_taken")
+		return
+	case err != nil:
+		log.Printf("ConfirmEmailChange: %v", err)
+		redirectProfile(w, r, "error", "account_failed")
+		return
+	}
+
+	// Let the old address know, in case the change wasn't theirs
+	body := fmt.Sprintf("Hi %s,\n\nThe email address on your Remainwith account was changed to %s. If this wasn't you, please contact us.\n",
+		user.Name, user.Email)
+	if err := a.mailer.Send(r.Context(), oldEmail, "Your email address was changed", body); err != nil {
+		log.Printf("Sending email change notice: %v", err)
+	}
+
+	claims, ok := parseAuthToken(r)
+	if !ok || userIDFromClaims(claims) != user.ID {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	sessionID, _ := claims["session_id"].(string)
+	if err := issueSession(w, user, sessionID); err != nil {
+		log.Printf("Re-issuing session for user %d: %v", user.ID, err)
+	}
+	redirectProfile(w, r, "notice", "email_changed")
+}
+
+// reissueSession loads userID afresh and issues a token for it.
+func (a *Accounts) reissueSession(w http.ResponseWriter, r *http.Request, userID int, sessionID string) error {
+	user, err := a.users.GetUserByID(r.Context(), userID)
+	if err != nil {
+		return err
+	}
+	return issueSession(w, user, sessionID)
+}
+
+// BaseURL is the origin used in emailed links, the configured BASE_URL.
+// It is never taken from the request, whose Host header the client picks.
+func BaseURL() string {
+	return baseURL
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"Remainwith/db/memstore"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// sentMail records what account handlers send.
+type sentMail struct {
+	to, subject, body string
+}
+
+type recordingSender struct {
+	sent []sentMail
+}
+
+func (s *recordingSender) Send(ctx context.Context, to, subject, body string) error {
+	s.sent = append(s.sent, sentMail{to, subject, body})
+	return nil
+}
+
+// newTestAccounts returns account handlers over a store holding one user,
+// whose ID it returns.
+func newTestAccounts(t *testing.T) (*Accounts, *memstore.Store, *recordingSender, int) {
+	t.Helper()
+	store := memstore.New()
+	hashed, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := store.NewUser(ctx, "Ana", "ana@example.com", string(hashed)); err != nil {
+		t.Fatal(err)
+	}
+	user, err := store.GetUserByEmail(ctx, "ana@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := &recordingSender{}
+	return NewAccounts(store, sender), store, sender, user.ID
+}
+
+func TestCheckLogin(t *testing.T) {
+	a, store, _, id := newTestAccounts(t)
+	ctx := context.Background()
+
+	if _, err := a.CheckLogin(ctx, "ana@example.com", "secret"); err != nil {
+		t.Errorf("good password: %v", err)
+	}
+	for _, tt := range []struct{ email, password string }{
+		{"ana@example.com", "wrong"},
+		{"nobody@example.com", "secret"},
+	} {
+		if _, err := a.CheckLogin(ctx, tt.email, tt.password); !errors.Is(err, ErrBadCredentials) {
+			t.Errorf("CheckLogin(%q, %q) = %v, want ErrBadCredentials", tt.email, tt.password, err)
+		}
+	}
+
+	store.SetSuspended(id, time.Now().Add(time.Hour))
+	var suspended *SuspendedError
+	if _, err := a.CheckLogin(ctx, "ana@example.com", "secret"); !errors.As(err, &suspended) {
+		t.Errorf("suspended account: %v, want SuspendedError", err)
+	}
+}
+
+func TestUpdateAccount(t *testing.T) {
+	a, store, _, id := newTestAccounts(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name, bio, code string
+	}{
+		{"  ", "", "name_required"},
+		{strings.Repeat("é", MaxDisplayName+1), "", "name_long"},
+		{"Ana", strings.Repeat("x", MaxBio+1), "bio_long"},
+	}
+	for _, tt := range tests {
+		var accountErr *AccountError
+		if err := a.UpdateAccount(ctx, id, tt.name, tt.bio); !errors.As(err, &accountErr) || accountErr.Code != tt.code {
+			t.Errorf("UpdateAccount(%q, %d-char bio) = %v, want %s", tt.name, len(tt.bio), err, tt.code)
+		}
+	}
+
+	if err := a.UpdateAccount(ctx, id, " Ana B ", " hello "); err != nil {
+		t.Fatal(err)
+	}
+	user, _ := store.GetUserByID(ctx, id)
+	if user.Name != "Ana B" || store.Bio(id) != "hello" {
+		t.Errorf("saved name %q, bio %q", user.Name, store.Bio(id))
+	}
+}
+
+func TestEmailChange(t *testing.T) {
+	JWTKey = []byte("test-key")
+	a, store, sender, id := newTestAccounts(t)
+	ctx := context.Background()
+
+	var accountErr *AccountError
+	if err := a.StartEmailChange(ctx, "https://example.com", id, "ana@new.example", "wrong"); !errors.As(err, &accountErr) || accountErr.Code != "password" {
+		t.Errorf("wrong password: %v", err)
+	}
+	if err := a.StartEmailChange(ctx, "https://example.com", id, "ana@new.example", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if len(sender.sent) != 1 || sender.sent[0].to != "ana@new.example" {
+		t.Fatalf("sent %+v", sender.sent)
+	}
+	link := regexp.MustCompile(`https://example\.com/profile/email/confirm\?token=\S+`).FindString(sender.sent[0].body)
+	if link == "" {
+		t.Fatalf("no confirmation link in %q", sender.sent[0].body)
+	}
+	u, _ := url.Parse(link)
+
+	rec := httptest.NewRecorder()
+	a.ConfirmEmailChangeHandler(rec, httptest.NewRequest(http.MethodGet, u.RequestURI(), nil))
+	if user, _ := store.GetUserByID(ctx, id); user.Email != "ana@new.example" {
+		t.Errorf("email is %q after confirming", user.Email)
+	}
+	if len(sender.sent) != 2 || sender.sent[1].to != "ana@example.com" {
+		t.Errorf("old address not notified: %+v", sender.sent)
+	}
+
+	// Links work once
+	rec = httptest.NewRecorder()
+	a.ConfirmEmailChangeHandler(rec, httptest.NewRequest(http.MethodGet, u.RequestURI(), nil))
+	if loc := rec.Header().Get("Location"); loc != "/profile?error=email_link" {
+		t.Errorf("second use redirected to %q", loc)
+	}
+}
@@ -8,7 +8,10 @@ import (
 
 type contextKey string
 
-const userContextKey contextKey = "user"
+const (
+	userContextKey contextKey = "user"
+	roleContextKey contextKey = "role"
+)
 
 func contextWithUser(ctx context.Context, claims jwt.MapClaims) context.Context {
 	return context.WithValue(ctx, userContextKey, claims)
@@ -18,3 +21,9 @@ func UserFromContext(ctx context.Context) (jwt.MapClaims, bool) {
 	claims, ok := ctx.Value(userContextKey).(jwt.MapClaims)
 	return claims, ok
 }
+
+// contextWithRole records the user's role as RequireRole found it in the
+// database, which GetRoleFromContext prefers over the token's claim.
+func contextWithRole(ctx context.Context, role string) context.Context {
+	return context.WithValue(ctx, roleContextKey, role)
+}
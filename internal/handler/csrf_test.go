@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/justinas/nosurf"
+)
+
+func TestCSRF(t *testing.T) {
+	t.Chdir("../..")
+
+	var token string
+	h := CSRF(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = nosurf.Token(r)
+	}), "/api/v1/", "/ws")
+
+	// A page load hands out the cookie and the token.
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/login", nil))
+	cookies := rec.Result().Cookies()
+	if token == "" || len(cookies) == 0 {
+		t.Fatal("GET didn't set up a token")
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		token       string
+		want        int
+		contentType string
+	}{
+		{name: "form page without token", path: "/login", want: http.StatusForbidden, contentType: "text/html"},
+		{name: "api without token", path: "/api/interests", want: http.StatusForbidden, contentType: "text/plain"},
+		{name: "header token", path: "/api/interests", token: token, want: http.StatusOK},
+		{name: "wrong token", path: "/api/interests", token: strings.Repeat("A", len(token)), want: http.StatusForbidden},
+		{name: "exempt prefix", path: "/api/v1/journals", want: http.StatusOK},
+		{name: "exempt exact path", path: "/ws", want: http.StatusOK},
+		{name: "exact path isn't a prefix", path: "/ws/other", want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, nil)
+			req.Header.Set("Origin", "http://"+req.Host)
+			for _, c := range cookies {
+				req.AddCookie(c)
+			}
+			if tt.token != "" {
+				req.Header.Set(nosurf.HeaderName, tt.token)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status %d, want %d", rec.Code, tt.want)
+			}
+			if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, tt.contentType) {
+				t.Errorf("Content-Type %q, want %s", ct, tt.contentType)
+			}
+		})
+	}
+}
+
+func TestCSRFRejectsCrossOrigin(t *testing.T) {
+	h := CSRF(http.NotFoundHandler())
+	req := httptest.NewRequest(http.MethodPost, "/api/blocks", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("status %d, want 403", rec.Code)
+	}
+}
@@ -1,10 +1,9 @@
 package handler
 
 import (
-	"html/template"
+	"Remainwith/db"
+	"log"
 	"net/http"
-
-	"github.com/justinas/nosurf"
 )
 
 func DashboardHandler(w http.ResponseWriter, r *http.Request) {
@@ -20,23 +19,27 @@ func DashboardHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Extract user name from claims
-	name, ok := claims["name"].(string)
-	if !ok {
-		name = "User" // Fallback if name not found
-	}
-
 	sessionID, _ := claims["session_id"].(string)
 
+	// A pinned safety plan is shown on the dashboard
+	var safetyPlan *db.SafetyPlan
+	if userID := GetUserIDFromContext(r); userID != 0 {
+		plan, err := db.GetSafetyPlan(r.Context(), userID)
+		if err != nil {
+			log.Printf("Dashboard: failed to get safety plan for user %d: %v", userID, err)
+		} else if plan.Pinned {
+			safetyPlan = plan
+		}
+	}
+
 	// Data to pass to template
 	data := struct {
-		Name      string
-		CSRFToken string
-		SessionID string
+		Page
+		SessionID  string
+		SafetyPlan *db.SafetyPlan
 	}{
-		Name:      name,
-		CSRFToken: nosurf.Token(r),
-		SessionID: sessionID,
+		SessionID:  sessionID,
+		SafetyPlan: safetyPlan,
 	}
 	w.Header().Set("Cache-Control", "no-store, no-cache, must-revalidate, proxy-revalidate")
 	w.Header().Set("Pragma", "no-cache")
@@ -48,16 +51,5 @@ func DashboardHandler(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/login", http.StatusSeeOther)
 		return
 	}
-	// Parse and execute template
-	tmpl, err := template.ParseFiles("frontend/dashboard.tmpl")
-	if err != nil {
-		http.Error(w, "Error loading template", http.StatusInternalServerError)
-		return
-	}
-
-	err = tmpl.Execute(w, data)
-	if err != nil {
-		http.Error(w, "Error rendering template", http.StatusInternalServerError)
-		return
-	}
+	Render(w, r, http.StatusOK, "dashboard", &data)
 }
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Pinger is what readiness checks; *pgxpool.Pool satisfies it.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Health serves the liveness and readiness probes.
+type Health struct {
+	db Pinger
+
+	// draining is set once shutdown starts, so load balancers stop
+	// sending traffic before the listener closes
+	draining atomic.Bool
+}
+
+// NewHealth returns probes whose readiness depends on db answering a ping.
+func NewHealth(db Pinger) *Health {
+	return &Health{db: db}
+}
+
+// Drain makes the readiness probe fail from now on.
+func (h *Health) Drain() {
+	h.draining.Store(true)
+}
+
+// LivenessHandler serves GET /healthz. It succeeds whenever the process
+// can answer at all.
+func (h *Health) LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte("ok\n"))
+}
+
+// ReadinessHandler serves GET /readyz: 200 while the database answers and
+// the server isn't shutting down, 503 otherwise.
+func (h *Health) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+
+	if h.draining.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("shutting down\n"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+	if err := h.db.Ping(ctx); err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("database unavailable\n"))
+		return
+	}
+	w.Write([]byte("ok\n"))
+}
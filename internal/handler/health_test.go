@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type pingFunc func(ctx context.Context) error
+
+func (f pingFunc) Ping(ctx context.Context) error { return f(ctx) }
+
+func TestReadiness(t *testing.T) {
+	up := pingFunc(func(context.Context) error { return nil })
+	down := pingFunc(func(context.Context) error { return errors.New("connection refused") })
+
+	tests := []struct {
+		name  string
+		db    Pinger
+		drain bool
+		want  int
+	}{
+		{"ready", up, false, http.StatusOK},
+		{"database down", down, false, http.StatusServiceUnavailable},
+		{"draining", up, true, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		h := NewHealth(tt.db)
+		if tt.drain {
+			h.Drain()
+		}
+		rec := httptest.NewRecorder()
+		h.ReadinessHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.want)
+		}
+
+		// Liveness never depends on the database
+		rec = httptest.NewRecorder()
+		h.LivenessHandler(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: liveness status %d", tt.name, rec.Code)
+		}
+	}
+}
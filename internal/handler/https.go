@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// HSTS tells browsers to use HTTPS for maxAge, covering subdomains too.
+// A zero maxAge leaves responses alone.
+func HSTS(maxAge time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if maxAge <= 0 {
+			return next
+		}
+		value := "max-age=" + strconv.Itoa(int(maxAge.Seconds())) + "; includeSubDomains"
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Strict-Transport-Security", value)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RedirectToHTTPS sends every request to the same host and path on the
+// HTTPS listener at httpsAddr. GETs and HEADs get a permanent redirect;
+// other methods get 308 so bodies are replayed rather than dropped.
+func RedirectToHTTPS(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+
+		status := http.StatusPermanentRedirect
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			status = http.StatusMovedPermanently
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), status)
+	})
+}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		addr, method, target string
+		status               int
+		location             string
+	}{
+		{":443", http.MethodGet, "http://example.com/journal?id=3", http.StatusMovedPermanently, "https://example.com/journal?id=3"},
+		{":8443", http.MethodGet, "http://example.com:8080/login", http.StatusMovedPermanently, "https://example.com:8443/login"},
+		{":443", http.MethodPost, "http://example.com/login", http.StatusPermanentRedirect, "https://example.com/login"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		RedirectToHTTPS(tt.addr).ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+		if rec.Code != tt.status || rec.Header().Get("Location") != tt.location {
+			t.Errorf("%s %s via %s: %d %q, want %d %q", tt.method, tt.target, tt.addr, rec.Code, rec.Header().Get("Location"), tt.status, tt.location)
+		}
+	}
+}
+
+func TestHSTS(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	for maxAge, want := range map[time.Duration]string{
+		0:              "",
+		24 * time.Hour: "max-age=86400; includeSubDomains",
+	} {
+		rec := httptest.NewRecorder()
+		HSTS(maxAge)(ok).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if got := rec.Header().Get("Strict-Transport-Security"); got != want {
+			t.Errorf("HSTS(%v) sent %q, want %q", maxAge, got, want)
+		}
+	}
+}
@@ -9,5 +9,5 @@ func IndexHandler(w http.ResponseWriter, r *http.Request) {
 		http.NotFound(w, r)
 		return
 	}
-	http.ServeFile(w, r, "frontend/index.html")
+	Render(w, r, http.StatusOK, "index", nil)
 }
@@ -6,10 +6,22 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+
+	"github.com/golang-jwt/jwt/v5"
 )
 
+// Interests serves onboarding and the interest picker.
+type Interests struct {
+	interests db.InterestStore
+}
+
+// NewInterests returns interest handlers backed by interests.
+func NewInterests(interests db.InterestStore) *Interests {
+	return &Interests{interests: interests}
+}
+
 // CheckOnboardingHandler returns whether the onboarding dialog should be shown.
-func CheckOnboardingHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Interests) CheckOnboardingHandler(w http.ResponseWriter, r *http.Request) {
 	userID := GetUserIDFromContext(r)
 	if userID == 0 {
 		log.Println("CheckOnboarding: User ID not found in context")
@@ -17,28 +29,31 @@ func CheckOnboardingHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	show, err := db.ShouldShowOnboarding(r.Context(), userID)
+	show, err := h.interests.ShouldShowOnboarding(r.Context(), userID)
 	if err != nil {
 		log.Printf("CheckOnboarding: DB error for user %d: %v", userID, err)
 		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]bool{"show": show})
 }
 
-// GetInterestsHandler returns the list of all interests.
-func GetInterestsHandler(w http.ResponseWriter, r *http.Request) {
-	interests, err := db.GetAllInterests(r.Context())
+// GetInterestsHandler returns the active interests grouped by category,
+// both in display order.
+func (h *Interests) GetInterestsHandler(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.interests.GetInterestCatalog(r.Context(), false)
 	if err != nil {
 		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
-	json.NewEncoder(w).Encode(interests)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(categories)
 }
 
 // SaveInterestsHandler saves the user's selected interests.
-func SaveInterestsHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Interests) SaveInterestsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -64,7 +79,7 @@ func SaveInterestsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := db.SaveUserInterestsByNames(r.Context(), userID, req.InterestNames); err != nil {
+	if err := h.interests.SaveUserInterestsByNames(r.Context(), userID, req.InterestNames); err != nil {
 		http.Error(w, "Failed to save interests", http.StatusInternalServerError)
 		return
 	}
@@ -78,7 +93,11 @@ func GetUserIDFromContext(r *http.Request) int {
 	if !ok {
 		return 0
 	}
+	return userIDFromClaims(claims)
+}
 
+// userIDFromClaims reads the user_id claim, or 0 if it is missing.
+func userIDFromClaims(claims jwt.MapClaims) int {
 	// Try to get user_id as float64 (standard JSON number)
 	if idFloat, ok := claims["user_id"].(float64); ok {
 		return int(idFloat)
@@ -1,16 +1,22 @@
 package handler
 
 import (
-	"log"
-	"os"
+	"Remainwith/config"
+	"strings"
 )
 
 var JWTKey []byte
 
-func InitJWT() {
-	key := os.Getenv("JWTKEY")
-	if key == "" {
-		log.Fatal("JWTKEY is not set")
-	}
-	JWTKey = []byte(key)
+// secureCookies marks session and CSRF cookies Secure; baseURL is the
+// origin in emailed links. Configure sets both.
+var (
+	secureCookies bool
+	baseURL       string
+)
+
+// Configure applies the settings handlers take from cfg.
+func Configure(cfg *config.Config) {
+	JWTKey = cfg.JWTKey
+	secureCookies = cfg.SecureCookies()
+	baseURL = strings.TrimSuffix(cfg.BaseURL, "/")
 }
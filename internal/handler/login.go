@@ -2,15 +2,11 @@ package handler
 
 import (
 	"Remainwith/db"
-	"crypto/rand"
-	"encoding/hex"
-	"fmt"
-	"html/template"
+	"context"
+	"errors"
 	"net/http"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/justinas/nosurf"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -24,21 +20,51 @@ func LoginPageHandler(w http.ResponseWriter, r *http.Request) {
 		http.NotFound(w, r)
 		return
 	}
-	tmpl, err := template.ParseFiles("frontend/login.tmpl")
+	Render(w, r, http.StatusOK, "login", &authPage{})
+}
+
+// authPage is the data for the login and signup forms.
+type authPage struct {
+	Page
+	Error string
+}
+
+// ErrBadCredentials is returned by CheckLogin for an unknown email or a
+// wrong password; the two are deliberately indistinguishable.
+var ErrBadCredentials = errors.New("invalid email or password")
+
+// SuspendedError is returned by CheckLogin for a suspended account.
+type SuspendedError struct {
+	Until time.Time
+}
+
+func (e *SuspendedError) Error() string {
+	return "This account is suspended until " + e.Until.Format("2 Jan 2006 15:04") + "."
+}
+
+// CheckLogin returns the account for email if password matches and the
+// account is not suspended.
+func (a *Accounts) CheckLogin(ctx context.Context, email, password string) (*db.Userinfo, error) {
+	user, err := a.users.GetUserByEmail(ctx, email)
 	if err != nil {
-		http.Error(w, "Template parsing failed", http.StatusInternalServerError)
-		return
+		// User not found or other error
+		return nil, ErrBadCredentials
+	}
+
+	if bcrypt.CompareHashAndPassword(
+		[]byte(user.Password),
+		[]byte(password),
+	) != nil {
+		return nil, ErrBadCredentials
 	}
-	data := struct {
-		CSRFToken string
-		Error     string
-	}{
-		CSRFToken: nosurf.Token(r),
-		Error:     "",
+
+	if user.SuspendedUntil != nil && user.SuspendedUntil.After(time.Now()) {
+		return nil, &SuspendedError{Until: *user.SuspendedUntil}
 	}
-	tmpl.Execute(w, data)
+	return user, nil
 }
-func LoginHandler(w http.ResponseWriter, r *http.Request) {
+
+func (a *Accounts) LoginHandler(w http.ResponseWriter, r *http.Request) {
 
 	if err := r.ParseForm(); err != nil {
 		http.Error(w, "Unable to parse form", http.StatusBadRequest)
@@ -50,90 +76,27 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		Password: r.FormValue("password"),
 	}
 
-	user, err := db.GetUserByEmail(r.Context(), req.Email)
+	user, err := a.CheckLogin(r.Context(), req.Email, req.Password)
 	if err != nil {
-		// User not found or other error
-		tmpl, tmplErr := template.ParseFiles("frontend/login.tmpl")
-		if tmplErr != nil {
-			http.Error(w, "Template parsing failed", http.StatusInternalServerError)
-			return
-		}
-		data := struct {
-			CSRFToken string
-			Error     string
-		}{
-			CSRFToken: nosurf.Token(r),
-			Error:     "Invalid email or password",
-		}
-		tmpl.Execute(w, data)
-		return
-	}
-
-	if bcrypt.CompareHashAndPassword(
-		[]byte(user.Password),
-		[]byte(req.Password),
-	) != nil {
-		// Password mismatch
-		tmpl, tmplErr := template.ParseFiles("frontend/login.tmpl")
-		if tmplErr != nil {
-			http.Error(w, "Template parsing failed", http.StatusInternalServerError)
-			return
+		message := "Invalid email or password"
+		var suspended *SuspendedError
+		if errors.As(err, &suspended) {
+			message = suspended.Error()
 		}
-		data := struct {
-			CSRFToken string
-			Error     string
-		}{
-			CSRFToken: nosurf.Token(r),
-			Error:     "Invalid email or password",
-		}
-		tmpl.Execute(w, data)
+		Render(w, r, http.StatusOK, "login", &authPage{Error: message})
 		return
 	}
 
-	randomBytes := make([]byte, 16)
-	if _, err := rand.Read(randomBytes); err != nil {
+	sessionID, err := NewSessionID()
+	if err != nil {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
-	sessionID := hex.EncodeToString(randomBytes)
-
-	claims := jwt.MapClaims{
-		"user_id":    user.ID,
-		"email":      user.Email,
-		"name":       user.Name,
-		"session_id": sessionID,
-		"exp":        time.Now().Add(24 * time.Hour).Unix(),
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
-	tokenString, err := token.SignedString(JWTKey)
-	if err != nil {
+	if err := issueSession(w, user, sessionID); err != nil {
 		http.Error(w, "Token generation failed", http.StatusInternalServerError)
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "auth_token",
-		Value:    tokenString,
-		Path:     "/",
-		Expires:  time.Now().Add(7 * 24 * time.Hour),
-		HttpOnly: true,
-		Secure:   false, // MUST be false for localhost
-		SameSite: http.SameSiteStrictMode,
-	})
-
-	// Set client-side session data cookie for per-tab logout
-	sessionData := fmt.Sprintf("%v|%s|%s", user.ID, sessionID, user.Email)
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_data",
-		Value:    sessionData,
-		Path:     "/",
-		Expires:  time.Now().Add(7 * 24 * time.Hour),
-		HttpOnly: false, // Allow JavaScript access for per-tab management
-		Secure:   false, // MUST be false for localhost
-		SameSite: http.SameSiteStrictMode,
-	})
-
 	http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
 }
@@ -49,6 +49,7 @@ func LogoutHandler(w http.ResponseWriter, r *http.Request) {
 		Path:     "/",
 		MaxAge:   -1,
 		HttpOnly: true,
+		Secure:   secureCookies,
 	}
 
 	sessionDataCookie := &http.Cookie{
@@ -57,6 +58,7 @@ func LogoutHandler(w http.ResponseWriter, r *http.Request) {
 		Path:     "/",
 		MaxAge:   -1,
 		HttpOnly: false,
+		Secure:   secureCookies,
 	}
 
 	http.SetCookie(w, authCookie)
@@ -1,61 +1,32 @@
 package handler
 
 import (
-	"fmt"
 	"net/http"
-
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/justinas/nosurf"
 )
 
 func JWTMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
-		cookie, err := r.Cookie("auth_token")
-		if err != nil {
-			http.Redirect(w, r, "/login", http.StatusSeeOther)
-			return
-		}
 		if len(JWTKey) == 0 {
 			http.Error(w, "JWT key not initialized", http.StatusInternalServerError)
 			return
 		}
 
-		token, err := jwt.Parse(cookie.Value, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method")
-			}
-			return JWTKey, nil
-		})
-
-		if err != nil || !token.Valid {
+		claims, ok := parseAuthToken(r)
+		if !ok {
 			http.Redirect(w, r, "/login", http.StatusSeeOther)
 			return
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
+		ctx := contextWithUser(r.Context(), claims)
+		r = r.WithContext(ctx)
+
+		if isSuspended(GetUserIDFromContext(r)) {
+			http.SetCookie(w, &http.Cookie{Name: "auth_token", Value: "", Path: "/", MaxAge: -1, HttpOnly: true, Secure: secureCookies})
 			http.Redirect(w, r, "/login", http.StatusSeeOther)
 			return
 		}
 
-		ctx := contextWithUser(r.Context(), claims)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		next.ServeHTTP(w, r)
 	})
 }
-
-func CSRFMiddleware() func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		csrfHandler := nosurf.New(next)
-		csrfHandler.SetBaseCookie(http.Cookie{
-			Path:     "/",
-			SameSite: http.SameSiteStrictMode,
-			Secure:   false, // Set to true in production with HTTPS
-			HttpOnly: false,
-		})
-		csrfHandler.SetFailureHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			http.Redirect(w, r, "/login", http.StatusSeeOther)
-		}))
-		return csrfHandler
-	}
-}
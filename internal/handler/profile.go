@@ -2,15 +2,73 @@ package handler
 
 import (
 	"Remainwith/db"
-	"html/template"
+	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
+	"unicode/utf8"
+)
 
-	"github.com/justinas/nosurf"
+// Length limits, in characters, for profile text.
+const (
+	MaxDisplayName = 40
+	MaxBio         = 300
 )
 
-func ProfilePageHandler(w http.ResponseWriter, r *http.Request) {
+// Profiles serves the profile page.
+type Profiles struct {
+	interests db.InterestStore
+}
+
+// NewProfiles returns profile page handlers reading interests from
+// interests.
+func NewProfiles(interests db.InterestStore) *Profiles {
+	return &Profiles{interests: interests}
+}
+
+// interestChoice is one of the user's interests on the privacy form.
+type interestChoice struct {
+	ID      int
+	Name    string
+	Visible bool
+}
+
+// userInterestChoices lists the user's active interests in catalog order,
+// marking the ones shown on their public profile.
+func (p *Profiles) userInterestChoices(r *http.Request, userID int, hidden []int) ([]interestChoice, error) {
+	ids, err := p.interests.GetUserInterestIDs(r.Context(), userID)
+	if err != nil {
+		return nil, err
+	}
+	categories, err := p.interests.GetInterestCatalog(r.Context(), false)
+	if err != nil {
+		return nil, err
+	}
+
+	var choices []interestChoice
+	for _, c := range categories {
+		for _, i := range c.Interests {
+			if slices.Contains(ids, i.ID) {
+				choices = append(choices, interestChoice{ID: i.ID, Name: i.Name, Visible: !slices.Contains(hidden, i.ID)})
+			}
+		}
+	}
+	return choices, nil
+}
+
+// initial is the first letter of name, shown when there is no avatar.
+func initial(name string) string {
+	r, _ := utf8.DecodeRuneInString(strings.TrimSpace(name))
+	if r == utf8.RuneError {
+		return "?"
+	}
+	return strings.ToUpper(string(r))
+}
+
+func (p *Profiles) ProfilePageHandler(w http.ResponseWriter, r *http.Request) {
 	claims, ok := UserFromContext(r.Context())
 	if !ok {
 		http.Redirect(w, r, "/login", http.StatusSeeOther)
@@ -26,7 +84,7 @@ func ProfilePageHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Fetch interests for both GET and POST
-	interests, err := db.GetUserInterests(r.Context(), userID)
+	interests, err := p.interests.GetUserInterests(r.Context(), userID)
 	if err != nil {
 		log.Printf("Error fetching interests: %v", err)
 		interests = []string{}
@@ -63,7 +121,7 @@ func ProfilePageHandler(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
-		err = db.SaveUserInterestsByNames(r.Context(), userID, interests)
+		err = p.interests.SaveUserInterestsByNames(r.Context(), userID, interests)
 		if err != nil {
 			log.Printf("Error saving interests: %v", err)
 			http.Error(w, "Failed to save interests", http.StatusInternalServerError)
@@ -82,29 +140,127 @@ func ProfilePageHandler(w http.ResponseWriter, r *http.Request) {
 
 	sessionID, _ := claims["session_id"].(string)
 
+	profile, err := db.GetProfile(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error fetching profile: %v", err)
+		profile = &db.Profile{UserID: userID, Discoverable: true, AllowDMs: db.DMEveryone}
+	}
+	choices, err := p.userInterestChoices(r, userID, profile.HiddenInterests)
+	if err != nil {
+		log.Printf("Error fetching interest choices: %v", err)
+	}
+
 	data := struct {
+		Page
 		Name          string
 		Email         string
 		UserID        int
 		SessionID     string
 		UserInterests []string
-		CSRFToken     string
 		Error         string
+		Notice        string
+
+		Profile         *db.Profile
+		Initial         string
+		InterestChoices []interestChoice
+		Saved           bool
 	}{
 		Name:          name,
 		Email:         email,
 		UserID:        userID,
 		SessionID:     sessionID,
 		UserInterests: interests,
-		CSRFToken:     nosurf.Token(r),
-		Error:         "",
+		Error:         profileErrors[r.URL.Query().Get("error")],
+		Notice:        profileNotices[r.URL.Query().Get("notice")],
+
+		Profile:         profile,
+		Initial:         initial(name),
+		InterestChoices: choices,
+		Saved:           r.URL.Query().Get("saved") == "1",
 	}
 
-	tmpl, err := template.ParseFiles("frontend/profile.tmpl")
+	Render(w, r, http.StatusOK, "profile", &data)
+}
+
+// SaveProfileSettingsHandler saves the public profile and privacy form
+// from the profile page.
+func (p *Profiles) SaveProfileSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := GetUserIDFromContext(r)
+	if userID == 0 {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	profile, err := db.GetProfile(r.Context(), userID)
+	if err != nil {
+		log.Printf("Error fetching profile: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	// The name others see and the bio are edited on the account form, see
+	// SaveAccountHandler
+	profile.AllowDMs = r.FormValue("allow_dms")
+	if !db.ValidDMPolicy(profile.AllowDMs) {
+		http.Error(w, "Invalid message setting", http.StatusBadRequest)
+		return
+	}
+	profile.Discoverable = r.FormValue("discoverable") == "on"
+	profile.AnonymousCampfires = r.FormValue("anonymous_campfires") == "on"
+
+	// Interests are shown unless unticked, so new ones start visible
+	ids, err := p.interests.GetUserInterestIDs(r.Context(), userID)
 	if err != nil {
-		log.Printf("Template parsing failed: %v", err)
-		http.Error(w, "Template parsing failed", http.StatusInternalServerError)
+		log.Printf("Error fetching interests: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
-	tmpl.Execute(w, data)
+	visible := r.Form["visible_interest"]
+	profile.HiddenInterests = profile.HiddenInterests[:0]
+	for _, id := range ids {
+		if !slices.Contains(visible, strconv.Itoa(id)) {
+			profile.HiddenInterests = append(profile.HiddenInterests, id)
+		}
+	}
+
+	if err := db.SaveProfile(r.Context(), profile); err != nil {
+		log.Printf("Error saving profile: %v", err)
+		http.Error(w, "Failed to save profile", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/profile?saved=1", http.StatusSeeOther)
+}
+
+// PublicProfileHandler serves GET /api/users/{id}/profile with the parts of
+// a profile the requesting user is allowed to see.
+func PublicProfileHandler(w http.ResponseWriter, r *http.Request) {
+	viewerID := GetUserIDFromContext(r)
+	if viewerID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || userID <= 0 {
+		http.Error(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	profile, err := db.GetPublicProfile(r.Context(), viewerID, userID)
+	if errors.Is(err, db.ErrProfileHidden) {
+		http.Error(w, "Profile not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("PublicProfile: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
 }
@@ -0,0 +1,211 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/justinas/nosurf"
+)
+
+// themeCookie holds the theme picked on the dashboard.
+const themeCookie = "remainwith-theme"
+
+// themes are the values of themeCookie the stylesheets know; the first is
+// the default.
+var themes = []string{"light", "dark", "sepia", "forest"}
+
+// Page is the data every template gets. Page data structs embed it and
+// Render fills it in, so templates can use .CSRFToken, .CSPNonce, .Theme
+// and .User without each handler setting them.
+type Page struct {
+	CSRFToken string
+	CSPNonce  string
+	Theme     string
+
+	// User is the signed-in user, or nil on pages outside JWTMiddleware.
+	User *PageUser
+}
+
+// PageUser is what templates know about the signed-in user.
+type PageUser struct {
+	ID   int
+	Name string
+	Role string
+}
+
+func (p *Page) page() *Page { return p }
+
+// pageData is implemented by pointers to structs embedding Page.
+type pageData interface {
+	page() *Page
+}
+
+// newPage returns the common data for r.
+func newPage(r *http.Request) Page {
+	p := Page{
+		CSRFToken: nosurf.Token(r),
+		CSPNonce:  CSPNonce(r),
+		Theme:     themes[0],
+	}
+	if c, err := r.Cookie(themeCookie); err == nil {
+		for _, theme := range themes {
+			if c.Value == theme {
+				p.Theme = theme
+			}
+		}
+	}
+	if claims, ok := UserFromContext(r.Context()); ok {
+		name, _ := claims["name"].(string)
+		p.User = &PageUser{
+			ID:   userIDFromClaims(claims),
+			Name: name,
+			Role: GetRoleFromContext(r),
+		}
+	}
+	return p
+}
+
+// Renderer executes the page templates in a file system. Every *.tmpl at
+// its root is a page, parsed together with layouts/*.tmpl and
+// partials/*.tmpl and executed through the "base" layout.
+//
+// Pages are parsed once by NewRenderer. With reload set they are parsed
+// again on every render instead, so template edits show up without a
+// restart while developing.
+type Renderer struct {
+	fsys   fs.FS
+	reload bool
+	funcs  template.FuncMap
+	pages  map[string]*template.Template
+}
+
+// NewRenderer parses the pages in fsys, failing on the first broken one
+// even when reload is set. funcs are added to the default template
+// functions, replacing any of the same name.
+func NewRenderer(fsys fs.FS, reload bool, funcs template.FuncMap) (*Renderer, error) {
+	files, err := fs.Glob(fsys, "*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, errors.New("render: no pages found")
+	}
+
+	rn := &Renderer{fsys: fsys, reload: reload, funcs: funcs, pages: make(map[string]*template.Template)}
+	for _, file := range files {
+		name := strings.TrimSuffix(file, ".tmpl")
+		tmpl, err := rn.parse(name)
+		if err != nil {
+			return nil, err
+		}
+		rn.pages[name] = tmpl
+	}
+	return rn, nil
+}
+
+var templateFuncs = template.FuncMap{
+	"join": strings.Join,
+
+	// asset maps a static file's URL to the URL to link; main swaps in
+	// the fingerprinting one from package static.
+	"asset": func(url string) string { return url },
+}
+
+// parse parses page name with the layouts and partials.
+func (rn *Renderer) parse(name string) (*template.Template, error) {
+	tmpl := template.New(name).Funcs(templateFuncs).Funcs(rn.funcs)
+	for _, shared := range []string{"layouts", "partials"} {
+		files, err := fs.Glob(rn.fsys, shared+"/*.tmpl")
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			continue
+		}
+		if tmpl, err = tmpl.ParseFS(rn.fsys, files...); err != nil {
+			return nil, err
+		}
+	}
+	tmpl, err := tmpl.ParseFS(rn.fsys, name+".tmpl")
+	if err != nil {
+		return nil, err
+	}
+	if tmpl.Lookup("base") == nil {
+		return nil, fmt.Errorf("render: %s: no base layout", name)
+	}
+	return tmpl, nil
+}
+
+func (rn *Renderer) lookup(name string) (*template.Template, error) {
+	if rn.reload {
+		return rn.parse(name)
+	}
+	tmpl, ok := rn.pages[name]
+	if !ok {
+		return nil, fmt.Errorf("render: no page %q", name)
+	}
+	return tmpl, nil
+}
+
+var buffers = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// Render executes page name with data and writes it with the given status.
+// data must be nil or a pointer to a struct embedding Page; Render fills
+// in the Page. The page is rendered into a buffer first, so a template
+// error becomes a 500 rather than half a page.
+func (rn *Renderer) Render(w http.ResponseWriter, r *http.Request, status int, name string, data any) {
+	buf := buffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer buffers.Put(buf)
+
+	if err := rn.execute(buf, r, name, data); err != nil {
+		log.Printf("render %s: %v", name, err)
+		http.Error(w, "Error rendering page", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	buf.WriteTo(w)
+}
+
+func (rn *Renderer) execute(buf *bytes.Buffer, r *http.Request, name string, data any) error {
+	tmpl, err := rn.lookup(name)
+	if err != nil {
+		return err
+	}
+
+	common := newPage(r)
+	if data == nil {
+		data = &common
+	} else if pd, ok := data.(pageData); ok {
+		*pd.page() = common
+	} else {
+		return errors.New("data must be a pointer to a struct embedding handler.Page")
+	}
+	return tmpl.ExecuteTemplate(buf, "base", data)
+}
+
+// pages renders for Render. Until main installs a parsed renderer with
+// SetRenderer, pages are parsed from ./frontend on every render.
+var pages = &Renderer{fsys: os.DirFS("frontend"), reload: true}
+
+// SetRenderer makes Render use rn.
+func SetRenderer(rn *Renderer) {
+	pages = rn
+}
+
+// Render renders page name with the renderer set by SetRenderer; see
+// Renderer.Render.
+func Render(w http.ResponseWriter, r *http.Request, status int, name string, data any) {
+	pages.Render(w, r, status, name, data)
+}
@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestRendererParsesPages catches a broken page at test time rather than
+// at startup.
+func TestRendererParsesPages(t *testing.T) {
+	if _, err := NewRenderer(os.DirFS("../../frontend"), false, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestPagesUseSharedTheme keeps the theme palettes in static/theme.css
+// instead of copied into each page. Pages may still style a component
+// per theme.
+func TestPagesUseSharedTheme(t *testing.T) {
+	palette := regexp.MustCompile(`html\[data-theme="\w+"\]\s*\{[^}]*--[\w-]+\s*:`)
+	files, err := filepath.Glob("../../frontend/*.tmpl")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no pages: %v", err)
+	}
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if palette.Match(src) {
+			t.Errorf("%s defines a theme; it belongs in static/theme.css", filepath.Base(file))
+		}
+	}
+}
+
+// writeTemplates lays out a template directory from name → source.
+func writeTemplates(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+const testBase = `{{define "base"}}<html data-theme="{{.Theme}}">{{template "content" .}}</html>{{end}}`
+
+func TestRender(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"layouts/base.tmpl": testBase,
+		"partials/hi.tmpl":  `{{define "hi"}}hi {{with .User}}{{.Name}}{{else}}stranger{{end}}{{end}}`,
+		"hello.tmpl":        `{{define "content"}}{{template "hi" .}}, {{.Greeting}}{{end}}`,
+		"broken.tmpl":       `{{define "content"}}before {{.Missing}}{{end}}`,
+		"no_page_data.tmpl": `{{define "content"}}{{.Theme}}{{end}}`,
+	})
+	rn, err := NewRenderer(os.DirFS(dir), false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type hello struct {
+		Page
+		Greeting string
+	}
+
+	t.Run("common data", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(&http.Cookie{Name: themeCookie, Value: "sepia"})
+		rec := httptest.NewRecorder()
+		rn.Render(rec, req, http.StatusTeapot, "hello", &hello{Greeting: "welcome"})
+		if rec.Code != http.StatusTeapot {
+			t.Errorf("status %d", rec.Code)
+		}
+		if want := `<html data-theme="sepia">hi stranger, welcome</html>`; rec.Body.String() != want {
+			t.Errorf("body %q, want %q", rec.Body.String(), want)
+		}
+	})
+
+	t.Run("unknown theme", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(&http.Cookie{Name: themeCookie, Value: `"><script>`})
+		rec := httptest.NewRecorder()
+		rn.Render(rec, req, http.StatusOK, "no_page_data", nil)
+		if want := `<html data-theme="light">light</html>`; rec.Body.String() != want {
+			t.Errorf("body %q, want %q", rec.Body.String(), want)
+		}
+	})
+
+	t.Run("execution error", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		rn.Render(rec, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusOK, "broken", &hello{})
+		if rec.Code != http.StatusInternalServerError || strings.Contains(rec.Body.String(), "before") {
+			t.Errorf("got %d %q, want a 500 without the partial page", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("data without Page", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		rn.Render(rec, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusOK, "hello", struct{ Greeting string }{})
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("status %d, want 500", rec.Code)
+		}
+	})
+}
+
+func TestRenderReload(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"layouts/base.tmpl": testBase,
+		"page.tmpl":         `{{define "content"}}one{{end}}`,
+	})
+	cached, err := NewRenderer(os.DirFS(dir), false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloading, err := NewRenderer(os.DirFS(dir), true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "page.tmpl"), []byte(`{{define "content"}}two{{end}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		rn   *Renderer
+		want string
+	}{{cached, "one"}, {reloading, "two"}} {
+		rec := httptest.NewRecorder()
+		tt.rn.Render(rec, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusOK, "page", nil)
+		if !strings.Contains(rec.Body.String(), tt.want) {
+			t.Errorf("reload=%v: body %q, want %q", tt.rn.reload, rec.Body.String(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"Remainwith/db"
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// suspensions mirrors users.suspended_until so JWTMiddleware can turn away
+// tokens issued before a suspension without a query per request.
+var (
+	suspensionsMu sync.RWMutex
+	suspensions   = make(map[int]time.Time)
+)
+
+// LoadSuspensions fills the suspension cache from the database.
+func LoadSuspensions(ctx context.Context) error {
+	current, err := db.GetSuspensions(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	suspensionsMu.Lock()
+	suspensions = current
+	suspensionsMu.Unlock()
+	return nil
+}
+
+// SetSuspension records that userID is suspended until the given time.
+// A zero time lifts the suspension.
+func SetSuspension(userID int, until time.Time) {
+	suspensionsMu.Lock()
+	defer suspensionsMu.Unlock()
+	if until.IsZero() {
+		delete(suspensions, userID)
+		return
+	}
+	suspensions[userID] = until
+}
+
+// isSuspended reports whether userID is currently suspended.
+func isSuspended(userID int) bool {
+	suspensionsMu.RLock()
+	until, ok := suspensions[userID]
+	suspensionsMu.RUnlock()
+	return ok && time.Now().Before(until)
+}
+
+// GetRoleFromContext returns the user's role: the one RequireRole loaded
+// from the database if it ran, else the role claim, defaulting to
+// db.RoleUser for tokens issued before roles existed. Checks that decide
+// what staff may do must run behind RequireRole, since the claim outlives
+// a demotion until the token expires.
+func GetRoleFromContext(r *http.Request) string {
+	if role, ok := r.Context().Value(roleContextKey).(string); ok {
+		return role
+	}
+	claims, ok := UserFromContext(r.Context())
+	if !ok {
+		return ""
+	}
+	if role, ok := claims["role"].(string); ok && db.ValidRole(role) {
+		return role
+	}
+	return db.RoleUser
+}
+
+// RequireRole allows the request through only for users holding at least
+// min. It must run inside JWTMiddleware. The role claim is confirmed
+// against the database so a demotion takes effect immediately, and the
+// database role is what GetRoleFromContext returns from then on.
+func RequireRole(min string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID := GetUserIDFromContext(r)
+			if userID == 0 {
+				http.Redirect(w, r, "/login", http.StatusSeeOther)
+				return
+			}
+			if !db.RoleAtLeast(GetRoleFromContext(r), min) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			account, err := db.GetUserAccount(r.Context(), userID)
+			if err != nil {
+				log.Printf("RequireRole: failed to load user %d: %v", userID, err)
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			if !db.RoleAtLeast(account.Role, min) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(contextWithRole(r.Context(), account.Role)))
+		})
+	}
+}
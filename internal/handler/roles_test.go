@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"Remainwith/db"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestDatabaseRoleOutranksClaim(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/admin", nil)
+	r = r.WithContext(contextWithUser(r.Context(), jwt.MapClaims{"user_id": 1.0, "role": db.RoleAdmin}))
+	if got := GetRoleFromContext(r); got != db.RoleAdmin {
+		t.Fatalf("role from claim = %q", got)
+	}
+
+	// An admin demoted after signing in still carries the admin claim
+	r = r.WithContext(contextWithRole(r.Context(), db.RoleModerator))
+	if got := GetRoleFromContext(r); got != db.RoleModerator {
+		t.Errorf("role = %q, want the database's %q", got, db.RoleModerator)
+	}
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestSecureHeaders(t *testing.T) {
+	var nonces []string
+	h := DefaultSecurityHeaders().SecureHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonces = append(nonces, CSPNonce(r))
+	}))
+
+	var policies []string
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		for _, name := range []string{"X-Frame-Options", "Referrer-Policy", "Permissions-Policy", "X-Content-Type-Options"} {
+			if rec.Header().Get(name) == "" {
+				t.Errorf("%s not set", name)
+			}
+		}
+		policies = append(policies, rec.Header().Get("Content-Security-Policy"))
+	}
+
+	if nonces[0] == "" || nonces[0] == nonces[1] {
+		t.Fatalf("nonces %q, want distinct values", nonces)
+	}
+	for i, policy := range policies {
+		if !strings.Contains(policy, "script-src 'self' 'nonce-"+nonces[i]+"'") || strings.Contains(policy, noncePlaceholder) {
+			t.Errorf("policy %q doesn't carry nonce %q", policy, nonces[i])
+		}
+	}
+}
+
+func TestSecureHeadersReportOnly(t *testing.T) {
+	s := DefaultSecurityHeaders()
+	s.ReportOnly = true
+	rec := httptest.NewRecorder()
+	s.SecureHeaders(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Header().Get("Content-Security-Policy") != "" || rec.Header().Get("Content-Security-Policy-Report-Only") == "" {
+		t.Errorf("report-only headers: %v", rec.Header())
+	}
+}
+
+// TestTemplatesUseNonces keeps templates working under the CSP: inline
+// scripts need the nonce and inline event handlers never run.
+func TestTemplatesUseNonces(t *testing.T) {
+	files, err := filepath.Glob("../../frontend/*.tmpl")
+	for _, shared := range []string{"layouts", "partials"} {
+		more, _ := filepath.Glob("../../frontend/" + shared + "/*.tmpl")
+		files = append(files, more...)
+	}
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no templates found: %v", err)
+	}
+	script := regexp.MustCompile(`<script\b[^>]*>`)
+	handlerAttr := regexp.MustCompile(`\son[a-z]+\s*=\s*["']`)
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tag := range script.FindAllString(string(src), -1) {
+			if !strings.Contains(tag, "src=") && !strings.Contains(tag, `nonce="{{.CSPNonce}}"`) {
+				t.Errorf("%s: %s has no nonce", filepath.Base(file), tag)
+			}
+		}
+		if m := handlerAttr.FindString(string(src)); m != "" {
+			t.Errorf("%s: inline event handler %q", filepath.Base(file), strings.TrimSpace(m))
+		}
+	}
+}
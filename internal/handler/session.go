@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"Remainwith/db"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Token errors reported by Authenticate.
+var (
+	ErrInvalidToken = errors.New("invalid or expired token")
+	ErrSuspended    = errors.New("account suspended")
+)
+
+// tokenTTL is how long a signed session token is valid.
+const tokenTTL = 24 * time.Hour
+
+// SignToken returns a signed session token for user and when it expires.
+// Browser sessions carry it in the auth_token cookie, API clients as a
+// bearer token.
+func SignToken(user *db.Userinfo, sessionID string) (string, time.Time, error) {
+	role := user.Role
+	if !db.ValidRole(role) {
+		role = db.RoleUser
+	}
+
+	expires := time.Now().Add(tokenTTL)
+	claims := jwt.MapClaims{
+		"user_id":    user.ID,
+		"email":      user.Email,
+		"name":       user.Name,
+		"session_id": sessionID,
+		"role":       role,
+		"exp":        expires.Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+
+	tokenString, err := token.SignedString(JWTKey)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return tokenString, expires, nil
+}
+
+// NewSessionID returns a random session ID for a new login.
+func NewSessionID() (string, error) {
+	randomBytes := make([]byte, 16)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(randomBytes), nil
+}
+
+// ParseToken verifies a signed session token and returns its claims.
+func ParseToken(tokenString string) (jwt.MapClaims, error) {
+	if len(JWTKey) == 0 {
+		return nil, fmt.Errorf("JWT key not initialized")
+	}
+
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method")
+		}
+		return JWTKey, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// Authenticate verifies tokenString and returns ctx carrying its claims,
+// so the context helpers work as they do behind JWTMiddleware.
+func Authenticate(ctx context.Context, tokenString string) (context.Context, error) {
+	claims, err := ParseToken(tokenString)
+	if err != nil {
+		return ctx, err
+	}
+	if isSuspended(userIDFromClaims(claims)) {
+		return ctx, ErrSuspended
+	}
+	return contextWithUser(ctx, claims), nil
+}
+
+// issueSession signs a token for user and sets the auth and per-tab
+// session cookies. Login passes a fresh sessionID; profile edits pass the
+// current one so the tab keeps its session while the claims catch up.
+func issueSession(w http.ResponseWriter, user *db.Userinfo, sessionID string) error {
+	tokenString, _, err := SignToken(user, sessionID)
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     "auth_token",
+		Value:    tokenString,
+		Path:     "/",
+		Expires:  time.Now().Add(7 * 24 * time.Hour),
+		HttpOnly: true,
+		Secure:   secureCookies,
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	// Set client-side session data cookie for per-tab logout
+	sessionData := fmt.Sprintf("%v|%s|%s", user.ID, sessionID, user.Email)
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session_data",
+		Value:    sessionData,
+		Path:     "/",
+		Expires:  time.Now().Add(7 * 24 * time.Hour),
+		HttpOnly: false, // Allow JavaScript access for per-tab management
+		Secure:   secureCookies,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return nil
+}
+
+// parseAuthToken returns the claims of a valid auth_token cookie.
+func parseAuthToken(r *http.Request) (jwt.MapClaims, bool) {
+	cookie, err := r.Cookie("auth_token")
+	if err != nil {
+		return nil, false
+	}
+	claims, err := ParseToken(cookie.Value)
+	return claims, err == nil
+}
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"Remainwith/db"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIssueSessionRoundTrip(t *testing.T) {
+	JWTKey = []byte("test-key")
+	user := &db.Userinfo{ID: 1234567, Name: "Sam", Email: "sam@example.com", Role: "bogus"}
+
+	rec := httptest.NewRecorder()
+	if err := issueSession(rec, user, "tab-session"); err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range rec.Result().Cookies() {
+		r.AddCookie(c)
+	}
+	claims, ok := parseAuthToken(r)
+	if !ok {
+		t.Fatal("issued token did not parse")
+	}
+	if got := userIDFromClaims(claims); got != user.ID {
+		t.Errorf("user_id = %d, want %d", got, user.ID)
+	}
+	if claims["name"] != "Sam" || claims["email"] != "sam@example.com" || claims["session_id"] != "tab-session" {
+		t.Errorf("unexpected claims %v", claims)
+	}
+	if claims["role"] != db.RoleUser {
+		t.Errorf("role = %v, want unknown roles downgraded to %q", claims["role"], db.RoleUser)
+	}
+	if c, err := r.Cookie("session_data"); err != nil || c.Value != "1234567|tab-session|sam@example.com" {
+		t.Errorf("session_data = %v (%v)", c, err)
+	}
+
+	bad := httptest.NewRequest(http.MethodGet, "/", nil)
+	bad.AddCookie(&http.Cookie{Name: "auth_token", Value: "not-a-token"})
+	if _, ok := parseAuthToken(bad); ok {
+		t.Error("garbage token parsed")
+	}
+}
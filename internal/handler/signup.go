@@ -1,12 +1,9 @@
 package handler
 
 import (
-	"Remainwith/db"
-	"html/template"
 	"log"
 	"net/http"
 
-	"github.com/justinas/nosurf"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -22,22 +19,10 @@ func SignupPageHandler(w http.ResponseWriter, r *http.Request) {
 		http.NotFound(w, r)
 		return
 	}
-	tmpl, err := template.ParseFiles("frontend/signup.tmpl")
-	if err != nil {
-		http.Error(w, "Template parsing failed", http.StatusInternalServerError)
-		return
-	}
-	data := struct {
-		CSRFToken string
-		Error     string
-	}{
-		CSRFToken: nosurf.Token(r),
-		Error:     "",
-	}
-	tmpl.Execute(w, data)
+	Render(w, r, http.StatusOK, "signup", &authPage{})
 }
 
-func SignupHandler(w http.ResponseWriter, r *http.Request) {
+func (a *Accounts) SignupHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Parse the form data
 	if err := r.ParseForm(); err != nil {
@@ -53,39 +38,15 @@ func SignupHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if sign.Name == "" || sign.Email == "" || sign.Password == "" || sign.Repassword == "" {
-		tmpl, err := template.ParseFiles("frontend/signup.tmpl")
-		if err != nil {
-			http.Error(w, "Template parsing failed", http.StatusInternalServerError)
-			return
-		}
-		data := struct {
-			CSRFToken string
-			Error     string
-		}{
-			CSRFToken: nosurf.Token(r),
-			Error:     "All fields are required",
-		}
-		tmpl.Execute(w, data)
+		Render(w, r, http.StatusOK, "signup", &authPage{Error: "All fields are required"})
 		return
 	}
 	if sign.Password != sign.Repassword {
-		tmpl, err := template.ParseFiles("frontend/signup.tmpl")
-		if err != nil {
-			http.Error(w, "Template parsing failed", http.StatusInternalServerError)
-			return
-		}
-		data := struct {
-			CSRFToken string
-			Error     string
-		}{
-			CSRFToken: nosurf.Token(r),
-			Error:     "Passwords do not match",
-		}
-		tmpl.Execute(w, data)
+		Render(w, r, http.StatusOK, "signup", &authPage{Error: "Passwords do not match"})
 		return
 	}
 
-	exists, err := db.CheckUser(r.Context(), sign.Email)
+	exists, err := a.users.CheckUser(r.Context(), sign.Email)
 	if err != nil {
 		http.Error(w, "Server error", http.StatusInternalServerError)
 		return
@@ -98,7 +59,7 @@ func SignupHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(sign.Password), bcrypt.DefaultCost)
-	if err := db.NewUser(r.Context(), sign.Name, sign.Email, string(hashedPassword)); err != nil {
+	if err := a.users.NewUser(r.Context(), sign.Name, sign.Email, string(hashedPassword)); err != nil {
 		log.Println("Error inserting user:", err)
 		http.Error(w, "Failed to create user", http.StatusInternalServerError)
 		return
@@ -0,0 +1,333 @@
+// Package integration drives the HTTP handlers, stores and websocket hub
+// together against a real database from testdb.
+package integration
+
+import (
+	"Remainwith/db"
+	"Remainwith/internal/admin"
+	"Remainwith/internal/chat"
+	"Remainwith/internal/handler"
+	"Remainwith/internal/mail"
+	"Remainwith/internal/models"
+	"Remainwith/internal/testdb"
+	"Remainwith/internal/ws"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+func TestMain(m *testing.M) { testdb.Main(m) }
+
+// newServer serves the signup, login, interests and websocket routes the
+// way main wires them, over a fresh database.
+func newServer(t *testing.T) (*httptest.Server, *db.Postgres) {
+	t.Helper()
+	store := testdb.New(t)
+	// Handlers render templates by path from the repository root
+	t.Chdir("../..")
+	handler.JWTKey = []byte("test-key")
+
+	accounts := handler.NewAccounts(store, mail.LogSender{})
+	interests := handler.NewInterests(store)
+	hub := ws.NewHub()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /signup", accounts.SignupHandler)
+	mux.HandleFunc("POST /login", accounts.LoginHandler)
+	mux.Handle("POST /api/interests", handler.JWTMiddleware(http.HandlerFunc(interests.SaveInterestsHandler)))
+	mux.Handle("GET /api/onboarding/check", handler.JWTMiddleware(http.HandlerFunc(interests.CheckOnboardingHandler)))
+	mux.Handle("/ws", handler.JWTMiddleware(http.HandlerFunc(hub.HandleConnection)))
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, store
+}
+
+// noRedirects returns a client that reports redirects instead of following them.
+func noRedirects() *http.Client {
+	return &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func TestSignup(t *testing.T) {
+	srv, store := newServer(t)
+	testdb.CreateUser(t, store, "Ben", "ben@example.com", "secret")
+
+	tests := []struct {
+		name     string
+		form     url.Values
+		status   int
+		location string
+		created  bool
+	}{
+		{"new account", url.Values{"name": {"Ana"}, "email": {"ana@example.com"}, "password": {"pw"}, "Repassword": {"pw"}}, http.StatusSeeOther, "/login", true},
+		{"mismatched passwords", url.Values{"name": {"Cy"}, "email": {"cy@example.com"}, "password": {"pw"}, "Repassword": {"other"}}, http.StatusOK, "", false},
+		{"missing name", url.Values{"email": {"di@example.com"}, "password": {"pw"}, "Repassword": {"pw"}}, http.StatusOK, "", false},
+		// Existing addresses are sent to log in without a new row
+		{"taken email", url.Values{"name": {"Ben"}, "email": {"ben@example.com"}, "password": {"pw"}, "Repassword": {"pw"}}, http.StatusSeeOther, "/login", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := noRedirects().PostForm(srv.URL+"/signup", tt.form)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.status || resp.Header.Get("Location") != tt.location {
+				t.Errorf("status %d, location %q; want %d, %q", resp.StatusCode, resp.Header.Get("Location"), tt.status, tt.location)
+			}
+			if tt.created {
+				if _, err := store.GetUserByEmail(context.Background(), tt.form.Get("email")); err != nil {
+					t.Errorf("account not created: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestLogin(t *testing.T) {
+	srv, store := newServer(t)
+	testdb.CreateUser(t, store, "Ana", "ana@example.com", "secret")
+
+	tests := []struct {
+		name, email, password string
+		ok                    bool
+	}{
+		{"good password", "ana@example.com", "secret", true},
+		{"wrong password", "ana@example.com", "wrong", false},
+		{"unknown email", "nobody@example.com", "secret", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := noRedirects().PostForm(srv.URL+"/login", url.Values{"email": {tt.email}, "password": {tt.password}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			var token bool
+			for _, c := range resp.Cookies() {
+				token = token || (c.Name == "auth_token" && c.Value != "")
+			}
+			ok := resp.StatusCode == http.StatusSeeOther && resp.Header.Get("Location") == "/dashboard"
+			if ok != tt.ok || token != tt.ok {
+				t.Errorf("status %d, location %q, token %v; want success %v", resp.StatusCode, resp.Header.Get("Location"), token, tt.ok)
+			}
+		})
+	}
+}
+
+func TestSaveInterests(t *testing.T) {
+	srv, store := newServer(t)
+	testdb.CreateUser(t, store, "Ana", "ana@example.com", "secret")
+	client := testdb.Login(t, srv.URL, "ana@example.com", "secret")
+
+	onboarding := func() bool {
+		t.Helper()
+		resp, err := client.Get(srv.URL + "/api/onboarding/check")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var body struct {
+			Show bool `json:"show"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		return body.Show
+	}
+
+	if !onboarding() {
+		t.Error("onboarding hidden before choosing interests")
+	}
+
+	tests := []struct {
+		body   string
+		status int
+	}{
+		{`{"interest_names":["a","b","c","d","e","f"]}`, http.StatusBadRequest},
+		{`not json`, http.StatusBadRequest},
+		{`{"interest_names":["Anxiety","Mindfulness"]}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		resp, err := client.Post(srv.URL+"/api/interests", "application/json", strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("POST %s: status %d, want %d", tt.body, resp.StatusCode, tt.status)
+		}
+	}
+
+	if onboarding() {
+		t.Error("onboarding still shown after choosing interests")
+	}
+
+	// Without a session the middleware sends people to log in
+	resp, err := noRedirects().Post(srv.URL+"/api/interests", "application/json", strings.NewReader(`{"interest_names":[]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Errorf("anonymous save: status %d, want 303", resp.StatusCode)
+	}
+}
+
+func TestWebsocketBroadcast(t *testing.T) {
+	srv, store := newServer(t)
+	ana := testdb.CreateUser(t, store, "Ana", "ana@example.com", "secret")
+	testdb.CreateUser(t, store, "Ben", "ben@example.com", "secret")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+
+	dial := func(email string) *websocket.Conn {
+		t.Helper()
+		client := testdb.Login(t, srv.URL, email, "secret")
+		conn, _, err := websocket.Dial(ctx, wsURL, &websocket.DialOptions{HTTPClient: client})
+		if err != nil {
+			t.Fatalf("dialing as %s: %v", email, err)
+		}
+		t.Cleanup(func() { conn.Close(websocket.StatusNormalClosure, "") })
+		return conn
+	}
+
+	// read returns the next frame accepted by match, skipping the rest.
+	read := func(conn *websocket.Conn, match func(models.Message) bool) models.Message {
+		t.Helper()
+		for {
+			_, data, err := conn.Read(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var msg models.Message
+			if err := json.Unmarshal(data, &msg); err != nil {
+				t.Fatal(err)
+			}
+			if match(msg) {
+				return msg
+			}
+		}
+	}
+
+	receiver := dial("ben@example.com")
+	sender := dial("ana@example.com")
+
+	// Joining lists who is already in the room, so once the sender hears
+	// about the receiver both are subscribed
+	read(sender, func(m models.Message) bool { return m.Type == ws.TypePresence })
+
+	if err := sender.Write(ctx, websocket.MessageText, []byte(`{"type":"message","content":"hello"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	got := read(receiver, func(m models.Message) bool { return m.Type == ws.TypeMessage })
+	if got.Content != "hello" || got.SenderID != strconv.Itoa(ana.ID) || got.SenderName != "Ana" {
+		t.Errorf("received %+v", got)
+	}
+	ack := read(sender, func(m models.Message) bool { return m.Type == ws.TypeAck || m.Type == ws.TypeError })
+	if ack.Type != ws.TypeAck {
+		t.Errorf("sender got %+v, want an ack", ack)
+	}
+}
+
+func TestDemotedAdminLosesRank(t *testing.T) {
+	_, store := newServer(t)
+	ctx := context.Background()
+	ana := testdb.CreateUser(t, store, "Ana", "ana@example.com", "secret")
+	ben := testdb.CreateUser(t, store, "Ben", "ben@example.com", "secret")
+	for id, role := range map[int]string{ana.ID: db.RoleAdmin, ben.ID: db.RoleModerator} {
+		if err := db.SetUserRole(ctx, id, role); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hub := ws.NewHub()
+	console := admin.NewConsole(hub, chat.NewCampfires(hub))
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /login", handler.NewAccounts(store, mail.LogSender{}).LoginHandler)
+	mux.Handle("POST /admin/users/{id}/suspend", handler.JWTMiddleware(handler.RequireRole(db.RoleModerator)(http.HandlerFunc(console.SuspendUserHandler))))
+	staff := httptest.NewServer(mux)
+	t.Cleanup(staff.Close)
+
+	// Ana signs in as an admin and is demoted while her token is live
+	client := testdb.Login(t, staff.URL, "ana@example.com", "secret")
+	if err := db.SetUserRole(ctx, ana.ID, db.RoleModerator); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.PostForm(staff.URL+"/admin/users/"+strconv.Itoa(ben.ID)+"/suspend", url.Values{"days": {"1"}, "reason": {"test"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("demoted admin suspending a moderator: status %d, want 403", resp.StatusCode)
+	}
+	if account, err := db.GetUserAccount(ctx, ben.ID); err != nil || account.SuspendedUntil != nil {
+		t.Errorf("moderator suspended: %+v, %v", account, err)
+	}
+}
+
+func TestCreateCampfire(t *testing.T) {
+	_, store := newServer(t)
+	ctx := context.Background()
+	testdb.CreateUser(t, store, "Ana", "ana@example.com", "secret")
+
+	campfires := chat.NewCampfires(ws.NewHub())
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /login", handler.NewAccounts(store, mail.LogSender{}).LoginHandler)
+	mux.Handle("POST /api/campfires", handler.JWTMiddleware(http.HandlerFunc(campfires.CreateHandler)))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	client := testdb.Login(t, srv.URL, "ana@example.com", "secret")
+
+	interests, err := db.GetAllInterests(ctx)
+	if err != nil || len(interests) == 0 {
+		t.Fatalf("GetAllInterests = %d, %v", len(interests), err)
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"no interest", `{"topic":"Exam week"}`, http.StatusCreated},
+		{"interest", fmt.Sprintf(`{"topic":"Exam week","interest_id":%d}`, interests[0].ID), http.StatusCreated},
+		{"unknown interest", `{"topic":"Exam week","interest_id":999999}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		resp, err := client.Post(srv.URL+"/api/campfires", "application/json", strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, resp.StatusCode, tt.status)
+		}
+	}
+
+	// Restore asks for every campfire, not a page of them
+	if all, err := db.ListCampfires(ctx, 0); err != nil || len(all) != 2 {
+		t.Errorf("ListCampfires without a limit = %d, %v, want 2", len(all), err)
+	}
+	if page, err := db.ListCampfires(ctx, 1); err != nil || len(page) != 1 {
+		t.Errorf("ListCampfires(1) = %d, %v, want 1", len(page), err)
+	}
+}
@@ -0,0 +1,63 @@
+// Package mail sends the few transactional emails the app needs.
+package mail
+
+import (
+	"Remainwith/config"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+// Sender delivers a plain-text email.
+type Sender interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+// LogSender writes emails to the log instead of sending them. It is the
+// default when no SMTP server is configured, which suits development.
+type LogSender struct{}
+
+// Send logs the email.
+func (LogSender) Send(ctx context.Context, to, subject, body string) error {
+	log.Printf("Email to %s: %s\n%s", to, subject, body)
+	return nil
+}
+
+// SMTPSender sends through an SMTP server.
+type SMTPSender struct {
+	Addr string // host:port
+	From string
+	Auth smtp.Auth
+}
+
+// Send sends the email.
+func (s SMTPSender) Send(ctx context.Context, to, subject, body string) error {
+	if strings.ContainsAny(to, "\r\n") || strings.ContainsAny(subject, "\r\n") {
+		return fmt.Errorf("invalid header value")
+	}
+	msg := "From: " + s.From + "\r\n" +
+		"To: " + to + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" + strings.ReplaceAll(body, "\n", "\r\n")
+	return smtp.SendMail(s.Addr, s.Auth, s.From, []string{to}, []byte(msg))
+}
+
+// FromConfig returns an SMTPSender for c, or a LogSender when no SMTP
+// server is configured.
+func FromConfig(c config.SMTP) Sender {
+	if c.Addr == "" {
+		return LogSender{}
+	}
+
+	s := SMTPSender{Addr: c.Addr, From: c.From}
+	if c.User != "" {
+		host, _, _ := net.SplitHostPort(c.Addr)
+		s.Auth = smtp.PlainAuth("", c.User, c.Password, host)
+	}
+	return s
+}
@@ -0,0 +1,47 @@
+package match
+
+import (
+	"Remainwith/internal/handler"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultSuggestions = 10
+	maxSuggestions     = 50
+)
+
+// SuggestionsHandler serves GET /api/matches?limit=n with the user's
+// suggestions and the interests behind each one.
+func SuggestionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := handler.GetUserIDFromContext(r)
+	if userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	limit := defaultSuggestions
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxSuggestions {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	suggestions, err := Suggest(r.Context(), userID, limit)
+	if err != nil {
+		log.Printf("Suggestions: failed for user %d: %v", userID, err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if suggestions == nil {
+		suggestions = []Suggestion{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(suggestions)
+}
@@ -0,0 +1,177 @@
+// Package match ranks other users by how much they have in common with
+// someone, for the "people with similar interests" suggestions.
+//
+// A candidate's score is the weighted Jaccard overlap of the two interest
+// sets, where each interest counts with its category's weight, scaled by
+// how recently the candidate was active.
+package match
+
+import (
+	"Remainwith/db"
+	"context"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	// candidatePool is how many users sharing an interest are scored.
+	candidatePool = 200
+
+	// activityHalfLife is how long it takes an idle user's recency factor
+	// to fall halfway towards minRecency.
+	activityHalfLife = 7 * 24 * time.Hour
+	minRecency       = 0.25
+)
+
+// Suggestion is a public view of a suggested user. It never includes
+// contact details.
+type Suggestion struct {
+	ID              int      `json:"id"`
+	Name            string   `json:"name"`
+	SharedInterests []string `json:"shared_interests"`
+	Score           float64  `json:"score"`
+}
+
+// Catalog maps interest IDs to what scoring needs to know about them.
+type Catalog map[int]CatalogEntry
+
+// CatalogEntry is one interest in a Catalog.
+type CatalogEntry struct {
+	Name   string
+	Weight float64
+}
+
+// NewCatalog flattens categories into a Catalog, giving every interest
+// its category's weight.
+func NewCatalog(categories []db.Category) Catalog {
+	catalog := make(Catalog)
+	for _, c := range categories {
+		weight := c.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		for _, i := range c.Interests {
+			catalog[i.ID] = CatalogEntry{Name: i.Name, Weight: weight}
+		}
+	}
+	return catalog
+}
+
+// weight returns the weight of an interest, 1 if it is not in the catalog.
+func (c Catalog) weight(id int) float64 {
+	if e, ok := c[id]; ok {
+		return e.Weight
+	}
+	return 1
+}
+
+// Similarity returns the weighted Jaccard overlap of two interest sets and
+// the interests they share, in a's order.
+func (c Catalog) Similarity(a, b []int) (float64, []int) {
+	inA, inB := toSet(a), toSet(b)
+
+	var intersection, union float64
+	for id := range inA {
+		union += c.weight(id)
+		if inB[id] {
+			intersection += c.weight(id)
+		}
+	}
+	for id := range inB {
+		if !inA[id] {
+			union += c.weight(id)
+		}
+	}
+	if union == 0 {
+		return 0, nil
+	}
+
+	var shared []int
+	for _, id := range a {
+		if inB[id] {
+			shared = append(shared, id)
+			delete(inB, id)
+		}
+	}
+	return intersection / union, shared
+}
+
+func toSet(ids []int) map[int]bool {
+	set := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
+
+// Recency returns a factor between minRecency and 1 for a user last active
+// at lastActive. It halves its distance to minRecency every
+// activityHalfLife.
+func Recency(lastActive, now time.Time) float64 {
+	if lastActive.IsZero() {
+		return minRecency
+	}
+	idle := now.Sub(lastActive)
+	if idle <= 0 {
+		return 1
+	}
+	decay := math.Pow(0.5, float64(idle)/float64(activityHalfLife))
+	return minRecency + (1-minRecency)*decay
+}
+
+// Rank scores candidates against mine and returns the best limit of them,
+// highest score first.
+func Rank(mine []int, candidates []db.MatchCandidate, catalog Catalog, now time.Time, limit int) []Suggestion {
+	suggestions := make([]Suggestion, 0, len(candidates))
+	for _, c := range candidates {
+		similarity, shared := catalog.Similarity(mine, c.InterestIDs)
+		if len(shared) == 0 {
+			continue
+		}
+
+		names := make([]string, 0, len(shared))
+		for _, id := range shared {
+			if e, ok := catalog[id]; ok {
+				names = append(names, e.Name)
+			}
+		}
+		suggestions = append(suggestions, Suggestion{
+			ID:              c.UserID,
+			Name:            c.Name,
+			SharedInterests: names,
+			Score:           math.Round(similarity*Recency(c.LastActive, now)*1000) / 1000,
+		})
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		if suggestions[i].Score != suggestions[j].Score {
+			return suggestions[i].Score > suggestions[j].Score
+		}
+		return suggestions[i].ID < suggestions[j].ID
+	})
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions
+}
+
+// Suggest returns up to limit users for userID to connect with.
+func Suggest(ctx context.Context, userID int, limit int) ([]Suggestion, error) {
+	mine, err := db.GetUserInterestIDs(ctx, userID)
+	if err != nil || len(mine) == 0 {
+		return nil, err
+	}
+
+	candidates, err := db.GetMatchCandidates(ctx, userID, candidatePool)
+	if err != nil {
+		return nil, err
+	}
+
+	categories, err := db.GetInterestCatalog(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return Rank(mine, candidates, NewCatalog(categories), time.Now(), limit), nil
+}
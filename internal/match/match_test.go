@@ -0,0 +1,75 @@
+package match
+
+import (
+	"Remainwith/db"
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+var testCatalog = NewCatalog([]db.Category{
+	{Weight: 2, Interests: []db.Interest{{ID: 1, Name: "Anxiety"}, {ID: 2, Name: "Stress"}}},
+	{Weight: 1, Interests: []db.Interest{{ID: 3, Name: "Mindfulness"}, {ID: 4, Name: "Letting go"}}},
+})
+
+func TestSimilarityWeighsCategories(t *testing.T) {
+	// Shares Anxiety (2) out of Anxiety, Mindfulness and Letting go (4).
+	got, shared := testCatalog.Similarity([]int{1, 3}, []int{1, 4})
+	if math.Abs(got-0.5) > 1e-9 {
+		t.Errorf("similarity = %v, want 0.5", got)
+	}
+	if !slices.Equal(shared, []int{1}) {
+		t.Errorf("shared = %v, want [1]", shared)
+	}
+
+	// Sharing a lighter interest counts for less.
+	light, _ := testCatalog.Similarity([]int{1, 3}, []int{3, 2})
+	if light >= got {
+		t.Errorf("light overlap %v should score below heavy overlap %v", light, got)
+	}
+
+	if got, _ := testCatalog.Similarity(nil, nil); got != 0 {
+		t.Errorf("empty sets similarity = %v, want 0", got)
+	}
+}
+
+func TestRecencyDecays(t *testing.T) {
+	now := time.Now()
+	if got := Recency(now, now); got != 1 {
+		t.Errorf("active now = %v, want 1", got)
+	}
+	week := Recency(now.Add(-activityHalfLife), now)
+	if want := minRecency + (1-minRecency)/2; math.Abs(week-want) > 1e-9 {
+		t.Errorf("one half-life idle = %v, want %v", week, want)
+	}
+	if got := Recency(time.Time{}, now); got != minRecency {
+		t.Errorf("never active = %v, want %v", got, minRecency)
+	}
+}
+
+func TestRankOrdersAndExplains(t *testing.T) {
+	now := time.Now()
+	candidates := []db.MatchCandidate{
+		{UserID: 10, Name: "Idle twin", InterestIDs: []int{1, 3}, LastActive: now.Add(-60 * 24 * time.Hour)},
+		{UserID: 11, Name: "Active twin", InterestIDs: []int{1, 3}, LastActive: now},
+		{UserID: 12, Name: "Partial", InterestIDs: []int{1, 4}, LastActive: now},
+		{UserID: 13, Name: "Nothing shared", InterestIDs: []int{2}, LastActive: now},
+	}
+
+	got := Rank([]int{1, 3}, candidates, testCatalog, now, 10)
+	var ids []int
+	for _, s := range got {
+		ids = append(ids, s.ID)
+	}
+	if want := []int{11, 12, 10}; !slices.Equal(ids, want) {
+		t.Fatalf("order = %v, want %v", ids, want)
+	}
+	if !slices.Equal(got[0].SharedInterests, []string{"Anxiety", "Mindfulness"}) {
+		t.Errorf("shared interests = %v", got[0].SharedInterests)
+	}
+
+	if got := Rank([]int{1, 3}, candidates, testCatalog, now, 1); len(got) != 1 {
+		t.Errorf("limit ignored: got %d suggestions", len(got))
+	}
+}
@@ -3,11 +3,10 @@ package message
 import (
 	"Remainwith/db"
 	"Remainwith/internal/handler"
-	"html/template"
+	"Remainwith/internal/safety"
+	"errors"
 	"net/http"
 	"strconv"
-
-	"github.com/justinas/nosurf"
 )
 
 type Journal struct {
@@ -15,17 +14,21 @@ type Journal struct {
 	Desc  string
 }
 
-func JournalPageHandler(w http.ResponseWriter, r *http.Request) {
+// Journals serves the journal pages.
+type Journals struct {
+	store db.JournalStore
+}
+
+// NewJournals returns journal handlers backed by store.
+func NewJournals(store db.JournalStore) *Journals {
+	return &Journals{store: store}
+}
+
+func (j *Journals) JournalPageHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.NotFound(w, r)
 		return
 	}
-	tmpl, err := template.ParseFiles("frontend/journal.tmpl")
-	if err != nil {
-		http.Error(w, "issue faced for parsing journal", http.StatusInternalServerError)
-		return
-	}
-
 	// Get user claims from context
 	claims, ok := handler.UserFromContext(r.Context())
 	if !ok {
@@ -41,24 +44,22 @@ func JournalPageHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	userID := int(userIDFloat)
 
-	journals, err := db.GetJournalsByUserID(r.Context(), userID)
+	journals, err := j.store.GetJournalsByUserID(r.Context(), userID)
 	if err != nil {
 		http.Error(w, "Failed to fetch journals", http.StatusInternalServerError)
 		return
 	}
 
 	data := struct {
-		CSRFToken string
-		Journals  []db.Journal
+		handler.Page
+		Journals []db.Journal
 	}{
-		CSRFToken: nosurf.Token(r),
-		Journals:  journals,
+		Journals: journals,
 	}
-	tmpl.Execute(w, data)
-
+	handler.Render(w, r, http.StatusOK, "journal", &data)
 }
 
-func JournalHandler(w http.ResponseWriter, r *http.Request) {
+func (j *Journals) JournalHandler(w http.ResponseWriter, r *http.Request) {
 
 	if err := r.ParseForm(); err != nil {
 		http.Error(w, "Unable to parse form", http.StatusBadRequest)
@@ -88,17 +89,22 @@ func JournalHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	userID := int(userIDFloat)
 
-	_, err := db.NewJournal(r.Context(), userID, journal.Title, journal.Desc)
+	_, err := j.store.NewJournal(r.Context(), userID, journal.Title, journal.Desc)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	if safety.MentionsCrisis(journal.Title + "\n" + journal.Desc) {
+		http.Redirect(w, r, safety.SupportURL("journal"), http.StatusSeeOther)
+		return
+	}
+
 	http.Redirect(w, r, "/journal", http.StatusSeeOther)
 
 }
 
-func UpdateJournalHandler(w http.ResponseWriter, r *http.Request) {
+func (j *Journals) UpdateJournalHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.NotFound(w, r)
 		return
@@ -137,16 +143,25 @@ func UpdateJournalHandler(w http.ResponseWriter, r *http.Request) {
 	userID := int(userIDFloat)
 
 	// Update journal
-	err = db.UpdateJournal(r.Context(), id, userID, title, desc)
+	err = j.store.UpdateJournal(r.Context(), id, userID, title, desc)
+	if errors.Is(err, db.ErrJournalNotFound) {
+		http.NotFound(w, r)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	if safety.MentionsCrisis(title + "\n" + desc) {
+		http.Redirect(w, r, safety.SupportURL("journal"), http.StatusSeeOther)
+		return
+	}
+
 	http.Redirect(w, r, "/journal", http.StatusSeeOther)
 }
 
-func DeleteJournalHandler(w http.ResponseWriter, r *http.Request) {
+func (j *Journals) DeleteJournalHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.NotFound(w, r)
 		return
@@ -173,7 +188,11 @@ func DeleteJournalHandler(w http.ResponseWriter, r *http.Request) {
 	userID := int(userIDFloat)
 
 	// Delete journal
-	err = db.DeleteJournal(r.Context(), id, userID)
+	err = j.store.DeleteJournal(r.Context(), id, userID)
+	if errors.Is(err, db.ErrJournalNotFound) {
+		http.NotFound(w, r)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
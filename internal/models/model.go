@@ -3,9 +3,44 @@ package models
 import "time"
 
 type Message struct {
-	ID         uint
-	SenderID   string
-	ReceiverID string
-	Content    string
-	CreatedAt  time.Time
+	ID         uint      `json:"id,omitempty"`
+	Type       string    `json:"type,omitempty"`
+	ClientID   string    `json:"clientID,omitempty"` // client-generated, used for idempotent retries
+	Room       string    `json:"room,omitempty"`
+	SenderID   string    `json:"senderID"`
+	SenderName string    `json:"senderName,omitempty"`
+	ReceiverID string    `json:"receiverID,omitempty"` // empty for room broadcasts
+	Content    string    `json:"content,omitempty"`
+	Status     string    `json:"status,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+
+	// Resources is a support card attached by the chat safety filters
+	// when a message suggests someone may be at risk. It is not stored.
+	Resources *ResourceCard `json:"resources,omitempty"`
+
+	// Members lists who is in a room, for roster frames.
+	Members []Member `json:"members,omitempty"`
+
+	// Locale is the sender's country code, used to pick Resources.
+	Locale string `json:"-"`
+}
+
+// Member is one user in a room roster.
+type Member struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Idle bool   `json:"idle,omitempty"`
+}
+
+// ResourceCard points someone towards support.
+type ResourceCard struct {
+	Title string         `json:"title"`
+	Body  string         `json:"body"`
+	Links []ResourceLink `json:"links,omitempty"`
+}
+
+// ResourceLink is a single helpline or website on a ResourceCard.
+type ResourceLink struct {
+	Label string `json:"label"`
+	URL   string `json:"url"`
 }
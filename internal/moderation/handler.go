@@ -0,0 +1,165 @@
+// Package moderation serves the blocking and reporting APIs and keeps the
+// websocket hub in step with them.
+package moderation
+
+import (
+	"Remainwith/db"
+	"Remainwith/internal/handler"
+	"Remainwith/internal/ws"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxReportDetails is how many characters of a report's details are kept.
+const maxReportDetails = 1000
+
+// Handlers serves the /api/blocks and /api/reports endpoints.
+type Handlers struct {
+	hub *ws.Hub
+}
+
+// NewHandlers returns handlers that apply block changes to hub.
+func NewHandlers(hub *ws.Hub) *Handlers {
+	return &Handlers{hub: hub}
+}
+
+type blockedUser struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// ListBlocksHandler serves GET /api/blocks with the users the caller has blocked.
+func (m *Handlers) ListBlocksHandler(w http.ResponseWriter, r *http.Request) {
+	userID := handler.GetUserIDFromContext(r)
+	if userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	users, err := db.GetBlockedUsers(r.Context(), userID)
+	if err != nil {
+		log.Printf("GetBlockedUsers: %v", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	blocked := make([]blockedUser, 0, len(users))
+	for _, u := range users {
+		blocked = append(blocked, blockedUser{ID: u.ID, Name: u.Name})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(blocked)
+}
+
+// BlockHandler serves POST /api/blocks with a JSON body of {"user_id": n}.
+func (m *Handlers) BlockHandler(w http.ResponseWriter, r *http.Request) {
+	userID := handler.GetUserIDFromContext(r)
+	if userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		UserID int `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID <= 0 {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if req.UserID == userID {
+		http.Error(w, "You cannot block yourself", http.StatusBadRequest)
+		return
+	}
+
+	if err := db.BlockUser(r.Context(), userID, req.UserID); err != nil {
+		log.Printf("BlockUser: %v", err)
+		http.Error(w, "Failed to block user", http.StatusInternalServerError)
+		return
+	}
+	m.hub.RefreshBlocks(strconv.Itoa(userID), strconv.Itoa(req.UserID))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UnblockHandler serves DELETE /api/blocks/{id}.
+func (m *Handlers) UnblockHandler(w http.ResponseWriter, r *http.Request) {
+	userID := handler.GetUserIDFromContext(r)
+	if userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	blockedID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || blockedID <= 0 {
+		http.Error(w, "Invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	if err := db.UnblockUser(r.Context(), userID, blockedID); err != nil {
+		log.Printf("UnblockUser: %v", err)
+		http.Error(w, "Failed to unblock user", http.StatusInternalServerError)
+		return
+	}
+	m.hub.RefreshBlocks(strconv.Itoa(userID), strconv.Itoa(blockedID))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReportHandler serves POST /api/reports with a JSON body of
+// {"message_id": n, "reason": "...", "details": "..."}.
+func (m *Handlers) ReportHandler(w http.ResponseWriter, r *http.Request) {
+	userID := handler.GetUserIDFromContext(r)
+	if userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		MessageID int    `json:"message_id"`
+		Reason    string `json:"reason"`
+		Details   string `json:"details"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.MessageID <= 0 {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if _, ok := db.ReportReasons[req.Reason]; !ok {
+		http.Error(w, "Unknown reason", http.StatusBadRequest)
+		return
+	}
+	details := truncate(strings.TrimSpace(req.Details), maxReportDetails)
+
+	report := &db.Report{
+		ReporterID: userID,
+		MessageID:  req.MessageID,
+		Reason:     req.Reason,
+		Details:    details,
+	}
+	if err := db.NewReport(r.Context(), report); err != nil {
+		if errors.Is(err, db.ErrMessageNotFound) {
+			http.Error(w, "Message not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("NewReport: %v", err)
+		http.Error(w, "Failed to file report", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"id": report.ID, "status": report.Status})
+}
+
+// truncate shortens s to at most n characters, never splitting one.
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
@@ -0,0 +1,18 @@
+package moderation
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateKeepsCharactersWhole(t *testing.T) {
+	details := strings.Repeat("é", maxReportDetails+5)
+	got := truncate(details, maxReportDetails)
+	if !utf8.ValidString(got) || utf8.RuneCountInString(got) != maxReportDetails {
+		t.Errorf("truncated to %d runes, valid %v", utf8.RuneCountInString(got), utf8.ValidString(got))
+	}
+	if got := truncate("short", maxReportDetails); got != "short" {
+		t.Errorf("short details became %q", got)
+	}
+}
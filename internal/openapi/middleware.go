@@ -0,0 +1,157 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// maxValidatedBody is the largest request body the middleware will buffer.
+const maxValidatedBody = 1 << 20
+
+// Validator checks /api/ traffic against the document. It is meant for
+// development: it buffers every API response, so production leaves it off.
+type Validator struct {
+	doc *Document
+
+	// Logf reports undocumented routes and responses that don't match the
+	// document. Defaults to log.Printf; contract tests set it to t.Errorf.
+	Logf func(format string, args ...any)
+}
+
+// NewValidator returns a validator for doc.
+func NewValidator(doc *Document) *Validator {
+	return &Validator{doc: doc, Logf: log.Printf}
+}
+
+// Middleware rejects JSON request bodies that don't match the document
+// with a 400, and logs responses that don't. Requests outside /api/ pass
+// straight through.
+func (v *Validator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		op, template := v.doc.Find(r.Method, r.URL.Path)
+		if op == nil {
+			v.Logf("openapi: %s %s is not documented", r.Method, r.URL.Path)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if problem := v.checkRequest(r, op); problem != "" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]any{
+				"error": map[string]string{"code": "validation_failed", "message": problem},
+			})
+			return
+		}
+
+		rec := &recorder{header: make(http.Header), status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		v.checkResponse(r.Method, template, op, rec)
+
+		for k, vals := range rec.header {
+			w.Header()[k] = vals
+		}
+		w.WriteHeader(rec.status)
+		w.Write(rec.body.Bytes())
+	})
+}
+
+// checkRequest validates a JSON body and returns a problem for the client,
+// or "" if the body is fine or not JSON.
+func (v *Validator) checkRequest(r *http.Request, op *Operation) string {
+	if op.RequestBody == nil {
+		return ""
+	}
+	media, ok := op.RequestBody.Content["application/json"]
+	if !ok || media.Schema == nil || !isJSON(r.Header.Get("Content-Type")) {
+		return ""
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxValidatedBody+1))
+	if err != nil || len(body) > maxValidatedBody {
+		// Leave oversize or broken bodies to the handler's own checks
+		r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+		return ""
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		if op.RequestBody.Required {
+			return "request body is required"
+		}
+		return ""
+	}
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return "request body is not valid JSON"
+	}
+	if err := v.doc.Validate(media.Schema, value); err != nil {
+		return "request body: " + err.Error()
+	}
+	return ""
+}
+
+// checkResponse logs a response whose status or body isn't documented.
+func (v *Validator) checkResponse(method, template string, op *Operation, rec *recorder) {
+	resp, ok := v.doc.Response(op, rec.status)
+	if !ok {
+		v.Logf("openapi: %s %s returned undocumented status %d", method, template, rec.status)
+		return
+	}
+	if !isJSON(rec.header.Get("Content-Type")) {
+		return
+	}
+	media, ok := resp.Content["application/json"]
+	if !ok || media.Schema == nil {
+		v.Logf("openapi: %s %s returned JSON for status %d, which documents none", method, template, rec.status)
+		return
+	}
+
+	var value any
+	if err := json.Unmarshal(rec.body.Bytes(), &value); err != nil {
+		v.Logf("openapi: %s %s returned invalid JSON: %v", method, template, err)
+		return
+	}
+	if err := v.doc.Validate(media.Schema, value); err != nil {
+		v.Logf("openapi: %s %s response %d does not match the document: %v", method, template, rec.status, err)
+	}
+}
+
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "application/json"
+}
+
+// recorder buffers a response so it can be checked before it is sent.
+type recorder struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	return r.body.Write(b)
+}
@@ -0,0 +1,165 @@
+// Package openapi holds the OpenAPI 3.1 description of the JSON endpoints,
+// serves it at /api/openapi.json and, in development, checks live traffic
+// against it.
+//
+// The document is written by hand in openapi.json next to this file. The
+// contract test in this package fails when a route under /api/ is
+// registered in main.go without being documented, or the other way round.
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+//go:embed openapi.json
+var specJSON []byte
+
+// Methods are the HTTP methods an OpenAPI path item can describe.
+var Methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// Document is the parsed API description, reduced to what validation and
+// the contract test need.
+type Document struct {
+	OpenAPI string `json:"openapi"`
+
+	// Paths maps a path template to its operations by lowercase method.
+	Paths      map[string]map[string]*Operation `json:"-"`
+	Components struct {
+		Schemas   map[string]*Schema   `json:"schemas"`
+		Responses map[string]*Response `json:"responses"`
+	} `json:"components"`
+}
+
+// Operation is one method on one path.
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	RequestBody *RequestBody         `json:"requestBody"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// RequestBody describes what an operation accepts.
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response describes one status code of an operation.
+type Response struct {
+	Ref         string               `json:"$ref"`
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+// MediaType is the body for one content type.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Load parses the embedded document.
+func Load() (*Document, error) {
+	var raw struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(specJSON, &raw); err != nil {
+		return nil, fmt.Errorf("openapi.json: %w", err)
+	}
+
+	doc := &Document{Paths: make(map[string]map[string]*Operation)}
+	if err := json.Unmarshal(specJSON, doc); err != nil {
+		return nil, fmt.Errorf("openapi.json: %w", err)
+	}
+
+	// Path items may also hold summaries and parameters; keep the methods
+	for path, item := range raw.Paths {
+		doc.Paths[path] = make(map[string]*Operation)
+		for key, value := range item {
+			if !isMethod(key) {
+				continue
+			}
+			var op Operation
+			if err := json.Unmarshal(value, &op); err != nil {
+				return nil, fmt.Errorf("openapi.json: %s %s: %w", key, path, err)
+			}
+			doc.Paths[path][key] = &op
+		}
+	}
+	return doc, nil
+}
+
+func isMethod(key string) bool {
+	for _, m := range Methods {
+		if key == m {
+			return true
+		}
+	}
+	return false
+}
+
+// Find returns the operation for a request and the path template it
+// matched. Literal segments win over {parameters}, as in http.ServeMux.
+func (d *Document) Find(method, path string) (*Operation, string) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	method = strings.ToLower(method)
+
+	var best *Operation
+	bestTemplate, bestLiterals := "", -1
+	for template, ops := range d.Paths {
+		op, ok := ops[method]
+		if !ok {
+			continue
+		}
+		literals, ok := matchTemplate(strings.Split(strings.Trim(template, "/"), "/"), segments)
+		if ok && literals > bestLiterals {
+			best, bestTemplate, bestLiterals = op, template, literals
+		}
+	}
+	return best, bestTemplate
+}
+
+// matchTemplate reports whether segments fit the template and how many
+// of its segments were literal.
+func matchTemplate(template, segments []string) (int, bool) {
+	if len(template) != len(segments) {
+		return 0, false
+	}
+	literals := 0
+	for i, t := range template {
+		if strings.HasPrefix(t, "{") && strings.HasSuffix(t, "}") {
+			if segments[i] == "" {
+				return 0, false
+			}
+			continue
+		}
+		if t != segments[i] {
+			return 0, false
+		}
+		literals++
+	}
+	return literals, true
+}
+
+// Response returns the documented response for status, falling back to
+// "default", with any $ref followed.
+func (d *Document) Response(op *Operation, status int) (*Response, bool) {
+	resp, ok := op.Responses[fmt.Sprint(status)]
+	if !ok {
+		resp, ok = op.Responses["default"]
+	}
+	if !ok {
+		return nil, false
+	}
+	if name, isRef := strings.CutPrefix(resp.Ref, "#/components/responses/"); isRef {
+		resp, ok = d.Components.Responses[name]
+	}
+	return resp, ok && resp != nil
+}
+
+// Handler serves GET /api/openapi.json.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(specJSON)
+}
@@ -0,0 +1,216 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Schema is the subset of JSON Schema the API document uses. Keywords it
+// doesn't model are ignored rather than rejected, so the validator errs on
+// the side of passing.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 typeList           `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *additional        `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+}
+
+// typeList is a JSON Schema "type", which may be one name or several.
+type typeList []string
+
+func (t *typeList) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*t = typeList{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return fmt.Errorf("type must be a string or an array of strings")
+	}
+	*t = many
+	return nil
+}
+
+// additional is "additionalProperties": either a bool or a schema that
+// every property not in "properties" must match.
+type additional struct {
+	allowed bool
+	schema  *Schema
+}
+
+func (a *additional) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &a.allowed); err == nil {
+		return nil
+	}
+	a.allowed = true
+	return json.Unmarshal(data, &a.schema)
+}
+
+// ValidationError describes where a value departs from its schema.
+type ValidationError struct {
+	Path    string // JSON pointer-ish location, "" for the root
+	Problem string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Problem
+	}
+	return e.Path + ": " + e.Problem
+}
+
+// Validate checks v, as decoded by encoding/json into an any, against s.
+func (d *Document) Validate(s *Schema, v any) error {
+	return d.validate(s, v, "")
+}
+
+func (d *Document) validate(s *Schema, v any, path string) error {
+	s, err := d.resolve(s)
+	if err != nil {
+		return err
+	}
+	fail := func(format string, args ...any) error {
+		return &ValidationError{Path: path, Problem: fmt.Sprintf(format, args...)}
+	}
+
+	if len(s.Type) > 0 && !slices.ContainsFunc(s.Type, func(t string) bool { return hasType(v, t) }) {
+		return fail("expected %s, got %s", strings.Join(s.Type, " or "), typeName(v))
+	}
+	if len(s.Enum) > 0 && !slices.Contains(s.Enum, v) {
+		return fail("must be one of %v", s.Enum)
+	}
+
+	switch v := v.(type) {
+	case string:
+		n := len([]rune(v))
+		if s.MinLength != nil && n < *s.MinLength {
+			return fail("must be at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			return fail("must be at most %d characters", *s.MaxLength)
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			return fail("must be at least %v", *s.Minimum)
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			return fail("must be at most %v", *s.Maximum)
+		}
+	case []any:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			return fail("must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			return fail("must have at most %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				if err := d.validate(s.Items, item, fmt.Sprintf("%s/%d", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				return fail("missing required property %q", name)
+			}
+		}
+		// Sorted so the first problem reported is stable
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := s.Properties[name]
+			if !ok && s.AdditionalProperties != nil {
+				if !s.AdditionalProperties.allowed {
+					return fail("unexpected property %q", name)
+				}
+				prop, ok = s.AdditionalProperties.schema, s.AdditionalProperties.schema != nil
+			}
+			if !ok {
+				continue
+			}
+			if err := d.validate(prop, v[name], path+"/"+name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolve follows $ref to a schema in components.
+func (d *Document) resolve(s *Schema) (*Schema, error) {
+	for seen := 0; s.Ref != ""; seen++ {
+		name, ok := strings.CutPrefix(s.Ref, "#/components/schemas/")
+		target := d.Components.Schemas[name]
+		if !ok || target == nil {
+			return nil, fmt.Errorf("unresolvable $ref %q", s.Ref)
+		}
+		if seen > 32 {
+			return nil, fmt.Errorf("$ref cycle at %q", s.Ref)
+		}
+		s = target
+	}
+	return s, nil
+}
+
+func hasType(v any, t string) bool {
+	switch t {
+	case "null":
+		return v == nil
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	}
+	return false
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,147 @@
+package presence
+
+import (
+	"Remainwith/db"
+	"Remainwith/internal/handler"
+	"Remainwith/internal/ws"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxSoloDuration caps what a solo session may log.
+const maxSoloDuration = 4 * time.Hour
+
+// JoinHandler matches the user into a presence room and redirects there.
+// With ?by=interests, rooms sharing the user's interests are preferred.
+func (m *Manager) JoinHandler(w http.ResponseWriter, r *http.Request) {
+	userID := handler.GetUserIDFromContext(r)
+	if userID == 0 {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	var interests []string
+	if r.URL.Query().Get("by") == "interests" {
+		var err error
+		interests, err = db.GetUserInterests(r.Context(), userID)
+		if err != nil {
+			log.Printf("PresenceJoin: failed to get interests for user %d: %v", userID, err)
+		}
+	}
+
+	room := m.Match(strconv.Itoa(userID), interests)
+	http.Redirect(w, r, "/presence/room/"+room.ID, http.StatusSeeOther)
+}
+
+// RoomPageHandler renders a presence room.
+func (m *Manager) RoomPageHandler(w http.ResponseWriter, r *http.Request) {
+	userID := handler.GetUserIDFromContext(r)
+	if userID == 0 {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	room, ok := m.Room(r.PathValue("id"))
+	if !ok {
+		// Rooms are ephemeral; find the user a new one
+		http.Redirect(w, r, "/presence/join", http.StatusSeeOther)
+		return
+	}
+
+	reactions := make([]string, 0, len(ws.Reactions))
+	for reaction := range ws.Reactions {
+		reactions = append(reactions, reaction)
+	}
+
+	data := struct {
+		handler.Page
+		RoomID        string
+		HubRoom       string
+		Capacity      int
+		Interests     []string
+		Reactions     []string
+		CurrentUserID int
+	}{
+		RoomID:        room.ID,
+		HubRoom:       room.HubRoom(),
+		Capacity:      room.Capacity,
+		Interests:     room.Interests,
+		Reactions:     reactions,
+		CurrentUserID: userID,
+	}
+
+	handler.Render(w, r, http.StatusOK, "presence", &data)
+}
+
+// SoloPageHandler renders the timed solo session page with recent history.
+func SoloPageHandler(w http.ResponseWriter, r *http.Request) {
+	userID := handler.GetUserIDFromContext(r)
+	if userID == 0 {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	history, err := db.GetPresenceSessions(r.Context(), userID, 10)
+	if err != nil {
+		log.Printf("PresenceSolo: failed to get history for user %d: %v", userID, err)
+		history = nil
+	}
+
+	data := struct {
+		handler.Page
+		History []db.PresenceSession
+	}{
+		History: history,
+	}
+
+	handler.Render(w, r, http.StatusOK, "presence_solo", &data)
+}
+
+// CompleteSoloHandler logs a finished solo session. It expects the form
+// fields started_at (unix seconds) and duration_seconds.
+func CompleteSoloHandler(w http.ResponseWriter, r *http.Request) {
+	userID := handler.GetUserIDFromContext(r)
+	if userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Unable to parse form", http.StatusBadRequest)
+		return
+	}
+
+	startedUnix, err := strconv.ParseInt(r.FormValue("started_at"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid start time", http.StatusBadRequest)
+		return
+	}
+	seconds, err := strconv.Atoi(r.FormValue("duration_seconds"))
+	if err != nil || seconds <= 0 {
+		http.Error(w, "Invalid duration", http.StatusBadRequest)
+		return
+	}
+
+	startedAt := time.Unix(startedUnix, 0)
+	duration := time.Duration(seconds) * time.Second
+	if duration > maxSoloDuration || startedAt.After(time.Now()) || time.Since(startedAt) > maxSoloDuration+time.Hour {
+		http.Error(w, "Session out of range", http.StatusBadRequest)
+		return
+	}
+
+	err = db.LogPresenceSession(r.Context(), db.PresenceSession{
+		UserID:    userID,
+		Mode:      "solo",
+		StartedAt: startedAt,
+		Duration:  duration,
+	})
+	if err != nil {
+		log.Printf("PresenceSolo: failed to log session for user %d: %v", userID, err)
+		http.Error(w, "Failed to save session", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
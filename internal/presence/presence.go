@@ -0,0 +1,265 @@
+package presence
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"Remainwith/internal/ws"
+)
+
+// HubRoomPrefix namespaces presence rooms inside the websocket hub.
+const HubRoomPrefix = "presence:"
+
+// Room is a silent co-presence space with a fixed number of seats.
+type Room struct {
+	ID        string
+	Capacity  int
+	Interests []string
+	CreatedAt time.Time
+}
+
+// HubRoom is the name of the hub room backing r.
+func (r *Room) HubRoom() string {
+	return HubRoomPrefix + r.ID
+}
+
+// reservation holds a seat for a matched user until their websocket connects.
+type reservation struct {
+	roomID  string
+	expires time.Time
+}
+
+// Manager matches users into presence rooms and keeps the hub's room
+// options in sync with them.
+type Manager struct {
+	// capacity is the number of seats in newly created rooms.
+	//
+	// Defaults to 6.
+	capacity int
+
+	// reservationTTL is how long a matched seat is held before the user
+	// has to have connected.
+	//
+	// Defaults to 30 seconds.
+	reservationTTL time.Duration
+
+	// emptyTTL is how long an empty room survives before it is removed.
+	//
+	// Defaults to 2 minutes.
+	emptyTTL time.Duration
+
+	hub *ws.Hub
+
+	mu           sync.Mutex
+	rooms        map[string]*Room
+	reservations map[string]reservation // by user ID
+	emptySince   map[string]time.Time   // by room ID
+}
+
+// NewManager constructs a Manager with the defaults and starts sweeping
+// empty rooms.
+func NewManager(hub *ws.Hub) *Manager {
+	m := &Manager{
+		capacity:       6,
+		reservationTTL: 30 * time.Second,
+		emptyTTL:       2 * time.Minute,
+		hub:            hub,
+		rooms:          make(map[string]*Room),
+		reservations:   make(map[string]reservation),
+		emptySince:     make(map[string]time.Time),
+	}
+	go m.sweepLoop()
+	return m
+}
+
+// Room returns the room with id, if it exists.
+func (m *Manager) Room(id string) (*Room, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.rooms[id]
+	return r, ok
+}
+
+// Match finds a room with a free seat for userID and reserves it. When
+// interests are given, rooms sharing more of them are preferred; otherwise,
+// and as a tie-break, fuller rooms win so people are not left sitting alone.
+// A new room is created only when every room is full.
+func (m *Manager) Match(userID string, interests []string) *Room {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expireReservationsLocked(now)
+
+	// Already seated or holding a seat
+	if res, ok := m.reservations[userID]; ok {
+		if r, ok := m.rooms[res.roomID]; ok {
+			return r
+		}
+	}
+	for _, r := range m.rooms {
+		for _, member := range m.hub.RoomMembers(r.HubRoom()) {
+			if member == userID {
+				return r
+			}
+		}
+	}
+
+	type candidate struct {
+		room      *Room
+		shared    int
+		occupancy int
+	}
+	var candidates []candidate
+	for _, r := range m.rooms {
+		occ := m.occupancyLocked(r)
+		if occ >= r.Capacity {
+			continue
+		}
+		candidates = append(candidates, candidate{r, sharedCount(r.Interests, interests), occ})
+	}
+
+	var room *Room
+	if len(candidates) > 0 {
+		sort.Slice(candidates, func(i, j int) bool {
+			a, b := candidates[i], candidates[j]
+			if a.shared != b.shared {
+				return a.shared > b.shared
+			}
+			if a.occupancy != b.occupancy {
+				return a.occupancy > b.occupancy
+			}
+			return a.room.CreatedAt.Before(b.room.CreatedAt)
+		})
+		room = candidates[0].room
+	} else {
+		room = m.createLocked(interests, now)
+	}
+
+	m.reservations[userID] = reservation{roomID: room.ID, expires: now.Add(m.reservationTTL)}
+	delete(m.emptySince, room.ID)
+	return room
+}
+
+// occupancyLocked counts connected members plus outstanding reservations.
+func (m *Manager) occupancyLocked(r *Room) int {
+	seats := make(map[string]struct{})
+	for _, member := range m.hub.RoomMembers(r.HubRoom()) {
+		seats[member] = struct{}{}
+	}
+	for userID, res := range m.reservations {
+		if res.roomID == r.ID {
+			seats[userID] = struct{}{}
+		}
+	}
+	return len(seats)
+}
+
+func (m *Manager) createLocked(interests []string, now time.Time) *Room {
+	r := &Room{
+		ID:        newRoomID(),
+		Capacity:  m.capacity,
+		Interests: append([]string(nil), interests...),
+		CreatedAt: now,
+	}
+	m.rooms[r.ID] = r
+	m.hub.ConfigureRoom(r.HubRoom(), ws.RoomOptions{
+		Capacity:      r.Capacity,
+		ReactionsOnly: true,
+		Admit: func(userID string) bool {
+			return m.claim(r.ID, userID)
+		},
+	})
+	log.Printf("Presence room %s created (capacity %d)", r.ID, r.Capacity)
+	return r
+}
+
+// claim uses up userID's reservation for room id, reporting whether they
+// had one. Seats are only ever handed out by Match.
+func (m *Manager) claim(id, userID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expireReservationsLocked(time.Now())
+	res, ok := m.reservations[userID]
+	if !ok || res.roomID != id {
+		return false
+	}
+	delete(m.reservations, userID)
+	return true
+}
+
+func (m *Manager) expireReservationsLocked(now time.Time) {
+	for userID, res := range m.reservations {
+		if now.After(res.expires) {
+			delete(m.reservations, userID)
+		}
+	}
+}
+
+// sweep releases reservations of users who have connected and removes rooms
+// that have stayed empty for longer than emptyTTL.
+func (m *Manager) sweep(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expireReservationsLocked(now)
+
+	for id, r := range m.rooms {
+		members := m.hub.RoomMembers(r.HubRoom())
+		for _, member := range members {
+			if res, ok := m.reservations[member]; ok && res.roomID == id {
+				delete(m.reservations, member)
+			}
+		}
+
+		if m.occupancyLocked(r) > 0 {
+			delete(m.emptySince, id)
+			continue
+		}
+		since, ok := m.emptySince[id]
+		if !ok {
+			m.emptySince[id] = now
+			continue
+		}
+		if now.Sub(since) >= m.emptyTTL {
+			delete(m.rooms, id)
+			delete(m.emptySince, id)
+			m.hub.RemoveRoom(r.HubRoom())
+			log.Printf("Presence room %s closed after being empty", id)
+		}
+	}
+}
+
+func (m *Manager) sweepLoop() {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		m.sweep(now)
+	}
+}
+
+func sharedCount(a, b []string) int {
+	set := make(map[string]struct{}, len(a))
+	for _, s := range a {
+		set[s] = struct{}{}
+	}
+	n := 0
+	for _, s := range b {
+		if _, ok := set[s]; ok {
+			n++
+		}
+	}
+	return n
+}
+
+func newRoomID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,64 @@
+package presence
+
+import (
+	"testing"
+
+	"Remainwith/internal/ws"
+)
+
+func TestMatchFillsRoomsBeforeCreatingNew(t *testing.T) {
+	m := NewManager(ws.NewHub())
+	m.capacity = 2
+
+	a := m.Match("1", nil)
+	b := m.Match("2", nil)
+	c := m.Match("3", nil)
+
+	if a != b {
+		t.Fatalf("users 1 and 2 were put in different rooms with a free seat")
+	}
+	if c == a {
+		t.Fatalf("user 3 was put in a full room")
+	}
+	if again := m.Match("1", nil); again != a {
+		t.Fatalf("re-matching user 1 moved them to another room")
+	}
+}
+
+func TestMatchPrefersSharedInterests(t *testing.T) {
+	m := NewManager(ws.NewHub())
+
+	anxious := m.Match("1", []string{"Anxiety", "Stress"})
+	anxious.Capacity = 1 // force the next user into a fresh room
+	students := m.Match("2", []string{"Student life"})
+	anxious.Capacity = 6
+
+	if students == anxious {
+		t.Fatal("user 2 was put in a full room")
+	}
+
+	if got := m.Match("3", []string{"Student life", "Loneliness"}); got != students {
+		t.Fatalf("user 3 matched room %s, want the student life room %s", got.ID, students.ID)
+	}
+	if got := m.Match("4", []string{"Stress"}); got != anxious {
+		t.Fatalf("user 4 matched room %s, want the stress room %s", got.ID, anxious.ID)
+	}
+}
+
+func TestOnlyReservedUsersTakeSeats(t *testing.T) {
+	m := NewManager(ws.NewHub())
+	room := m.Match("1", nil)
+
+	if m.claim(room.ID, "2") {
+		t.Error("user 2 took a seat without a reservation")
+	}
+	if m.claim("elsewhere", "1") {
+		t.Error("user 1's reservation was honoured in another room")
+	}
+	if !m.claim(room.ID, "1") {
+		t.Fatal("user 1 was refused their reserved seat")
+	}
+	if m.claim(room.ID, "1") {
+		t.Error("a reservation was used twice")
+	}
+}
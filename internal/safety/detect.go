@@ -0,0 +1,33 @@
+package safety
+
+import (
+	"strings"
+	"unicode"
+)
+
+// crisisPhrases are matched against normalised text. They are deliberately
+// broad: resources shown unnecessarily cost little, missing someone costs
+// a great deal.
+var crisisPhrases = []string{
+	"kill myself", "killing myself", "end my life", "ending my life",
+	"want to die", "wanna die", "wish i was dead", "wish i were dead",
+	"suicide", "suicidal", "take my own life",
+	"self harm", "selfharm", "hurt myself", "hurting myself", "cut myself", "cutting myself",
+	"no reason to live", "nothing to live for", "better off dead", "better off without me",
+	"can't go on", "cant go on", "don't want to be here anymore", "dont want to be here anymore",
+}
+
+// MentionsCrisis reports whether text contains language suggesting the
+// writer, or someone they mention, may be at risk of suicide or self-harm.
+func MentionsCrisis(text string) bool {
+	text = strings.ReplaceAll(strings.ToLower(text), "’", "'")
+	text = strings.Join(strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	}), " ")
+	for _, phrase := range crisisPhrases {
+		if strings.Contains(text, phrase) {
+			return true
+		}
+	}
+	return false
+}
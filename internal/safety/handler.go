@@ -0,0 +1,171 @@
+package safety
+
+import (
+	"Remainwith/db"
+	"Remainwith/internal/handler"
+	"Remainwith/internal/models"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxPlanSection caps each section of a safety plan.
+const maxPlanSection = 4000
+
+// continueTargets are the pages the support interstitial may send people
+// back to, keyed by the from query parameter.
+var continueTargets = map[string]string{
+	"journal": "/journal",
+	"chat":    "/campfire",
+}
+
+// cardView is a ResourceCard ready for html/template, which would
+// otherwise refuse the tel: and sms: links helplines rely on.
+type cardView struct {
+	Title string
+	Body  string
+	Links []linkView
+}
+
+type linkView struct {
+	Label string
+	URL   template.URL
+}
+
+// linkSchemes are the URL schemes a resource link may use.
+var linkSchemes = []string{"https:", "http:", "tel:", "sms:"}
+
+func viewCard(card models.ResourceCard) cardView {
+	v := cardView{Title: card.Title, Body: card.Body}
+	for _, l := range card.Links {
+		for _, scheme := range linkSchemes {
+			if strings.HasPrefix(strings.ToLower(l.URL), scheme) {
+				v.Links = append(v.Links, linkView{Label: l.Label, URL: template.URL(l.URL)})
+				break
+			}
+		}
+	}
+	return v
+}
+
+// Handlers serves the support interstitial and the safety plan pages.
+type Handlers struct {
+	resources *Registry
+}
+
+// NewHandlers returns handlers that show cards from resources.
+func NewHandlers(resources *Registry) *Handlers {
+	return &Handlers{resources: resources}
+}
+
+// SupportURL is where a save is redirected when it mentions a crisis.
+func SupportURL(from string) string {
+	return "/support?from=" + from
+}
+
+// SupportPageHandler renders the gentle interstitial shown after someone
+// writes about self-harm, with resources for their country.
+func (h *Handlers) SupportPageHandler(w http.ResponseWriter, r *http.Request) {
+	userID := handler.GetUserIDFromContext(r)
+	if userID == 0 {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	continueURL, ok := continueTargets[r.URL.Query().Get("from")]
+	if !ok {
+		continueURL = "/dashboard"
+	}
+
+	plan, err := db.GetSafetyPlan(r.Context(), userID)
+	if err != nil {
+		log.Printf("Support: failed to get safety plan for user %d: %v", userID, err)
+		plan = &db.SafetyPlan{}
+	}
+
+	data := struct {
+		handler.Page
+		Card        cardView
+		ContinueURL string
+		HasPlan     bool
+	}{
+		Card:        viewCard(h.resources.ForRequest(r)),
+		ContinueURL: continueURL,
+		HasPlan:     !plan.UpdatedAt.IsZero(),
+	}
+
+	handler.Render(w, r, http.StatusOK, "support", &data)
+}
+
+// SafetyPlanPageHandler renders the user's safety plan for editing.
+func (h *Handlers) SafetyPlanPageHandler(w http.ResponseWriter, r *http.Request) {
+	userID := handler.GetUserIDFromContext(r)
+	if userID == 0 {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	plan, err := db.GetSafetyPlan(r.Context(), userID)
+	if err != nil {
+		log.Printf("SafetyPlan: failed to get plan for user %d: %v", userID, err)
+		http.Error(w, "Failed to load your safety plan", http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		handler.Page
+		Plan  *db.SafetyPlan
+		Card  cardView
+		Saved bool
+	}{
+		Plan:  plan,
+		Card:  viewCard(h.resources.ForRequest(r)),
+		Saved: r.URL.Query().Get("saved") == "1",
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	handler.Render(w, r, http.StatusOK, "safety_plan", &data)
+}
+
+// SaveSafetyPlanHandler stores the submitted safety plan.
+func (h *Handlers) SaveSafetyPlanHandler(w http.ResponseWriter, r *http.Request) {
+	userID := handler.GetUserIDFromContext(r)
+	if userID == 0 {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Unable to parse form", http.StatusBadRequest)
+		return
+	}
+
+	section := func(name string) string {
+		v := strings.TrimSpace(r.FormValue(name))
+		if utf8.RuneCountInString(v) > maxPlanSection {
+			v = string([]rune(v)[:maxPlanSection])
+		}
+		return v
+	}
+
+	plan := &db.SafetyPlan{
+		UserID:           userID,
+		WarningSigns:     section("warning_signs"),
+		CopingStrategies: section("coping_strategies"),
+		Distractions:     section("distractions"),
+		Contacts:         section("contacts"),
+		Professionals:    section("professionals"),
+		SafeEnvironment:  section("safe_environment"),
+		ReasonsToLive:    section("reasons_to_live"),
+		Pinned:           r.FormValue("pinned") == "on",
+	}
+	if err := db.SaveSafetyPlan(r.Context(), plan); err != nil {
+		log.Printf("SaveSafetyPlan: %v", err)
+		http.Error(w, "Failed to save your safety plan", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/safety-plan?saved=1", http.StatusSeeOther)
+}
@@ -0,0 +1,107 @@
+// Package safety surfaces crisis resources when someone writes about
+// self-harm, and keeps each user's private safety plan.
+package safety
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strings"
+
+	"Remainwith/internal/models"
+)
+
+// DefaultLocale is the registry key used when no country matches.
+const DefaultLocale = "default"
+
+// Registry holds crisis resources keyed by ISO 3166 country code.
+type Registry struct {
+	cards map[string]models.ResourceCard
+}
+
+// NewRegistry returns a registry for cards, which must include a
+// DefaultLocale entry.
+func NewRegistry(cards map[string]models.ResourceCard) (*Registry, error) {
+	if _, ok := cards[DefaultLocale]; !ok {
+		return nil, fmt.Errorf("crisis resources have no %q entry", DefaultLocale)
+	}
+	normalized := make(map[string]models.ResourceCard, len(cards))
+	for code, card := range cards {
+		if code != DefaultLocale {
+			code = strings.ToUpper(code)
+		}
+		normalized[code] = card
+	}
+	return &Registry{cards: normalized}, nil
+}
+
+// LoadRegistry reads a JSON object of country code to resource card from
+// name in fsys.
+func LoadRegistry(fsys fs.FS, name string) (*Registry, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var cards map[string]models.ResourceCard
+	if err := json.Unmarshal(data, &cards); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return NewRegistry(cards)
+}
+
+// DefaultRegistry returns a registry with only the international card, for
+// use when the configured one cannot be loaded.
+func DefaultRegistry() *Registry {
+	r, _ := NewRegistry(map[string]models.ResourceCard{DefaultLocale: defaultCard})
+	return r
+}
+
+var defaultCard = models.ResourceCard{
+	Title: "You don't have to go through this alone",
+	Body:  "If you or someone here is thinking about suicide or self-harm, please reach out to people who can help right now. If you are in immediate danger, call your local emergency number.",
+	Links: []models.ResourceLink{
+		{Label: "Find a helpline in your country", URL: "https://findahelpline.com"},
+	},
+}
+
+// For returns the card for locale, which may be a country code ("GB") or a
+// language tag ("en-GB", "en_GB"). Unknown locales get the default card.
+func (r *Registry) For(locale string) models.ResourceCard {
+	if card, ok := r.cards[Region(locale)]; ok {
+		return card
+	}
+	return r.cards[DefaultLocale]
+}
+
+// ForRequest picks a card from the request's Accept-Language header.
+func (r *Registry) ForRequest(req *http.Request) models.ResourceCard {
+	return r.For(RequestRegion(req))
+}
+
+// Region extracts an upper-case country code from a locale, or returns ""
+// if it has none.
+func Region(locale string) string {
+	locale = strings.TrimSpace(locale)
+	parts := strings.FieldsFunc(locale, func(r rune) bool { return r == '-' || r == '_' })
+	switch {
+	case len(parts) >= 2 && len(parts[len(parts)-1]) == 2:
+		return strings.ToUpper(parts[len(parts)-1])
+	case len(parts) == 1 && len(locale) == 2 && strings.ToUpper(locale) == locale:
+		return locale
+	}
+	return ""
+}
+
+// RequestRegion returns the first country named in the request's
+// Accept-Language header, or "".
+func RequestRegion(req *http.Request) string {
+	for _, tag := range strings.Split(req.Header.Get("Accept-Language"), ",") {
+		tag, _, _ = strings.Cut(tag, ";")
+		if region := Region(tag); region != "" {
+			return region
+		}
+	}
+	return ""
+}
@@ -0,0 +1,66 @@
+package safety
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"Remainwith/internal/models"
+)
+
+func TestRegistryPicksCountry(t *testing.T) {
+	reg, err := NewRegistry(map[string]models.ResourceCard{
+		DefaultLocale: {Title: "default"},
+		"gb":          {Title: "uk"},
+		"US":          {Title: "us"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{"GB", "uk"},
+		{"en-GB", "uk"},
+		{"en_us", "us"},
+		{"en", "default"},
+		{"fr-FR", "default"},
+		{"", "default"},
+	}
+	for _, tt := range tests {
+		if got := reg.For(tt.locale).Title; got != tt.want {
+			t.Errorf("For(%q) = %q, want %q", tt.locale, got, tt.want)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/support", nil)
+	req.Header.Set("Accept-Language", "en;q=0.9, en-US;q=0.8")
+	if got := reg.ForRequest(req).Title; got != "us" {
+		t.Errorf("ForRequest = %q, want us", got)
+	}
+}
+
+func TestRegistryRequiresDefault(t *testing.T) {
+	if _, err := NewRegistry(map[string]models.ResourceCard{"US": {}}); err == nil {
+		t.Fatal("registry without a default entry was accepted")
+	}
+}
+
+func TestMentionsCrisis(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"Some days I think everyone would be better off without me.", true},
+		{"I can’t go on like this", true},
+		{"thinking about SUICIDE again", true},
+		{"I killed it at work today", false},
+		{"Late-night thoughts about the ocean", false},
+	}
+	for _, tt := range tests {
+		if got := MentionsCrisis(tt.text); got != tt.want {
+			t.Errorf("MentionsCrisis(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+// Package static serves CSS, scripts and images from memory under
+// fingerprinted URLs, so browsers can cache them for good, and picks a
+// precompressed variant for each request.
+package static
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mount serves the files in FS under Prefix, which starts and ends in "/".
+type Mount struct {
+	Prefix string
+	FS     fs.FS
+}
+
+// Files holds every file of its mounts. Each is served at its plain URL,
+// e.g. /static/theme.js, and at a fingerprinted one containing a hash of
+// its content, e.g. /static/theme.3f2a1b9c.js. A new version gets a new
+// fingerprinted URL, so those are cached for a year; plain URLs are
+// revalidated on every use.
+//
+// Text files are gzipped when loaded, so the gzip variant always matches
+// the file. The standard library has no brotli encoder, so a brotli
+// variant is only served where a name.br sibling is shipped; one older
+// than its file is stale and ignored.
+//
+// Files are loaded once by New. With reload set the mounts are checked
+// on every use instead and loaded again when a file changes, so edits
+// show up without a restart while developing.
+type Files struct {
+	mounts []Mount
+	reload bool
+
+	mu     sync.RWMutex
+	stamp  string // sizes and times the tables were loaded from
+	routes map[string]route
+	urls   map[string]string // plain URL → fingerprinted URL
+}
+
+type route struct {
+	*file
+	fingerprinted bool
+}
+
+type file struct {
+	name        string
+	contentType string
+	hash        string
+
+	// variants by content coding, "" for the file as is
+	variants map[string][]byte
+}
+
+// codings are the content codings Files can serve, best first.
+var codings = []struct {
+	name, ext string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// New loads every file of the mounts into memory.
+func New(reload bool, mounts ...Mount) (*Files, error) {
+	for _, m := range mounts {
+		if !strings.HasPrefix(m.Prefix, "/") || !strings.HasSuffix(m.Prefix, "/") {
+			return nil, fmt.Errorf("static: prefix %q must start and end with /", m.Prefix)
+		}
+	}
+	f := &Files{mounts: mounts, reload: reload}
+	stamp, err := f.stat()
+	if err != nil {
+		return nil, err
+	}
+	if err := f.load(stamp); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// stat describes the size and modification time of every file, so a
+// change to any of them changes the result.
+func (f *Files) stat() (string, error) {
+	var b strings.Builder
+	for _, m := range f.mounts {
+		err := fs.WalkDir(m.FS, ".", func(name string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(&b, "%s%s %d %d\n", m.Prefix, name, info.Size(), info.ModTime().UnixNano())
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
+
+// load reads the mounts into fresh tables and swaps them in.
+func (f *Files) load(stamp string) error {
+	routes := make(map[string]route)
+	urls := make(map[string]string)
+	for _, m := range f.mounts {
+		err := fs.WalkDir(m.FS, ".", func(name string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || isVariant(m.FS, name) {
+				return err
+			}
+			return add(routes, urls, m, name)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	f.mu.Lock()
+	f.stamp, f.routes, f.urls = stamp, routes, urls
+	f.mu.Unlock()
+	return nil
+}
+
+// tables returns the current routes and URLs, first loading the mounts
+// again if reloading and a file has changed. A failed reload is logged
+// and the previous tables kept, so a half-saved file doesn't take the
+// site down.
+func (f *Files) tables() (map[string]route, map[string]string) {
+	if f.reload {
+		stamp, err := f.stat()
+		f.mu.RLock()
+		changed := err == nil && stamp != f.stamp
+		f.mu.RUnlock()
+		if err == nil && changed {
+			err = f.load(stamp)
+		}
+		if err != nil {
+			log.Printf("static: reload: %v", err)
+		}
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.routes, f.urls
+}
+
+// isVariant reports whether name is the compressed copy of another file.
+func isVariant(fsys fs.FS, name string) bool {
+	for _, c := range codings {
+		if base, ok := strings.CutSuffix(name, c.ext); ok {
+			if _, err := fs.Stat(fsys, base); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func add(routes map[string]route, urls map[string]string, m Mount, name string) error {
+	data, err := fs.ReadFile(m.FS, name)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	file := &file{
+		name:     name,
+		hash:     hex.EncodeToString(sum[:])[:8],
+		variants: map[string][]byte{"": data},
+	}
+	file.contentType = mime.TypeByExtension(path.Ext(name))
+	if file.contentType == "" {
+		file.contentType = http.DetectContentType(data)
+	}
+
+	if br, err := freshVariant(m.FS, name, ".br"); err != nil {
+		return err
+	} else if br != nil {
+		file.variants["br"] = br
+	}
+	if compressible(file.contentType) {
+		if gz := gzipped(data); len(gz) < len(data) {
+			file.variants["gzip"] = gz
+		}
+	}
+
+	plain := m.Prefix + name
+	ext := path.Ext(name)
+	fingerprinted := m.Prefix + strings.TrimSuffix(name, ext) + "." + file.hash + ext
+	routes[plain] = route{file, false}
+	routes[fingerprinted] = route{file, true}
+	urls[plain] = fingerprinted
+	return nil
+}
+
+// freshVariant returns the contents of name's sibling with ext, or nil if
+// there is none or it is older than name. Embedded files have no times,
+// so their siblings always count as fresh.
+func freshVariant(fsys fs.FS, name, ext string) ([]byte, error) {
+	variant, err := fs.Stat(fsys, name+ext)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	original, err := fs.Stat(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	if variant.ModTime().Before(original.ModTime()) {
+		return nil, nil
+	}
+	return fs.ReadFile(fsys, name+ext)
+}
+
+// compressible reports whether gzip is worth trying on contentType.
+// Images other than SVG are compressed already.
+func compressible(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return strings.HasPrefix(mediaType, "text/") ||
+		mediaType == "application/javascript" ||
+		mediaType == "application/json" ||
+		mediaType == "image/svg+xml"
+}
+
+func gzipped(data []byte) []byte {
+	var buf bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
+}
+
+// URL returns the fingerprinted URL for the plain URL of a file, such as
+// "/static/theme.js". Unknown URLs are returned as they are.
+func (f *Files) URL(plain string) string {
+	_, urls := f.tables()
+	if u, ok := urls[plain]; ok {
+		return u
+	}
+	return plain
+}
+
+// ServeHTTP serves the file at r's path, in the best encoding the client
+// accepts.
+func (f *Files) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	routes, _ := f.tables()
+	rt, ok := routes[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", rt.contentType)
+	if rt.fingerprinted {
+		header.Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		header.Set("Cache-Control", "no-cache")
+	}
+
+	coding := ""
+	if len(rt.variants) > 1 {
+		header.Add("Vary", "Accept-Encoding")
+		for _, c := range codings {
+			if _, ok := rt.variants[c.name]; ok && accepts(r.Header.Get("Accept-Encoding"), c.name) {
+				coding = c.name
+				break
+			}
+		}
+	}
+	etag := rt.hash
+	if coding != "" {
+		header.Set("Content-Encoding", coding)
+		etag += "-" + coding
+	}
+	header.Set("ETag", strconv.Quote(etag))
+
+	http.ServeContent(w, r, rt.name, time.Time{}, bytes.NewReader(rt.variants[coding]))
+}
+
+// accepts reports whether an Accept-Encoding header allows coding.
+func accepts(acceptEncoding, coding string) bool {
+	wildcard := false
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		allowed := true
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			v, err := strconv.ParseFloat(q, 64)
+			allowed = err == nil && v > 0
+		}
+		switch {
+		case strings.EqualFold(name, coding):
+			return allowed
+		case name == "*":
+			wildcard = allowed
+		}
+	}
+	return wildcard
+}
@@ -0,0 +1,155 @@
+package static
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func newFiles(t *testing.T) *Files {
+	t.Helper()
+	css := strings.Repeat("body { color: black; }\n", 50)
+	f, err := New(false,
+		Mount{Prefix: "/static/", FS: fstest.MapFS{
+			"style.css":    {Data: []byte(css)},
+			"style.css.br": {Data: []byte("brotli bytes")},
+			"app.js":       {Data: []byte(strings.Repeat("console.log(1);\n", 50))},
+		}},
+		Mount{Prefix: "/assets/", FS: fstest.MapFS{
+			"logo.png": {Data: []byte("\x89PNG\r\n\x1a\nnot really")},
+		}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func get(f *Files, url string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	f.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestFingerprintedURLs(t *testing.T) {
+	f := newFiles(t)
+
+	url := f.URL("/assets/logo.png")
+	if url == "/assets/logo.png" || !strings.HasPrefix(url, "/assets/logo.") || !strings.HasSuffix(url, ".png") {
+		t.Fatalf("URL = %q", url)
+	}
+	if got := f.URL("/assets/missing.png"); got != "/assets/missing.png" {
+		t.Errorf("unknown URL became %q", got)
+	}
+
+	rec := get(f, url)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Header().Get("Cache-Control"), "immutable") {
+		t.Errorf("fingerprinted: %d %q", rec.Code, rec.Header().Get("Cache-Control"))
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "image/png" {
+		t.Errorf("Content-Type %q", ct)
+	}
+
+	rec = get(f, "/assets/logo.png")
+	if rec.Code != http.StatusOK || rec.Header().Get("Cache-Control") != "no-cache" {
+		t.Errorf("plain: %d %q", rec.Code, rec.Header().Get("Cache-Control"))
+	}
+
+	rec = get(f, "/assets/logo.png", "If-None-Match", rec.Header().Get("ETag"))
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("revalidation: status %d, want 304", rec.Code)
+	}
+}
+
+func TestContentNegotiation(t *testing.T) {
+	f := newFiles(t)
+
+	tests := []struct {
+		url, acceptEncoding, want string
+	}{
+		{"/static/style.css", "gzip, deflate, br", "br"},
+		{"/static/style.css", "gzip", "gzip"},
+		{"/static/style.css", "br;q=0, gzip", "gzip"},
+		{"/static/style.css", "", ""},
+		{"/static/app.js", "br, gzip", "gzip"},
+		{"/static/app.js", "*", "gzip"},
+		{"/assets/logo.png", "gzip", ""},
+	}
+	for _, tt := range tests {
+		rec := get(f, tt.url, "Accept-Encoding", tt.acceptEncoding)
+		if got := rec.Header().Get("Content-Encoding"); got != tt.want {
+			t.Errorf("%s with %q: encoding %q, want %q", tt.url, tt.acceptEncoding, got, tt.want)
+		}
+	}
+
+	rec := get(f, "/static/app.js", "Accept-Encoding", "gzip")
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(zr)
+	if !strings.HasPrefix(string(body), "console.log(1);") || rec.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("gzip body %q, Vary %q", body, rec.Header().Get("Vary"))
+	}
+
+	if rec := get(f, "/static/style.css.br"); rec.Code != http.StatusNotFound {
+		t.Errorf("variant served on its own: %d", rec.Code)
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string, age time.Duration) {
+		t.Helper()
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		when := time.Now().Add(-age)
+		if err := os.Chtimes(p, when, when); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("theme.js", "let theme = 1;", time.Hour)
+	write("theme.js.br", "brotli bytes", time.Hour)
+
+	fixed, err := New(false, Mount{Prefix: "/static/", FS: os.DirFS(dir)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := New(true, Mount{Prefix: "/static/", FS: os.DirFS(dir)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := f.URL("/static/theme.js")
+	if rec := get(f, "/static/theme.js", "Accept-Encoding", "br"); rec.Header().Get("Content-Encoding") != "br" {
+		t.Fatal("fresh brotli variant not served")
+	}
+
+	write("theme.js", "let theme = 2;", 0)
+
+	if f.URL("/static/theme.js") == before {
+		t.Error("fingerprint unchanged after the file changed")
+	}
+	rec := get(f, "/static/theme.js", "Accept-Encoding", "br")
+	if rec.Header().Get("Content-Encoding") == "br" {
+		t.Error("stale brotli variant served")
+	}
+	if body := rec.Body.String(); body != "let theme = 2;" {
+		t.Errorf("body %q after edit", body)
+	}
+	if fixed.URL("/static/theme.js") != before {
+		t.Error("files loaded without reload changed")
+	}
+}
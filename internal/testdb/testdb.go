@@ -0,0 +1,305 @@
+// Package testdb runs integration tests against a throwaway Postgres.
+//
+// The first test to call New starts a server from the local initdb and
+// postgres binaries, looked up in $POSTGRES_BIN, on PATH and under
+// /usr/lib/postgresql, in a temporary directory and listening only on a
+// unix socket. Set TEST_DATABASE_URL to use a running server instead.
+// Either way each test gets a fresh database with the schema applied, and
+// tests skip when no server is available.
+//
+// Packages using it stop the server by calling Main from TestMain:
+//
+//	func TestMain(m *testing.M) { testdb.Main(m) }
+package testdb
+
+import (
+	"Remainwith/config"
+	"Remainwith/db"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	startOnce sync.Once
+	srv       *server
+	startErr  error
+
+	databases atomic.Int64
+)
+
+// errUnavailable makes New skip rather than fail.
+var errUnavailable = errors.New("no Postgres available")
+
+// server is a Postgres that tests create their databases in.
+type server struct {
+	// dsn connects to the maintenance database.
+	dsn string
+
+	// cmd and dir are set when the server was started here.
+	cmd *exec.Cmd
+	dir string
+}
+
+// Main runs the tests and stops the server if one was started.
+func Main(m *testing.M) {
+	code := m.Run()
+	if srv != nil {
+		srv.stop()
+	}
+	os.Exit(code)
+}
+
+// New returns stores on a new, empty database with the schema applied.
+// For the duration of the test config.DB points at the same database, so
+// package-level db functions and the websocket hub see it too.
+func New(t testing.TB) *db.Postgres {
+	t.Helper()
+	startOnce.Do(func() { srv, startErr = start() })
+	if errors.Is(startErr, errUnavailable) {
+		t.Skip(startErr)
+	}
+	if startErr != nil {
+		t.Fatal(startErr)
+	}
+
+	ctx := context.Background()
+	name := fmt.Sprintf("remainwith_test_%d_%d", os.Getpid(), databases.Add(1))
+	if err := srv.exec(ctx, "CREATE DATABASE "+pgx.Identifier{name}.Sanitize()); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := pgxpool.ParseConfig(srv.dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ConnConfig.Database = name
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := config.DB
+	config.DB = pool
+	t.Cleanup(func() {
+		config.DB = previous
+		pool.Close()
+		if err := srv.exec(context.Background(), "DROP DATABASE IF EXISTS "+pgx.Identifier{name}.Sanitize()); err != nil {
+			t.Logf("dropping %s: %v", name, err)
+		}
+	})
+
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatalf("applying schema: %v", err)
+	}
+	return db.NewPostgres(pool)
+}
+
+// CreateUser adds an account with the given password, hashed as signup
+// would, and returns it.
+func CreateUser(t testing.TB, users db.UserStore, name, email, password string) *db.Userinfo {
+	t.Helper()
+	ctx := context.Background()
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := users.NewUser(ctx, name, email, string(hashed)); err != nil {
+		t.Fatalf("creating %s: %v", email, err)
+	}
+	user, err := users.GetUserByEmail(ctx, email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// Login signs in through the login form at baseURL and returns a client
+// carrying the session cookies. The client doesn't follow redirects.
+func Login(t testing.TB, baseURL, email, password string) *http.Client {
+	t.Helper()
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{
+		Jar: jar,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.PostForm(baseURL+"/login", url.Values{"email": {email}, "password": {password}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "/dashboard" {
+		t.Fatalf("login as %s: status %d, location %q", email, resp.StatusCode, resp.Header.Get("Location"))
+	}
+	return client
+}
+
+func (s *server) exec(ctx context.Context, sql string) error {
+	conn, err := pgx.Connect(ctx, s.dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+	_, err = conn.Exec(ctx, sql)
+	return err
+}
+
+// start connects to TEST_DATABASE_URL or starts a local server.
+func start() (*server, error) {
+	if dsn := os.Getenv("TEST_DATABASE_URL"); dsn != "" {
+		s := &server{dsn: dsn}
+		return s, s.wait(5 * time.Second)
+	}
+
+	initdb, postgres := findBinary("initdb"), findBinary("postgres")
+	if initdb == "" || postgres == "" {
+		return nil, fmt.Errorf("%w: install Postgres, set POSTGRES_BIN or set TEST_DATABASE_URL", errUnavailable)
+	}
+	if os.Geteuid() == 0 {
+		return nil, fmt.Errorf("%w: postgres refuses to run as root; set TEST_DATABASE_URL", errUnavailable)
+	}
+
+	// Unix socket paths are short, so stay out of long TMPDIRs
+	dir, err := os.MkdirTemp("/tmp", "remainwith-pg-")
+	if err != nil {
+		return nil, err
+	}
+	s := &server{
+		dir: dir,
+		dsn: fmt.Sprintf("host=%s port=5432 user=postgres dbname=postgres sslmode=disable", dir),
+	}
+	data := filepath.Join(dir, "data")
+
+	out, err := exec.Command(initdb, "-D", data, "-U", "postgres", "-A", "trust", "-E", "UTF8", "--no-locale", "-N").CombinedOutput()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("initdb: %v\n%s", err, out)
+	}
+
+	logFile, err := os.Create(filepath.Join(dir, "postgres.log"))
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	defer logFile.Close()
+
+	// Durability is pointless for a database thrown away after the run
+	s.cmd = exec.Command(postgres, "-D", data, "-k", dir, "-p", "5432",
+		"-c", "listen_addresses=",
+		"-c", "fsync=off",
+		"-c", "synchronous_commit=off",
+		"-c", "full_page_writes=off")
+	s.cmd.Stdout = logFile
+	s.cmd.Stderr = logFile
+	if err := s.cmd.Start(); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("starting postgres: %w", err)
+	}
+
+	if err := s.wait(15 * time.Second); err != nil {
+		log, _ := os.ReadFile(filepath.Join(dir, "postgres.log"))
+		s.stop()
+		return nil, fmt.Errorf("%w\n%s", err, log)
+	}
+	return s, nil
+}
+
+// wait polls until the server accepts connections.
+func (s *server) wait(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		conn, err := pgx.Connect(ctx, s.dsn)
+		if err == nil {
+			err = conn.Ping(ctx)
+			conn.Close(ctx)
+		}
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("postgres not ready after %v: %w", timeout, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// stop shuts down a server started here and removes its files.
+func (s *server) stop() {
+	if s.cmd == nil {
+		return
+	}
+	// SIGINT is Postgres's fast shutdown
+	s.cmd.Process.Signal(syscall.SIGINT)
+	done := make(chan struct{})
+	go func() {
+		s.cmd.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		s.cmd.Process.Kill()
+		<-done
+	}
+	os.RemoveAll(s.dir)
+}
+
+// findBinary returns the path of a Postgres program, or "".
+func findBinary(name string) string {
+	if dir := os.Getenv("POSTGRES_BIN"); dir != "" {
+		if path := filepath.Join(dir, name); isExecutable(path) {
+			return path
+		}
+	}
+	if path, err := exec.LookPath(name); err == nil {
+		return path
+	}
+	// Debian and Ubuntu keep the server binaries off PATH; take the newest
+	matches, _ := filepath.Glob("/usr/lib/postgresql/*/bin/" + name)
+	sort.Slice(matches, func(i, j int) bool {
+		return versionOf(matches[i]) > versionOf(matches[j])
+	})
+	for _, path := range matches {
+		if isExecutable(path) {
+			return path
+		}
+	}
+	return ""
+}
+
+// versionOf is the major version in a /usr/lib/postgresql/<v>/bin path.
+func versionOf(path string) int {
+	var v int
+	fmt.Sscanf(strings.TrimPrefix(path, "/usr/lib/postgresql/"), "%d", &v)
+	return v
+}
+
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir() && info.Mode()&0o111 != 0
+}
@@ -0,0 +1,437 @@
+package ws
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"Remainwith/internal/models"
+	"Remainwith/internal/safety"
+)
+
+// Filter inspects a chat message before it is stored and broadcast. It may
+// rewrite msg (for example to mask words) or attach resources to it, and
+// returns a *MessageError to refuse the message outright.
+type Filter interface {
+	Filter(msg *models.Message) error
+}
+
+// FilterFunc adapts an ordinary function to a Filter.
+type FilterFunc func(msg *models.Message) error
+
+// Filter calls f(msg).
+func (f FilterFunc) Filter(msg *models.Message) error {
+	return f(msg)
+}
+
+// FilterChain runs filters in order and stops at the first refusal.
+type FilterChain []Filter
+
+// Filter runs every filter in the chain.
+func (fc FilterChain) Filter(msg *models.Message) error {
+	for _, f := range fc {
+		if err := f.Filter(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetFilters replaces the default chain used by rooms that do not set
+// RoomOptions.Filters.
+func (h *Hub) SetFilters(fc FilterChain) {
+	h.roomsMu.Lock()
+	h.filters = fc
+	h.roomsMu.Unlock()
+}
+
+// roomFilters returns the chain that applies to room.
+func (h *Hub) roomFilters(room string) FilterChain {
+	h.roomsMu.RLock()
+	defer h.roomsMu.RUnlock()
+	if fc := h.rooms[room].Filters; fc != nil {
+		return fc
+	}
+	return h.filters
+}
+
+// Wordlists holds the words used by the profanity and slur filters.
+type Wordlists struct {
+	// Profanity is masked in place.
+	Profanity []string
+
+	// Slurs cause the whole message to be refused.
+	Slurs []string
+}
+
+// DefaultFilters returns the chain used by rooms that do not configure
+// their own: crisis support first so it sees the original wording, then
+// slurs and spam, which refuse, then the rewriting filters. resources is
+// passed to NewCrisisDetector.
+func DefaultFilters(lists Wordlists, resources func(locale string) models.ResourceCard) FilterChain {
+	return FilterChain{
+		NewCrisisDetector(resources),
+		NewWordFilter(lists.Slurs, false),
+		NewSpamFilter(),
+		NewWordFilter(lists.Profanity, true),
+		FilterFunc(StripLinks),
+		FilterFunc(StripPhoneNumbers),
+	}
+}
+
+// LoadWordlist reads one word or phrase per line from name in fsys. Blank
+// lines and lines starting with # are ignored.
+func LoadWordlist(fsys fs.FS, name string) ([]string, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, strings.ToLower(line))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read wordlist %s: %w", name, err)
+	}
+	return words, nil
+}
+
+// ErrBlockedLanguage is returned when a message contains a slur.
+var ErrBlockedLanguage = &MessageError{"that message contains language that isn't allowed here"}
+
+// leet undoes common character substitutions used to dodge wordlists.
+var leet = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s")
+
+// normalizeWord lowercases a token, undoes leetspeak and drops anything
+// that is not a letter, so "Sh1t!" becomes "shit".
+func normalizeWord(w string) string {
+	return strings.Map(func(r rune) rune {
+		if !unicode.IsLetter(r) {
+			return -1
+		}
+		return r
+	}, leet.Replace(strings.ToLower(w)))
+}
+
+// squeeze collapses runs of the same letter, so "shiiit" becomes "shit".
+func squeeze(w string) string {
+	var b strings.Builder
+	var last rune
+	for _, r := range w {
+		if r != last {
+			b.WriteRune(r)
+		}
+		last = r
+	}
+	return b.String()
+}
+
+// WordFilter matches whole words and phrases against a list, either
+// masking them or refusing the message.
+type WordFilter struct {
+	words    map[string]struct{}
+	squeezed map[string]struct{}
+	phrases  [][]string // normalized words of each multi-word entry
+	mask     bool
+}
+
+// NewWordFilter returns a filter for words, each a word or a phrase of
+// several. If mask is true matches are replaced with asterisks; otherwise
+// the message is refused.
+func NewWordFilter(words []string, mask bool) *WordFilter {
+	wf := &WordFilter{
+		words:    make(map[string]struct{}, len(words)),
+		squeezed: make(map[string]struct{}, len(words)),
+		mask:     mask,
+	}
+	for _, w := range words {
+		var phrase []string
+		for _, token := range wordPattern.FindAllString(w, -1) {
+			if n := normalizeWord(token); n != "" {
+				phrase = append(phrase, n)
+			}
+		}
+		switch len(phrase) {
+		case 0:
+		case 1:
+			wf.words[phrase[0]] = struct{}{}
+			wf.squeezed[squeeze(phrase[0])] = struct{}{}
+		default:
+			wf.phrases = append(wf.phrases, phrase)
+		}
+	}
+	return wf
+}
+
+// matches reports whether the normalized word n is on the list. Stretched
+// spellings are only compared in squeezed form, so that "as" never
+// matches "ass".
+func (wf *WordFilter) matches(n string) bool {
+	if _, ok := wf.words[n]; ok {
+		return true
+	}
+	if sq := squeeze(n); sq != n {
+		_, ok := wf.squeezed[sq]
+		return ok
+	}
+	return false
+}
+
+// sameWord reports whether the normalized word n is want, allowing for
+// stretched spellings as matches does.
+func sameWord(n, want string) bool {
+	if n == want {
+		return true
+	}
+	sq := squeeze(n)
+	return sq != n && sq == squeeze(want)
+}
+
+// phraseAt returns how many of words, from the start, make up a phrase on
+// the list, or 0 if none starts there.
+func (wf *WordFilter) phraseAt(words []string) int {
+next:
+	for _, phrase := range wf.phrases {
+		if len(phrase) > len(words) {
+			continue
+		}
+		for i, want := range phrase {
+			if !sameWord(words[i], want) {
+				continue next
+			}
+		}
+		return len(phrase)
+	}
+	return 0
+}
+
+// Filter implements Filter.
+func (wf *WordFilter) Filter(msg *models.Message) error {
+	if len(wf.words) == 0 && len(wf.phrases) == 0 {
+		return nil
+	}
+
+	spans := wordPattern.FindAllStringIndex(msg.Content, -1)
+	words := make([]string, len(spans))
+	for i, span := range spans {
+		words[i] = normalizeWord(msg.Content[span[0]:span[1]])
+	}
+	hit := make([]bool, len(spans))
+	matched := false
+	for i := range words {
+		if wf.matches(words[i]) {
+			hit[i], matched = true, true
+		}
+		for j := wf.phraseAt(words[i:]); j > 0; j-- {
+			hit[i+j-1], matched = true, true
+		}
+	}
+	if !matched {
+		return nil
+	}
+	if !wf.mask {
+		return ErrBlockedLanguage
+	}
+
+	var b strings.Builder
+	last := 0
+	for i, span := range spans {
+		if !hit[i] {
+			continue
+		}
+		runes := []rune(msg.Content[span[0]:span[1]])
+		b.WriteString(msg.Content[last:span[0]])
+		b.WriteString(string(runes[0]) + strings.Repeat("*", len(runes)-1))
+		last = span[1]
+	}
+	b.WriteString(msg.Content[last:])
+	msg.Content = b.String()
+	return nil
+}
+
+// wordPattern splits content into candidate words, keeping the digits and
+// symbols normalizeWord understands.
+var wordPattern = regexp.MustCompile(`[\p{L}0-9@$]+`)
+
+var (
+	linkPattern  = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+|\b[a-z0-9-]+(?:\.[a-z0-9-]+)*\.(?:com|net|org|io|co|me|ly|gg|app|xyz|info|link|ru|uk|in)\b(?:/\S*)?`)
+	phonePattern = regexp.MustCompile(`\+?\d[\d\s().-]{6,}\d`)
+)
+
+// StripLinks replaces URLs and bare domains, which are the usual vehicle
+// for spam and for moving people off the platform.
+func StripLinks(msg *models.Message) error {
+	msg.Content = linkPattern.ReplaceAllString(msg.Content, "[link removed]")
+	return nil
+}
+
+// StripPhoneNumbers replaces anything that looks like a phone number so
+// people do not share contact details in public rooms.
+func StripPhoneNumbers(msg *models.Message) error {
+	msg.Content = phonePattern.ReplaceAllStringFunc(msg.Content, func(m string) string {
+		digits := 0
+		for _, r := range m {
+			if r >= '0' && r <= '9' {
+				digits++
+			}
+		}
+		if digits < 9 {
+			return m
+		}
+		return "[number removed]"
+	})
+	return nil
+}
+
+// ErrFlooding and ErrRepeated are returned by the spam filter.
+var (
+	ErrFlooding = &MessageError{"you're sending messages too quickly, take a breath"}
+	ErrRepeated = &MessageError{"you've already sent that message"}
+)
+
+// SpamFilter refuses floods of messages and repeats of the same text from
+// one sender in one room.
+type SpamFilter struct {
+	// window is the period over which messages are counted.
+	//
+	// Defaults to 10 seconds.
+	window time.Duration
+
+	// maxPerWindow is the number of messages allowed in window.
+	//
+	// Defaults to 6.
+	maxPerWindow int
+
+	// repeatWindow is how long an identical message is remembered.
+	//
+	// Defaults to 1 minute.
+	repeatWindow time.Duration
+
+	mu        sync.Mutex
+	senders   map[string]*senderHistory
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+type senderHistory struct {
+	sent   []time.Time
+	recent map[string]sentText
+}
+
+type sentText struct {
+	clientID string
+	at       time.Time
+}
+
+// NewSpamFilter returns a SpamFilter with default limits.
+func NewSpamFilter() *SpamFilter {
+	return &SpamFilter{
+		window:       10 * time.Second,
+		maxPerWindow: 6,
+		repeatWindow: time.Minute,
+		senders:      make(map[string]*senderHistory),
+		now:          time.Now,
+	}
+}
+
+// Filter implements Filter.
+func (sf *SpamFilter) Filter(msg *models.Message) error {
+	now := sf.now()
+	key := msg.Room + "\x00" + msg.SenderID
+	text := strings.Join(strings.Fields(strings.ToLower(msg.Content)), " ")
+
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+
+	if now.Sub(sf.lastPrune) >= sf.repeatWindow {
+		sf.pruneLocked(now)
+	}
+
+	hist := sf.senders[key]
+	if hist == nil {
+		hist = &senderHistory{recent: make(map[string]sentText)}
+		sf.senders[key] = hist
+	}
+	hist.expire(now, sf.window, sf.repeatWindow)
+
+	if prev, ok := hist.recent[text]; ok {
+		if msg.ClientID != "" && prev.clientID == msg.ClientID {
+			// A retry of a message we already let through; the hub
+			// deduplicates it, so it must not count as spam.
+			return nil
+		}
+		return ErrRepeated
+	}
+	if len(hist.sent) >= sf.maxPerWindow {
+		return ErrFlooding
+	}
+
+	hist.sent = append(hist.sent, now)
+	hist.recent[text] = sentText{clientID: msg.ClientID, at: now}
+	return nil
+}
+
+// expire forgets sends older than window and texts older than repeatWindow.
+func (hist *senderHistory) expire(now time.Time, window, repeatWindow time.Duration) {
+	kept := hist.sent[:0]
+	for _, t := range hist.sent {
+		if now.Sub(t) < window {
+			kept = append(kept, t)
+		}
+	}
+	hist.sent = kept
+	for text, s := range hist.recent {
+		if now.Sub(s.at) >= repeatWindow {
+			delete(hist.recent, text)
+		}
+	}
+}
+
+// pruneLocked drops senders with nothing left to remember.
+func (sf *SpamFilter) pruneLocked(now time.Time) {
+	for key, hist := range sf.senders {
+		hist.expire(now, sf.window, sf.repeatWindow)
+		if len(hist.sent) == 0 && len(hist.recent) == 0 {
+			delete(sf.senders, key)
+		}
+	}
+	sf.lastPrune = now
+}
+
+// CrisisDetector attaches a resource card to messages that suggest the
+// writer, or someone they mention, may be at risk. It never refuses a
+// message: reaching out should not be blocked.
+type CrisisDetector struct {
+	resources func(locale string) models.ResourceCard
+}
+
+// NewCrisisDetector returns a detector that attaches the card resources
+// returns for the sender's locale. A nil resources uses
+// safety.DefaultRegistry.
+func NewCrisisDetector(resources func(locale string) models.ResourceCard) *CrisisDetector {
+	if resources == nil {
+		resources = safety.DefaultRegistry().For
+	}
+	return &CrisisDetector{resources: resources}
+}
+
+// Filter implements Filter.
+func (cd *CrisisDetector) Filter(msg *models.Message) error {
+	if safety.MentionsCrisis(msg.Content) {
+		card := cd.resources(msg.Locale)
+		msg.Resources = &card
+	}
+	return nil
+}
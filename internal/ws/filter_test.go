@@ -0,0 +1,142 @@
+package ws
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"Remainwith/internal/models"
+)
+
+func TestFilterRewrites(t *testing.T) {
+	profanity := NewWordFilter([]string{"shit", "ass", "piss off"}, true)
+
+	tests := []struct {
+		name   string
+		filter Filter
+		in     string
+		want   string
+	}{
+		{"masks word", profanity, "oh shit", "oh s***"},
+		{"masks leetspeak", profanity, "sh1t happens", "s*** happens"},
+		{"masks stretched", profanity, "shiiiit", "s******"},
+		{"masks phrase", profanity, "just PISS   0ff, ok", "just P***   0**, ok"},
+		{"leaves phrase words apart", profanity, "piss is off topic", "piss is off topic"},
+		{"leaves near misses", profanity, "as I said, pass the assessment", "as I said, pass the assessment"},
+		{"strips url", FilterFunc(StripLinks), "see https://spam.example/x now", "see [link removed] now"},
+		{"strips bare domain", FilterFunc(StripLinks), "go to cheap-pills.com today", "go to [link removed] today"},
+		{"strips phone", FilterFunc(StripPhoneNumbers), "call me on +44 7700 900123", "call me on [number removed]"},
+		{"keeps short numbers", FilterFunc(StripPhoneNumbers), "I slept 8 hours on 19/10", "I slept 8 hours on 19/10"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := models.Message{Content: tt.in}
+			if err := tt.filter.Filter(&msg); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if msg.Content != tt.want {
+				t.Errorf("got %q, want %q", msg.Content, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlurFilterRefuses(t *testing.T) {
+	f := NewWordFilter([]string{"badword", "kill yourself"}, false)
+	for _, content := range []string{"you B4DW0RD", "you should kill yourself", "k1ll, yourseeelf"} {
+		msg := models.Message{Content: content}
+		if err := f.Filter(&msg); !errors.Is(err, ErrBlockedLanguage) {
+			t.Errorf("%q: err = %v, want ErrBlockedLanguage", content, err)
+		}
+	}
+	msg := models.Message{Content: "killer yourself"}
+	if err := f.Filter(&msg); err != nil {
+		t.Errorf("%q refused: %v", msg.Content, err)
+	}
+}
+
+func TestSpamFilter(t *testing.T) {
+	now := time.Now()
+	sf := NewSpamFilter()
+	sf.now = func() time.Time { return now }
+
+	send := func(clientID, content string) error {
+		return sf.Filter(&models.Message{Room: "r", SenderID: "1", ClientID: clientID, Content: content})
+	}
+
+	if err := send("a", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if err := send("a", "hello"); err != nil {
+		t.Fatalf("retry of the same message was refused: %v", err)
+	}
+	if err := send("b", "Hello "); !errors.Is(err, ErrRepeated) {
+		t.Fatalf("repeat err = %v, want ErrRepeated", err)
+	}
+
+	for i := 0; i < sf.maxPerWindow-1; i++ {
+		if err := send(string(rune('c'+i)), string(rune('A'+i))); err != nil {
+			t.Fatalf("message %d refused: %v", i, err)
+		}
+	}
+	if err := send("z", "one too many"); !errors.Is(err, ErrFlooding) {
+		t.Fatalf("flood err = %v, want ErrFlooding", err)
+	}
+
+	now = now.Add(sf.repeatWindow)
+	if err := send("y", "hello"); err != nil {
+		t.Fatalf("message refused after the windows passed: %v", err)
+	}
+}
+
+func TestCrisisDetectorAttachesCard(t *testing.T) {
+	cd := NewCrisisDetector(nil)
+
+	msg := models.Message{Content: "honestly I just want to die"}
+	if err := cd.Filter(&msg); err != nil {
+		t.Fatalf("crisis detector refused a message: %v", err)
+	}
+	if msg.Resources == nil {
+		t.Fatal("no resource card attached")
+	}
+	if msg.Content != "honestly I just want to die" {
+		t.Errorf("content changed to %q", msg.Content)
+	}
+
+	calm := models.Message{Content: "this pasta is to die for"}
+	cd.Filter(&calm)
+	if calm.Resources != nil {
+		t.Error("card attached to an ordinary message")
+	}
+}
+
+func TestCrisisResourcesOnlyReachSender(t *testing.T) {
+	h, _ := newStoreHub()
+	h.SetFilters(FilterChain{NewCrisisDetector(func(string) models.ResourceCard {
+		return models.ResourceCard{Title: "You're not alone"}
+	})})
+	sender := newClient(nil, "1", "a", 8)
+	senderTab := newClient(nil, "1", "a", 8)
+	other := newClient(nil, "2", "b", 8)
+	for _, c := range []*client{sender, senderTab, other} {
+		h.addSubscriber(c)
+	}
+
+	h.handleMessage(sender, models.Message{ClientID: "c1", Content: "I want to kill myself"})
+
+	got := frames(t, sender)
+	if len(got) != 3 || got[0].Type != TypeAck || got[1].Type != TypeSupport || got[1].Resources == nil {
+		t.Fatalf("sender got %+v, want ack, support card, echo", got)
+	}
+	for _, c := range []*client{senderTab, other} {
+		for _, frame := range frames(t, c) {
+			if frame.Resources != nil {
+				t.Errorf("user %s saw the support card", c.userID)
+			}
+		}
+	}
+	if got[2].Resources != nil {
+		t.Error("the broadcast echo carried the support card")
+	}
+}
@@ -2,153 +2,653 @@ package ws
 
 import (
 	"context"
+	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"Remainwith/db"
+	"Remainwith/internal/handler"
 	"Remainwith/internal/models"
+	"Remainwith/internal/safety"
 
 	"github.com/coder/websocket"
 	"golang.org/x/time/rate"
 )
 
+// Frame types exchanged over the websocket.
+const (
+	TypeMessage = "message" // chat message, client → server → subscribers
+	TypeAck     = "ack"     // server → sender once a message is persisted
+	TypeReceipt = "receipt" // server → sender when a direct message is delivered or read
+	TypeRead    = "read"    // client → server when a direct message has been seen
+	TypeError   = "error"   // server → client when a frame could not be handled
+	TypeSupport = "support" // server → sender alone when the safety filters attached resources
+)
+
+// outbound is a frame queued for a client's writer goroutine.
+type outbound struct {
+	data []byte
+
+	// delivered is the ID of a direct message addressed to this client.
+	// Once the frame is written a delivery receipt is recorded for it.
+	delivered uint
+}
+
+// client is a single websocket connection and the user behind it.
+// Frames are queued on msgs and written by a single writer goroutine; if the
+// client cannot keep up, closeSlow is called and the connection is dropped.
+type client struct {
+	conn   *websocket.Conn
+	userID string
+	name   string
+	room   string
+
+	presence presenceState
+
+	// locale is the country from the connecting request's
+	// Accept-Language, used to pick crisis resources
+	locale string
+
+	// blocked holds users this user has blocked or been blocked by,
+	// guarded by the hub's subscribersMu
+	blocked map[string]struct{}
+
+	msgs      chan outbound
+	closeOnce sync.Once
+	closeSlow func()
+}
+
+// newClient constructs a client with an outbound queue of size buffer.
+func newClient(conn *websocket.Conn, userID, name string, buffer int) *client {
+	c := &client{
+		conn:     conn,
+		userID:   userID,
+		name:     name,
+		room:     DefaultRoom,
+		presence: presenceState{lastSeen: time.Now()},
+		msgs:     make(chan outbound, buffer),
+	}
+	c.closeSlow = func() {
+		c.close(websocket.StatusPolicyViolation, "connection too slow to keep up with messages")
+	}
+	return c
+}
+
+// close sends a close frame and waits for the handshake. Only the first
+// call for a client does anything.
+func (c *client) close(code websocket.StatusCode, reason string) {
+	c.closeOnce.Do(func() {
+		if c.conn != nil {
+			c.conn.Close(code, reason)
+		}
+	})
+}
+
 // Hub manages websocket connections and message broadcasting
 type Hub struct {
-	// subscribers holds all active websocket connections
-	subscribers map[*websocket.Conn]struct{}
-	mu          sync.RWMutex
+	// subscriberMessageBuffer controls the max number
+	// of messages that can be queued for a subscriber
+	// before it is kicked.
+	//
+	// Defaults to 16.
+	subscriberMessageBuffer int
+
+	// writeTimeout bounds a single websocket write.
+	//
+	// Defaults to 5 seconds.
+	writeTimeout time.Duration
+
+	// readTimeout closes connections that send nothing at all, not even
+	// heartbeats, for this long.
+	//
+	// Defaults to 90 seconds.
+	readTimeout time.Duration
 
-	// broadcast channel for incoming messages
-	broadcast chan models.Message
+	// idleAfter marks a connection idle once it has missed heartbeats for
+	// this long.
+	//
+	// Defaults to 45 seconds.
+	idleAfter time.Duration
 
-	// register/unregister channels for connections
-	register   chan *websocket.Conn
-	unregister chan *websocket.Conn
+	// typingDebounce is the minimum gap between typing events forwarded
+	// for one connection.
+	//
+	// Defaults to 2 seconds.
+	typingDebounce time.Duration
+
+	// subscribers holds all active websocket connections. Once closing
+	// is set by Shutdown no more are admitted.
+	subscribersMu sync.RWMutex
+	subscribers   map[*client]struct{}
+	closing       bool
+
+	// stop ends the hub's background goroutines
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	// rooms holds per-room options set through ConfigureRoom, and
+	// roomHooks the callbacks registered through OnRoomMessage. mutes and
+	// bans hold host moderation, keyed by room and then user ID.
+	roomsMu   sync.RWMutex
+	rooms     map[string]RoomOptions
+	roomHooks []func(room string, msg models.Message)
+	mutes     map[string]map[string]time.Time
+	bans      map[string]map[string]struct{}
+
+	// pseudonyms holds the IDs anonymous members go by instead of their
+	// user IDs, keyed by room and then user ID.
+	pseudonyms map[string]map[string]string
+
+	// filters is the safety chain for rooms without their own.
+	// Defaults to DefaultFilters with empty wordlists and the default
+	// crisis resources.
+	filters FilterChain
+
+	// persist stores a chat message before it is acknowledged and reports
+	// whether it was a retry of a message already stored.
+	// Defaults to db.SaveMessage.
+	persist func(ctx context.Context, msg *models.Message) (bool, error)
+
+	// updateStatus records a delivery or read receipt for a direct message.
+	// Defaults to db.UpdateMessageStatus.
+	updateStatus func(ctx context.Context, id uint, receiverID, status string) (*models.Message, error)
+
+	// loadBlocks returns the users a user has blocked or been blocked by.
+	// Defaults to db.GetBlockRelations.
+	loadBlocks func(ctx context.Context, userID string) ([]string, error)
+
+	// startVisit and endVisit record who was in a room when, which
+	// decides the room messages a user may report. Default to
+	// db.StartRoomVisit and db.EndRoomVisit.
+	startVisit func(ctx context.Context, instance, room, userID string) (int, error)
+	endVisit   func(ctx context.Context, id int) error
+
+	// instance names this server in the visits it records, so on startup
+	// it ends only its own leftover ones. Defaults to the host name.
+	instance string
+
+	// aliasKey keys AnonymousName; see SetAliasSecret.
+	aliasKey []byte
+
+	// blockRefreshes counts RefreshBlocks calls, so a connection whose
+	// blocks were loaded before it was registered can tell it missed one.
+	blockRefreshes atomic.Uint64
+
+	// identity returns a user's display name and anonymity preference.
+	// Defaults to db.GetChatIdentity.
+	identity func(ctx context.Context, userID string) (db.ChatIdentity, error)
+
+	// canMessage reports whether a user's DM setting lets the sender
+	// message them. Defaults to db.CanDirectMessage.
+	canMessage func(ctx context.Context, senderID, receiverID string) (bool, error)
+
+	// logf controls where logs are sent.
+	// Defaults to log.Printf.
+	logf func(f string, v ...any)
+
+	validator *MessageHandler
 }
 
 // NewHub creates a new websocket hub
 func NewHub() *Hub {
 	h := &Hub{
-		subscribers: make(map[*websocket.Conn]struct{}),
-		broadcast:   make(chan models.Message, 256),
-		register:    make(chan *websocket.Conn),
-		unregister:  make(chan *websocket.Conn),
+		subscriberMessageBuffer: 16,
+		writeTimeout:            5 * time.Second,
+		readTimeout:             90 * time.Second,
+		idleAfter:               45 * time.Second,
+		typingDebounce:          2 * time.Second,
+		subscribers:             make(map[*client]struct{}),
+		stop:                    make(chan struct{}),
+		rooms:                   make(map[string]RoomOptions),
+		mutes:                   make(map[string]map[string]time.Time),
+		bans:                    make(map[string]map[string]struct{}),
+		pseudonyms:              make(map[string]map[string]string),
+		persist:                 db.SaveMessage,
+		updateStatus:            db.UpdateMessageStatus,
+		filters:                 DefaultFilters(Wordlists{}, nil),
+		loadBlocks:              db.GetBlockRelations,
+		startVisit:              db.StartRoomVisit,
+		endVisit:                db.EndRoomVisit,
+		identity:                db.GetChatIdentity,
+		canMessage:              db.CanDirectMessage,
+		logf:                    log.Printf,
+		validator:               NewMessageHandler(),
 	}
-	go h.run()
+	h.instance, _ = os.Hostname()
+	h.aliasKey = make([]byte, 32)
+	rand.Read(h.aliasKey)
+	go h.watchPresence()
 	return h
 }
 
-// run handles the hub's main loop
-func (h *Hub) run() {
+// addSubscriber registers a client.
+func (h *Hub) addSubscriber(c *client) {
+	h.subscribersMu.Lock()
+	h.subscribers[c] = struct{}{}
+	n := len(h.subscribers)
+	h.subscribersMu.Unlock()
+	h.logf("Client connected. Total subscribers: %d", n)
+}
+
+// deleteSubscriber removes a client.
+func (h *Hub) deleteSubscriber(c *client) {
+	h.subscribersMu.Lock()
+	delete(h.subscribers, c)
+	n := len(h.subscribers)
+	h.subscribersMu.Unlock()
+	h.logf("Client disconnected. Total subscribers: %d", n)
+}
+
+// shouldReceive reports whether c is an audience of msg. Room messages go to
+// everyone in the room; direct messages only reach the receiver and the
+// sender's other tabs. Nobody is told about their own typing or presence,
+// and nothing from a blocked user is ever delivered.
+// Callers must hold subscribersMu.
+func (h *Hub) shouldReceive(c *client, msg models.Message) bool {
+	if c.blocks(msg.SenderID) {
+		return false
+	}
+	if (msg.Type == TypeTyping || msg.Type == TypePresence) && c.userID == msg.SenderID {
+		return false
+	}
+	if msg.ReceiverID == "" {
+		return msg.Room == "" || c.room == msg.Room
+	}
+	return c.userID == msg.ReceiverID || c.userID == msg.SenderID
+}
+
+// Broadcast queues msg for every client in its audience. It never blocks:
+// a client whose queue is full is kicked rather than slowing everyone else.
+// msg carries the sender's user ID, which is swapped for their pseudonym
+// if they are anonymous in the room.
+func (h *Hub) Broadcast(msg models.Message) {
+	public := msg
+	public.SenderID = h.publicID(msg.Room, msg.SenderID)
+	data, err := json.Marshal(public)
+	if err != nil {
+		h.logf("Error marshaling message: %v", err)
+		return
+	}
+
+	h.subscribersMu.RLock()
+	defer h.subscribersMu.RUnlock()
+
+	for c := range h.subscribers {
+		if !h.shouldReceive(c, msg) {
+			continue
+		}
+		out := outbound{data: data}
+		if msg.ReceiverID != "" && c.userID == msg.ReceiverID {
+			out.delivered = msg.ID
+		}
+		h.queue(c, out)
+	}
+}
+
+// queue hands out to c's writer without blocking.
+func (h *Hub) queue(c *client, out outbound) {
+	select {
+	case c.msgs <- out:
+	default:
+		go c.closeSlow()
+	}
+}
+
+// send marshals msg and queues it for c alone.
+func (h *Hub) send(c *client, msg models.Message) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		h.logf("Error marshaling message: %v", err)
+		return
+	}
+	h.queue(c, outbound{data: data})
+}
+
+// sendToUser queues msg for every connection belonging to userID.
+func (h *Hub) sendToUser(userID string, msg models.Message) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		h.logf("Error marshaling message: %v", err)
+		return
+	}
+
+	h.subscribersMu.RLock()
+	defer h.subscribersMu.RUnlock()
+	for c := range h.subscribers {
+		if c.userID == userID {
+			h.queue(c, outbound{data: data})
+		}
+	}
+}
+
+// receipt advances the status of a direct message and, if it changed,
+// tells the original sender.
+func (h *Hub) receipt(id uint, receiverID, status string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	msg, err := h.updateStatus(ctx, id, receiverID, status)
+	if err != nil {
+		h.logf("Error updating message %d to %s: %v", id, status, err)
+		return
+	}
+	if msg == nil {
+		return
+	}
+	msg.Type = TypeReceipt
+	h.sendToUser(msg.SenderID, *msg)
+}
+
+// writeLoop is the only goroutine writing to c's connection. It drains the
+// outbound queue until ctx is cancelled or a write fails.
+func (h *Hub) writeLoop(ctx context.Context, c *client) error {
 	for {
 		select {
-		case conn := <-h.register:
-			h.mu.Lock()
-			h.subscribers[conn] = struct{}{}
-			h.mu.Unlock()
-			log.Printf("Client connected. Total subscribers: %d", len(h.subscribers))
-
-		case conn := <-h.unregister:
-			h.mu.Lock()
-			if _, ok := h.subscribers[conn]; ok {
-				delete(h.subscribers, conn)
-				conn.Close(websocket.StatusNormalClosure, "unregistered")
+		case out := <-c.msgs:
+			wctx, cancel := context.WithTimeout(ctx, h.writeTimeout)
+			err := c.conn.Write(wctx, websocket.MessageText, out.data)
+			cancel()
+			if err != nil {
+				return err
 			}
-			h.mu.Unlock()
-			log.Printf("Client disconnected. Total subscribers: %d", len(h.subscribers))
-
-		case msg := <-h.broadcast:
-			h.mu.RLock()
-			for conn := range h.subscribers {
-				go func(c *websocket.Conn, m models.Message) {
-					ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-					defer cancel()
-
-					data, err := json.Marshal(m)
-					if err != nil {
-						log.Printf("Error marshaling message: %v", err)
-						return
-					}
-
-					err = c.Write(ctx, websocket.MessageText, data)
-					if err != nil {
-						log.Printf("Error writing to websocket: %v", err)
-						h.unregister <- c
-					}
-				}(conn, msg)
+			if out.delivered != 0 {
+				go h.receipt(out.delivered, c.userID, db.MessageDelivered)
 			}
-			h.mu.RUnlock()
+		case <-ctx.Done():
+			return ctx.Err()
 		}
 	}
 }
 
-// Broadcast sends a message to all connected clients
-func (h *Hub) Broadcast(msg models.Message) {
+// Shutdown stops admitting connections and sends every subscriber a
+// going-away close frame. It returns once all of them have finished the
+// closing handshake, or drops the rest when ctx is done.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.stopOnce.Do(func() { close(h.stop) })
+
+	h.subscribersMu.Lock()
+	h.closing = true
+	clients := make([]*client, 0, len(h.subscribers))
+	for c := range h.subscribers {
+		clients = append(clients, c)
+	}
+	h.subscribersMu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, c := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.close(websocket.StatusGoingAway, "server is shutting down")
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
 	select {
-	case h.broadcast <- msg:
-	default:
-		log.Println("Broadcast channel full, dropping message")
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		for _, c := range clients {
+			if c.conn != nil {
+				c.conn.CloseNow()
+			}
+		}
+		return ctx.Err()
 	}
 }
 
 // HandleConnection handles a new websocket connection
 func (h *Hub) HandleConnection(w http.ResponseWriter, r *http.Request) {
+	userID := handler.GetUserIDFromContext(r)
+	if userID == 0 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	name := "Guest"
+	if claims, ok := handler.UserFromContext(r.Context()); ok {
+		if n, ok := claims["name"].(string); ok && n != "" {
+			name = n
+		}
+	}
+
+	err := h.serve(w, r, strconv.Itoa(userID), name)
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	if websocket.CloseStatus(err) == websocket.StatusNormalClosure ||
+		websocket.CloseStatus(err) == websocket.StatusGoingAway {
+		return
+	}
+	if err != nil {
+		h.logf("Websocket error: %v", err)
+	}
+}
+
+// serve accepts the websocket for an authenticated user and runs it until
+// the connection drops. Reads happen on this goroutine; writes happen on a
+// dedicated writer so a slow peer never blocks the reader or the hub.
+func (h *Hub) serve(w http.ResponseWriter, r *http.Request, userID, name string) error {
 	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{
 		OriginPatterns: []string{"*"}, // Allow all origins for development
 	})
 	if err != nil {
-		log.Printf("Websocket accept error: %v", err)
-		return
+		return err
 	}
+	defer conn.CloseNow()
 
-	// Register the connection
-	h.register <- conn
-
-	// Handle incoming messages with rate limiting
-	limiter := rate.NewLimiter(rate.Every(time.Millisecond*100), 10)
+	c := newClient(conn, userID, name, h.subscriberMessageBuffer)
+	if room := r.URL.Query().Get("room"); room != "" {
+		c.room = room
+	}
+	c.locale = safety.RequestRegion(r)
+	if h.isBanned(c.room, c.userID) {
+		return conn.Close(websocket.StatusPolicyViolation, "removed from room")
+	}
+	h.applyIdentity(c)
 
-	// Clean up on disconnect
+	// Blocks are loaded before c is registered so nothing from a blocked
+	// user reaches it, and again if RefreshBlocks ran in between, since
+	// that couldn't see c yet.
+	refreshes := h.blockRefreshes.Load()
+	h.loadBlocksFor(c)
+	var refused *admitError
+	if err := h.admit(c); errors.As(err, &refused) {
+		return conn.Close(refused.code, refused.reason)
+	}
+	if h.blockRefreshes.Load() != refreshes {
+		h.loadBlocksFor(c)
+	}
+	defer h.recordVisit(c)()
+	h.join(c)
 	defer func() {
-		h.unregister <- conn
+		h.deleteSubscriber(c)
+		h.leave(c)
 	}()
 
-	// Read messages from client
-	for {
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
 
-		_, data, err := conn.Read(ctx)
+	writeErr := make(chan error, 1)
+	go func() {
+		writeErr <- h.writeLoop(ctx, c)
+		// Unblock the reader if the writer gave up first
 		cancel()
+	}()
 
+	// Handle incoming messages with rate limiting
+	limiter := rate.NewLimiter(rate.Every(time.Millisecond*100), 10)
+
+	// Read messages from client
+	for {
+		rctx, rcancel := context.WithTimeout(ctx, h.readTimeout)
+		_, data, err := conn.Read(rctx)
+		rcancel()
 		if err != nil {
-			if websocket.CloseStatus(err) == websocket.StatusNormalClosure ||
-				websocket.CloseStatus(err) == websocket.StatusGoingAway {
-				return
+			cancel()
+			if werr := <-writeErr; werr != nil && !errors.Is(werr, context.Canceled) {
+				return werr
 			}
-			log.Printf("Websocket read error: %v", err)
-			return
+			return err
 		}
 
 		// Rate limit incoming messages
-		if err := limiter.Wait(context.Background()); err != nil {
-			log.Printf("Rate limit exceeded: %v", err)
+		if err := limiter.Wait(ctx); err != nil {
+			h.logf("Rate limit exceeded: %v", err)
 			continue
 		}
 
 		// Parse incoming message
 		var msg models.Message
 		if err := json.Unmarshal(data, &msg); err != nil {
-			log.Printf("Error unmarshaling message: %v", err)
+			h.logf("Error unmarshaling message: %v", err)
 			continue
 		}
 
-		// Set timestamp if not provided
-		if msg.CreatedAt.IsZero() {
-			msg.CreatedAt = time.Now()
+		h.handleFrame(c, msg)
+	}
+}
+
+// handleFrame dispatches a frame read from c by its type.
+func (h *Hub) handleFrame(c *client, msg models.Message) {
+	switch msg.Type {
+	case TypeHeartbeat:
+		h.heartbeat(c, msg.Status)
+	case TypeTyping:
+		h.typing(c)
+	case TypeReaction:
+		if !h.isMuted(c.room, c.userID) {
+			h.react(c, msg.Content)
+		}
+	case TypeMute, TypeUnmute, TypeRemove:
+		h.moderate(c, msg)
+	case TypeRead:
+		if msg.ID != 0 {
+			h.receipt(msg.ID, c.userID, db.MessageRead)
 		}
+	case "", TypeMessage:
+		h.handleMessage(c, msg)
+	default:
+		h.sendError(c, msg.ClientID, "unknown frame type")
+	}
+}
 
-		// Broadcast the message
+// handleMessage persists a chat message from c, acknowledges it and
+// broadcasts it. Retries of an already stored ClientID are acknowledged
+// again but never re-broadcast.
+func (h *Hub) handleMessage(c *client, msg models.Message) {
+	// Never trust the client with its own identity
+	msg.Type = TypeMessage
+	msg.Room = c.room
+	msg.SenderID = c.userID
+	msg.SenderName = c.name
+	msg.Locale = c.locale
+	msg.Status = ""
+	if msg.ClientID == "" {
+		msg.ClientID = strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+
+	if h.roomOptions(c.room).ReactionsOnly {
+		h.sendError(c, msg.ClientID, "this room only allows reactions")
+		return
+	}
+
+	if h.isMuted(c.room, c.userID) {
+		h.sendError(c, msg.ClientID, "you are muted in this room")
+		return
+	}
+
+	if msg.ReceiverID != "" {
+		h.subscribersMu.RLock()
+		blocked := c.blocks(msg.ReceiverID)
+		h.subscribersMu.RUnlock()
+		if blocked {
+			h.sendError(c, msg.ClientID, "you can't message this person")
+			return
+		}
+		if !h.dmAllowed(c.userID, msg.ReceiverID) {
+			h.sendError(c, msg.ClientID, "this person isn't accepting messages from you")
+			return
+		}
+	}
+
+	if err := h.validator.ValidateMessage(&msg); err != nil {
+		h.sendError(c, msg.ClientID, err.Error())
+		return
+	}
+
+	if err := h.roomFilters(c.room).Filter(&msg); err != nil {
+		h.send(c, models.Message{
+			Type:      TypeError,
+			ClientID:  msg.ClientID,
+			SenderID:  c.userID,
+			Content:   err.Error(),
+			CreatedAt: time.Now(),
+			Resources: msg.Resources,
+		})
+		return
+	}
+
+	// Resources are for the sender's eyes only: the rest of the room
+	// must not learn that a crisis filter fired
+	resources := msg.Resources
+	msg.Resources = nil
+
+	// The server's clock decides when a message was sent
+	msg.CreatedAt = time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	duplicate, err := h.persist(ctx, &msg)
+	cancel()
+	if err != nil {
+		h.logf("Error persisting message from %s: %v", c.userID, err)
+		h.sendError(c, msg.ClientID, "message could not be saved, please retry")
+		return
+	}
+
+	h.send(c, models.Message{
+		ID:         msg.ID,
+		Type:       TypeAck,
+		ClientID:   msg.ClientID,
+		SenderID:   msg.SenderID,
+		ReceiverID: msg.ReceiverID,
+		Status:     msg.Status,
+		CreatedAt:  msg.CreatedAt,
+	})
+
+	if resources != nil {
+		h.send(c, models.Message{
+			Type:      TypeSupport,
+			ClientID:  msg.ClientID,
+			SenderID:  "system",
+			CreatedAt: msg.CreatedAt,
+			Resources: resources,
+		})
+	}
+
+	if !duplicate {
 		h.Broadcast(msg)
+		h.notifyRoomMessage(msg)
 	}
 }
+
+// sendError tells c that the frame identified by clientID was rejected.
+func (h *Hub) sendError(c *client, clientID, reason string) {
+	h.send(c, models.Message{
+		Type:      TypeError,
+		ClientID:  clientID,
+		SenderID:  c.userID,
+		Content:   reason,
+		CreatedAt: time.Now(),
+	})
+}
@@ -0,0 +1,459 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"Remainwith/db"
+	"Remainwith/internal/models"
+
+	"github.com/coder/websocket"
+)
+
+// newTestHub returns a hub with logging silenced.
+func newTestHub() *Hub {
+	h := NewHub()
+	h.logf = func(string, ...any) {}
+	return h
+}
+
+func TestSlowSubscriberIsKicked(t *testing.T) {
+	h := newTestHub()
+
+	fast := newClient(nil, "1", "fast", 4)
+	slow := newClient(nil, "2", "slow", 4)
+	var kicked atomic.Bool
+	slow.closeSlow = func() { kicked.Store(true) }
+	h.addSubscriber(fast)
+	h.addSubscriber(slow)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range fast.msgs {
+		}
+	}()
+
+	for i := 0; i < 10; i++ {
+		h.Broadcast(models.Message{SenderID: "1", Content: "hi"})
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for !kicked.Load() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if !kicked.Load() {
+		t.Fatal("slow subscriber was not kicked")
+	}
+	if len(slow.msgs) != cap(slow.msgs) {
+		t.Fatalf("slow queue has %d messages, want it full at %d", len(slow.msgs), cap(slow.msgs))
+	}
+
+	close(fast.msgs)
+	<-done
+}
+
+func TestDirectMessageAudience(t *testing.T) {
+	h := newTestHub()
+
+	sender := newClient(nil, "1", "a", 4)
+	receiver := newClient(nil, "2", "b", 4)
+	other := newClient(nil, "3", "c", 4)
+	for _, c := range []*client{sender, receiver, other} {
+		h.addSubscriber(c)
+	}
+
+	h.Broadcast(models.Message{ID: 7, SenderID: "1", ReceiverID: "2", Content: "hi"})
+
+	if len(sender.msgs) != 1 || len(receiver.msgs) != 1 || len(other.msgs) != 0 {
+		t.Fatalf("queue lengths = %d/%d/%d, want 1/1/0", len(sender.msgs), len(receiver.msgs), len(other.msgs))
+	}
+	if out := <-receiver.msgs; out.delivered != 7 {
+		t.Fatalf("receiver frame delivered = %d, want 7", out.delivered)
+	}
+	if out := <-sender.msgs; out.delivered != 0 {
+		t.Fatalf("sender frame delivered = %d, want 0", out.delivered)
+	}
+}
+
+// BenchmarkBroadcast measures fan-out into per-client queues, with each
+// client drained by its own goroutine standing in for the writer.
+func BenchmarkBroadcast(b *testing.B) {
+	for _, n := range []int{100, 1000, 5000, 10000} {
+		b.Run(fmt.Sprintf("subscribers=%d", n), func(b *testing.B) {
+			h := newTestHub()
+			var wg sync.WaitGroup
+			for i := 0; i < n; i++ {
+				c := newClient(nil, strconv.Itoa(i), "", 256)
+				c.closeSlow = func() {}
+				h.addSubscriber(c)
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for range c.msgs {
+					}
+				}()
+			}
+
+			msg := models.Message{Type: TypeMessage, SenderID: "0", Content: "benchmark"}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				h.Broadcast(msg)
+			}
+			b.StopTimer()
+			b.ReportMetric(float64(b.N*n)/b.Elapsed().Seconds(), "deliveries/s")
+
+			for c := range h.subscribers {
+				close(c.msgs)
+			}
+			wg.Wait()
+		})
+	}
+}
+
+// BenchmarkBroadcastWebsocket measures end-to-end delivery over real
+// websocket connections through serve's writer goroutines.
+func BenchmarkBroadcastWebsocket(b *testing.B) {
+	for _, n := range []int{100, 1000} {
+		b.Run(fmt.Sprintf("subscribers=%d", n), func(b *testing.B) {
+			h := newTestHub()
+			h.subscriberMessageBuffer = 1024
+
+			var ids atomic.Int64
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				h.serve(w, r, strconv.FormatInt(ids.Add(1), 10), "bench")
+			}))
+			defer srv.Close()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var received atomic.Int64
+			var wg sync.WaitGroup
+			url := "ws" + strings.TrimPrefix(srv.URL, "http")
+			for i := 0; i < n; i++ {
+				conn, _, err := websocket.Dial(ctx, url, nil)
+				if err != nil {
+					b.Fatalf("dial %d: %v", i, err)
+				}
+				conn.SetReadLimit(1 << 20)
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer conn.CloseNow()
+					for {
+						if _, _, err := conn.Read(ctx); err != nil {
+							return
+						}
+						received.Add(1)
+					}
+				}()
+			}
+
+			// Wait for every connection to be registered
+			for {
+				h.subscribersMu.RLock()
+				registered := len(h.subscribers)
+				h.subscribersMu.RUnlock()
+				if registered == n {
+					break
+				}
+				time.Sleep(time.Millisecond)
+			}
+
+			msg := models.Message{Type: TypeMessage, SenderID: "0", Content: "benchmark"}
+			want := int64(b.N * n)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				h.Broadcast(msg)
+				// Stay within the queue size so nobody is kicked as slow
+				if (i+1)%512 == 0 {
+					for received.Load() < int64((i+1)*n) {
+						time.Sleep(100 * time.Microsecond)
+					}
+				}
+			}
+			for received.Load() < want {
+				time.Sleep(100 * time.Microsecond)
+			}
+			b.StopTimer()
+			b.ReportMetric(float64(want)/b.Elapsed().Seconds(), "deliveries/s")
+
+			cancel()
+			wg.Wait()
+		})
+	}
+}
+
+func TestShutdownClosesSubscribers(t *testing.T) {
+	h := newTestHub()
+	var ids atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.serve(w, r, strconv.FormatInt(ids.Add(1), 10), "test")
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	// Clients only answer close frames while reading
+	closed := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		conn, _, err := websocket.Dial(ctx, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			defer conn.CloseNow()
+			for {
+				if _, _, err := conn.Read(ctx); err != nil {
+					closed <- err
+					return
+				}
+			}
+		}()
+	}
+	for h.ConnectedUsers("") != 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := h.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-closed; websocket.CloseStatus(err) != websocket.StatusGoingAway {
+			t.Errorf("client %d closed with %v, want going away", i, err)
+		}
+	}
+
+	conn, _, err := websocket.Dial(ctx, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.CloseNow()
+	if _, _, err := conn.Read(ctx); websocket.CloseStatus(err) != websocket.StatusGoingAway {
+		t.Errorf("connecting after shutdown: %v, want going away", err)
+	}
+}
+
+// messageStore stands in for the messages table behind persist and
+// updateStatus.
+type messageStore struct {
+	mu     sync.Mutex
+	byKey  map[string]models.Message // sender ID + client ID
+	byID   map[uint]*models.Message
+	nextID uint
+	fail   bool
+}
+
+func newStoreHub() (*Hub, *messageStore) {
+	h := newTestHub()
+	s := &messageStore{byKey: make(map[string]models.Message), byID: make(map[uint]*models.Message)}
+	h.persist = s.save
+	h.updateStatus = s.update
+	h.canMessage = func(context.Context, string, string) (bool, error) { return true, nil }
+	return h, s
+}
+
+func (s *messageStore) save(_ context.Context, msg *models.Message) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail {
+		return false, fmt.Errorf("database down")
+	}
+	key := msg.SenderID + "\x00" + msg.ClientID
+	if stored, ok := s.byKey[key]; ok {
+		*msg = stored
+		return true, nil
+	}
+	s.nextID++
+	msg.ID = s.nextID
+	msg.Status = db.MessageSent
+	s.byKey[key] = *msg
+	stored := *msg
+	s.byID[msg.ID] = &stored
+	return false, nil
+}
+
+// update advances statuses forward only, like db.UpdateMessageStatus.
+func (s *messageStore) update(_ context.Context, id uint, receiverID, status string) (*models.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	msg, ok := s.byID[id]
+	if !ok || msg.ReceiverID != receiverID {
+		return nil, nil
+	}
+	if msg.Status != db.MessageSent && !(msg.Status == db.MessageDelivered && status == db.MessageRead) {
+		return nil, nil
+	}
+	msg.Status = status
+	updated := *msg
+	return &updated, nil
+}
+
+// frames drains and decodes the frames queued for c.
+func frames(t *testing.T, c *client) []models.Message {
+	t.Helper()
+	var got []models.Message
+	for len(c.msgs) > 0 {
+		var msg models.Message
+		if err := json.Unmarshal((<-c.msgs).data, &msg); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, msg)
+	}
+	return got
+}
+
+func TestMessageAckedAfterPersist(t *testing.T) {
+	h, store := newStoreHub()
+	sender := newClient(nil, "1", "a", 8)
+	other := newClient(nil, "2", "b", 8)
+	h.addSubscriber(sender)
+	h.addSubscriber(other)
+
+	store.fail = true
+	h.handleMessage(sender, models.Message{ClientID: "c1", Content: "hello"})
+	if got := frames(t, sender); len(got) != 1 || got[0].Type != TypeError || got[0].ClientID != "c1" {
+		t.Fatalf("sender got %+v after a failed save, want one error", got)
+	}
+	if len(other.msgs) != 0 {
+		t.Fatal("unsaved message was broadcast")
+	}
+
+	store.fail = false
+	backdated := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+	h.handleMessage(sender, models.Message{ClientID: "c1", Content: "hello", CreatedAt: backdated})
+	got := frames(t, sender)
+	if len(got) != 2 || got[0].Type != TypeAck || got[0].ID == 0 || got[0].Status != db.MessageSent {
+		t.Fatalf("sender got %+v, want an ack with the stored ID first", got)
+	}
+	if time.Since(got[0].CreatedAt) > time.Minute {
+		t.Errorf("CreatedAt %v taken from the client", got[0].CreatedAt)
+	}
+	if got[1].Type != TypeMessage || got[1].ID != got[0].ID {
+		t.Errorf("echo %+v doesn't match the ack", got[1])
+	}
+	if got := frames(t, other); len(got) != 1 || got[0].Type != TypeMessage {
+		t.Errorf("other got %+v, want the message", got)
+	}
+}
+
+func TestRetryIsDeduplicated(t *testing.T) {
+	h, _ := newStoreHub()
+	sender := newClient(nil, "1", "a", 8)
+	other := newClient(nil, "2", "b", 8)
+	h.addSubscriber(sender)
+	h.addSubscriber(other)
+
+	h.handleMessage(sender, models.Message{ClientID: "c1", Content: "hello"})
+	first := frames(t, sender)
+	h.handleMessage(sender, models.Message{ClientID: "c1", Content: "hello"})
+	retry := frames(t, sender)
+
+	if len(retry) != 1 || retry[0].Type != TypeAck || retry[0].ID != first[0].ID {
+		t.Fatalf("retry got %+v, want only an ack for message %d", retry, first[0].ID)
+	}
+	if !retry[0].CreatedAt.Equal(first[0].CreatedAt) {
+		t.Errorf("retry ack CreatedAt %v, want the stored %v", retry[0].CreatedAt, first[0].CreatedAt)
+	}
+	if got := frames(t, other); len(got) != 1 {
+		t.Errorf("other got %d copies, want 1", len(got))
+	}
+}
+
+func TestReceiptsOnlyMoveForward(t *testing.T) {
+	h, _ := newStoreHub()
+	sender := newClient(nil, "1", "a", 8)
+	receiver := newClient(nil, "2", "b", 8)
+	other := newClient(nil, "3", "c", 8)
+	for _, c := range []*client{sender, receiver, other} {
+		h.addSubscriber(c)
+	}
+
+	h.handleMessage(sender, models.Message{ClientID: "c1", ReceiverID: "2", Content: "hi"})
+	id := frames(t, sender)[0].ID
+	frames(t, receiver)
+
+	steps := []struct {
+		from   *client
+		status string
+		want   string // receipt status the sender sees, if any
+	}{
+		{other, db.MessageRead, ""},
+		{receiver, db.MessageDelivered, db.MessageDelivered},
+		{receiver, db.MessageDelivered, ""},
+		{receiver, db.MessageRead, db.MessageRead},
+		{receiver, db.MessageDelivered, ""},
+		{receiver, db.MessageRead, ""},
+	}
+	for i, step := range steps {
+		if step.status == db.MessageRead {
+			h.handleFrame(step.from, models.Message{Type: TypeRead, ID: id})
+		} else {
+			h.receipt(id, step.from.userID, step.status)
+		}
+		got := frames(t, sender)
+		switch {
+		case step.want == "" && len(got) != 0:
+			t.Errorf("step %d: sender got %+v, want nothing", i, got)
+		case step.want != "" && (len(got) != 1 || got[0].Type != TypeReceipt || got[0].Status != step.want || got[0].ClientID != "c1"):
+			t.Errorf("step %d: sender got %+v, want a %s receipt for c1", i, got, step.want)
+		}
+	}
+	if len(receiver.msgs) != 0 || len(other.msgs) != 0 {
+		t.Error("receipts went to someone other than the sender")
+	}
+}
+
+func TestAdmit(t *testing.T) {
+	h := newTestHub()
+	seats := map[string]bool{"1": true}
+	h.ConfigureRoom("matched", RoomOptions{
+		Capacity: 2,
+		Admit: func(userID string) bool {
+			ok := seats[userID]
+			delete(seats, userID)
+			return ok
+		},
+	})
+
+	join := func(userID, room string) error {
+		c := newClient(nil, userID, "", 8)
+		c.room = room
+		return h.admit(c)
+	}
+
+	tests := []struct {
+		name   string
+		userID string
+		room   string
+		want   error
+	}{
+		{"default room", "1", DefaultRoom, nil},
+		{"unknown room", "1", "made-up", errUnknownRoom},
+		{"unreserved", "2", "matched", errNoSeat},
+		{"reserved", "1", "matched", nil},
+		{"second tab", "1", "matched", nil},
+		{"reservation used up", "3", "matched", errNoSeat},
+	}
+	for _, tt := range tests {
+		if err := join(tt.userID, tt.room); err != tt.want {
+			t.Errorf("%s: admit = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if got := h.ConnectedUsers("matched"); got != 1 {
+		t.Errorf("%d users in the matched room, want 1", got)
+	}
+}
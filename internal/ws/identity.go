@@ -0,0 +1,140 @@
+package ws
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// Words for anonymous aliases, e.g. "Quiet Heron".
+var (
+	aliasAdjectives = []string{
+		"Quiet", "Gentle", "Steady", "Kind", "Calm", "Warm", "Bright", "Patient",
+		"Soft", "Brave", "Hopeful", "Curious", "Still", "Wandering", "Easy", "Open",
+	}
+	aliasNouns = []string{
+		"Heron", "Fox", "Willow", "River", "Sparrow", "Pine", "Otter", "Lantern",
+		"Moth", "Fern", "Hare", "Comet", "Pebble", "Owl", "Maple", "Wren",
+	}
+)
+
+// AnonymousName returns the alias userID goes by in room. It is stable for
+// the pair, so others can follow a conversation, but differs between rooms.
+// It is keyed with the hub's alias secret, so knowing a user ID is not
+// enough to work out someone's alias.
+func (h *Hub) AnonymousName(userID, room string) string {
+	mac := hmac.New(sha256.New, h.aliasKey)
+	mac.Write([]byte(room))
+	mac.Write([]byte{0})
+	mac.Write([]byte(userID))
+	n := binary.BigEndian.Uint32(mac.Sum(nil))
+	return aliasAdjectives[n%uint32(len(aliasAdjectives))] + " " + aliasNouns[(n/uint32(len(aliasAdjectives)))%uint32(len(aliasNouns))]
+}
+
+// SetAliasSecret keys anonymous aliases with secret, so they survive
+// restarts; without it each hub picks a random key. Call it before
+// serving connections.
+func (h *Hub) SetAliasSecret(secret []byte) {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("remainwith anonymous aliases"))
+	h.aliasKey = mac.Sum(nil)
+}
+
+// pseudonymPrefix starts every pseudonymous member ID, so they can never
+// be mistaken for, or looked up as, a user ID.
+const pseudonymPrefix = "anon-"
+
+// applyIdentity replaces c's name with the user's display name, or with an
+// alias when they chose anonymous mode and the room allows it. Anonymous
+// members also get a pseudonymous ID for the room, which frames carry in
+// place of their user ID. On error the name from the session is kept.
+func (h *Hub) applyIdentity(c *client) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	identity, err := h.identity(ctx, c.userID)
+	if err != nil {
+		h.logf("Error loading identity for %s: %v", c.userID, err)
+		return
+	}
+	if identity.AnonymousCampfires && h.roomOptions(c.room).AllowAnonymous {
+		c.name = h.AnonymousName(c.userID, c.room)
+		h.assignPseudonym(c.room, c.userID)
+		return
+	}
+	h.dropPseudonym(c.room, c.userID)
+	if identity.DisplayName != "" {
+		c.name = identity.DisplayName
+	}
+}
+
+// assignPseudonym gives userID a random ID in room, unless they already
+// have one. It lasts as long as the hub, so an anonymous member keeps it
+// across tabs and reconnects.
+func (h *Hub) assignPseudonym(room, userID string) {
+	h.roomsMu.Lock()
+	defer h.roomsMu.Unlock()
+	if _, ok := h.pseudonyms[room][userID]; ok {
+		return
+	}
+	if h.pseudonyms[room] == nil {
+		h.pseudonyms[room] = make(map[string]string)
+	}
+	b := make([]byte, 8)
+	rand.Read(b)
+	h.pseudonyms[room][userID] = pseudonymPrefix + hex.EncodeToString(b)
+}
+
+// dropPseudonym makes userID appear under their own ID in room again.
+func (h *Hub) dropPseudonym(room, userID string) {
+	h.roomsMu.Lock()
+	delete(h.pseudonyms[room], userID)
+	h.roomsMu.Unlock()
+}
+
+// publicID returns the ID others in room know userID by: their pseudonym
+// if they are anonymous there, otherwise userID itself.
+func (h *Hub) publicID(room, userID string) string {
+	h.roomsMu.RLock()
+	defer h.roomsMu.RUnlock()
+	if id, ok := h.pseudonyms[room][userID]; ok {
+		return id
+	}
+	return userID
+}
+
+// resolveMember turns an ID seen in room back into a user ID. The real ID
+// behind a pseudonym never leaves the hub; unknown pseudonyms resolve to
+// the empty string.
+func (h *Hub) resolveMember(room, id string) string {
+	if !strings.HasPrefix(id, pseudonymPrefix) {
+		return id
+	}
+	h.roomsMu.RLock()
+	defer h.roomsMu.RUnlock()
+	for userID, pseudonym := range h.pseudonyms[room] {
+		if pseudonym == id {
+			return userID
+		}
+	}
+	return ""
+}
+
+// dmAllowed reports whether receiverID accepts direct messages from
+// senderID. Errors refuse the message.
+func (h *Hub) dmAllowed(senderID, receiverID string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ok, err := h.canMessage(ctx, senderID, receiverID)
+	if err != nil {
+		h.logf("Error checking DM setting for %s: %v", receiverID, err)
+		return false
+	}
+	return ok
+}
@@ -0,0 +1,163 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+
+	"Remainwith/db"
+	"Remainwith/internal/models"
+)
+
+func TestAnonymousNameIsStablePerRoom(t *testing.T) {
+	h := newTestHub()
+	a := h.AnonymousName("7", "campfire:1")
+	if a != h.AnonymousName("7", "campfire:1") {
+		t.Fatal("alias changed between calls")
+	}
+	differs := false
+	for _, room := range []string{"campfire:2", "campfire:3", "campfire:4"} {
+		if h.AnonymousName("7", room) != a {
+			differs = true
+		}
+	}
+	if !differs {
+		t.Error("alias is the same in every room")
+	}
+}
+
+func TestAnonymousNameNeedsTheSecret(t *testing.T) {
+	// Someone who knows the user IDs but not the secret gets aliases that
+	// don't line up with the real ones.
+	h, guess := newTestHub(), newTestHub()
+	h.SetAliasSecret([]byte("server secret"))
+	guess.SetAliasSecret([]byte("a guess"))
+
+	matches := 0
+	for id := 1; id <= 200; id++ {
+		userID := strconv.Itoa(id)
+		if h.AnonymousName(userID, "campfire:1") == guess.AnonymousName(userID, "campfire:1") {
+			matches++
+		}
+	}
+	// 256 aliases, so about one in 256 collide by chance
+	if matches > 10 {
+		t.Errorf("%d of 200 aliases recomputed without the secret", matches)
+	}
+
+	same := newTestHub()
+	same.SetAliasSecret([]byte("server secret"))
+	if h.AnonymousName("7", "campfire:1") != same.AnonymousName("7", "campfire:1") {
+		t.Error("alias changed with the same secret")
+	}
+}
+
+func TestApplyIdentity(t *testing.T) {
+	h := newTestHub()
+	h.identity = func(ctx context.Context, userID string) (db.ChatIdentity, error) {
+		return db.ChatIdentity{DisplayName: "Sky", AnonymousCampfires: true}, nil
+	}
+	h.ConfigureRoom("campfire:1", RoomOptions{AllowAnonymous: true})
+
+	lobby := newClient(nil, "7", "Account Name", 1)
+	h.applyIdentity(lobby)
+	if lobby.name != "Sky" {
+		t.Errorf("lobby name = %q, want display name", lobby.name)
+	}
+
+	campfire := newClient(nil, "7", "Account Name", 1)
+	campfire.room = "campfire:1"
+	h.applyIdentity(campfire)
+	if campfire.name != h.AnonymousName("7", "campfire:1") {
+		t.Errorf("campfire name = %q, want alias", campfire.name)
+	}
+}
+
+func TestDMRefusedByReceiverSetting(t *testing.T) {
+	h := newTestHub()
+	persisted := 0
+	h.persist = func(ctx context.Context, msg *models.Message) (bool, error) {
+		persisted++
+		return false, nil
+	}
+	h.canMessage = func(ctx context.Context, senderID, receiverID string) (bool, error) {
+		return receiverID != "3", nil
+	}
+
+	c := newClient(nil, "2", "b", 4)
+	h.addSubscriber(c)
+
+	h.handleMessage(c, models.Message{Type: TypeMessage, ClientID: "x", ReceiverID: "3", Content: "hello"})
+	if persisted != 0 {
+		t.Fatal("message to a user refusing DMs was persisted")
+	}
+	var frame models.Message
+	if err := json.Unmarshal((<-c.msgs).data, &frame); err != nil {
+		t.Fatal(err)
+	}
+	if frame.Type != TypeError {
+		t.Fatalf("got %q frame, want %q", frame.Type, TypeError)
+	}
+
+	h.handleMessage(c, models.Message{Type: TypeMessage, ClientID: "y", ReceiverID: "4", Content: "hello"})
+	if persisted != 1 {
+		t.Fatalf("persisted %d messages, want 1", persisted)
+	}
+}
+
+func TestAnonymousMembersGoByPseudonym(t *testing.T) {
+	h, _ := newStoreHub()
+	h.identity = func(ctx context.Context, userID string) (db.ChatIdentity, error) {
+		return db.ChatIdentity{DisplayName: "Sky", AnonymousCampfires: userID == "7"}, nil
+	}
+	h.ConfigureRoom("campfire:1", RoomOptions{AllowAnonymous: true, HostID: "1"})
+
+	host := newClient(nil, "1", "Host", 8)
+	anon := newClient(nil, "7", "Account Name", 8)
+	for _, c := range []*client{host, anon} {
+		c.room = "campfire:1"
+		h.applyIdentity(c)
+		h.addSubscriber(c)
+	}
+	h.join(anon)
+	roster := frames(t, anon)[0]
+	pseudonym := roster.ReceiverID
+	if !strings.HasPrefix(pseudonym, pseudonymPrefix) {
+		t.Fatalf("roster tells the anonymous member they are %q, want a pseudonym", pseudonym)
+	}
+	if _, err := strconv.Atoi(pseudonym); err == nil {
+		t.Fatal("pseudonym parses as a user ID")
+	}
+
+	h.typing(anon)
+	h.react(anon, "🌿")
+	h.handleMessage(anon, models.Message{ClientID: "c1", Content: "hello"})
+	seen := frames(t, host)
+	if len(seen) != 4 {
+		t.Fatalf("host got %d frames, want join, typing, reaction and message", len(seen))
+	}
+	for _, frame := range seen {
+		if frame.SenderID != pseudonym || strings.Contains(frame.SenderName, "Sky") {
+			t.Errorf("%s frame from %q (%q), want pseudonym %q", frame.Type, frame.SenderID, frame.SenderName, pseudonym)
+		}
+	}
+
+	// The host moderates through the pseudonym; it resolves on the server
+	h.handleFrame(host, models.Message{Type: TypeMute, ReceiverID: pseudonym})
+	if !h.isMuted("campfire:1", "7") {
+		t.Error("muting the pseudonym didn't mute the member")
+	}
+	if h.resolveMember("campfire:1", "anon-0000") != "" {
+		t.Error("an unknown pseudonym resolved to someone")
+	}
+
+	// Elsewhere, and for members who aren't anonymous, IDs are real
+	if got := h.publicID("campfire:1", "1"); got != "1" {
+		t.Errorf("host goes by %q, want their user ID", got)
+	}
+	if got := h.publicID("campfire:2", "7"); got != "7" {
+		t.Errorf("member goes by %q in another room, want their user ID", got)
+	}
+}
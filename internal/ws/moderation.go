@@ -0,0 +1,250 @@
+package ws
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"Remainwith/db"
+	"Remainwith/internal/models"
+
+	"github.com/coder/websocket"
+)
+
+// Moderation frame types.
+const (
+	TypeMute       = "mute"       // host → server; ReceiverID is the target, Content optional minutes
+	TypeUnmute     = "unmute"     // host → server; ReceiverID is the target
+	TypeRemove     = "remove"     // host → server; ReceiverID is the target
+	TypeModeration = "moderation" // server → target; Status is muted, unmuted or removed
+
+	ModerationMuted   = "muted"
+	ModerationUnmuted = "unmuted"
+	ModerationRemoved = "removed"
+)
+
+// defaultMute is how long a host mute lasts when no duration is given.
+const defaultMute = 10 * time.Minute
+
+// loadBlocksFor fills c's block set from the database.
+func (h *Hub) loadBlocksFor(c *client) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ids, err := h.loadBlocks(ctx, c.userID)
+	if err != nil {
+		h.logf("Error loading blocks for %s: %v", c.userID, err)
+		return
+	}
+
+	blocked := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		blocked[id] = struct{}{}
+	}
+
+	h.subscribersMu.Lock()
+	c.blocked = blocked
+	h.subscribersMu.Unlock()
+}
+
+// RefreshBlocks reloads block relations for every connection belonging to
+// the given users. Call it after a block or unblock so the change applies
+// to live connections immediately.
+func (h *Hub) RefreshBlocks(userIDs ...string) {
+	h.blockRefreshes.Add(1)
+
+	want := make(map[string]struct{}, len(userIDs))
+	for _, id := range userIDs {
+		want[id] = struct{}{}
+	}
+
+	h.subscribersMu.RLock()
+	var affected []*client
+	for c := range h.subscribers {
+		if _, ok := want[c.userID]; ok {
+			affected = append(affected, c)
+		}
+	}
+	h.subscribersMu.RUnlock()
+
+	for _, c := range affected {
+		h.loadBlocksFor(c)
+	}
+}
+
+// EndStaleVisits ends the room visits this server left open when it last
+// stopped. Call it on startup, before serving connections.
+func (h *Hub) EndStaleVisits(ctx context.Context) error {
+	return db.EndInstanceVisits(ctx, h.instance)
+}
+
+// recordVisit stores that c's user is in its room, so they can report
+// messages sent there, and returns a func that ends the visit.
+func (h *Hub) recordVisit(c *client) func() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	id, err := h.startVisit(ctx, h.instance, c.room, c.userID)
+	if err != nil {
+		h.logf("Error recording visit to %s by %s: %v", c.room, c.userID, err)
+		return func() {}
+	}
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := h.endVisit(ctx, id); err != nil {
+			h.logf("Error ending visit %d: %v", id, err)
+		}
+	}
+}
+
+// blocks reports whether c is in a block relation with userID.
+// Callers must hold subscribersMu.
+func (c *client) blocks(userID string) bool {
+	_, ok := c.blocked[userID]
+	return ok
+}
+
+// isHost reports whether c may moderate its room.
+func (h *Hub) isHost(c *client) bool {
+	host := h.roomOptions(c.room).HostID
+	return host != "" && host == c.userID
+}
+
+// MuteUser silences userID in room until d has passed.
+func (h *Hub) MuteUser(room, userID string, d time.Duration) {
+	h.roomsMu.Lock()
+	if h.mutes[room] == nil {
+		h.mutes[room] = make(map[string]time.Time)
+	}
+	h.mutes[room][userID] = time.Now().Add(d)
+	h.roomsMu.Unlock()
+
+	h.notifyModeration(room, userID, ModerationMuted,
+		"The host has muted you for "+d.Round(time.Minute).String()+".")
+}
+
+// UnmuteUser lifts a mute.
+func (h *Hub) UnmuteUser(room, userID string) {
+	h.roomsMu.Lock()
+	delete(h.mutes[room], userID)
+	h.roomsMu.Unlock()
+
+	h.notifyModeration(room, userID, ModerationUnmuted, "You can speak again.")
+}
+
+// isMuted reports whether userID is currently muted in room.
+func (h *Hub) isMuted(room, userID string) bool {
+	h.roomsMu.RLock()
+	defer h.roomsMu.RUnlock()
+	until, ok := h.mutes[room][userID]
+	return ok && time.Now().Before(until)
+}
+
+// RemoveUser disconnects userID from room and keeps them out of it.
+func (h *Hub) RemoveUser(room, userID, reason string) {
+	h.roomsMu.Lock()
+	if h.bans[room] == nil {
+		h.bans[room] = make(map[string]struct{})
+	}
+	h.bans[room][userID] = struct{}{}
+	h.roomsMu.Unlock()
+
+	if reason == "" {
+		reason = "You have been removed from this room."
+	}
+	h.notifyModeration(room, userID, ModerationRemoved, reason)
+
+	h.subscribersMu.RLock()
+	var targets []*client
+	for c := range h.subscribers {
+		if c.room == room && c.userID == userID {
+			targets = append(targets, c)
+		}
+	}
+	h.subscribersMu.RUnlock()
+
+	// Give writers a moment to flush the notice before disconnecting
+	go func() {
+		time.Sleep(time.Second)
+		for _, c := range targets {
+			if c.conn != nil {
+				c.conn.Close(websocket.StatusPolicyViolation, "removed from room")
+			}
+		}
+	}()
+}
+
+// DisconnectUser closes every connection userID has, in any room, for
+// example when their account is suspended.
+func (h *Hub) DisconnectUser(userID, reason string) {
+	h.subscribersMu.RLock()
+	var targets []*client
+	for c := range h.subscribers {
+		if c.userID == userID {
+			targets = append(targets, c)
+		}
+	}
+	h.subscribersMu.RUnlock()
+
+	for _, c := range targets {
+		if c.conn != nil {
+			c.conn.Close(websocket.StatusPolicyViolation, reason)
+		}
+	}
+}
+
+// isBanned reports whether userID has been removed from room.
+func (h *Hub) isBanned(room, userID string) bool {
+	h.roomsMu.RLock()
+	defer h.roomsMu.RUnlock()
+	_, ok := h.bans[room][userID]
+	return ok
+}
+
+// notifyModeration tells userID's connections in room what happened to them.
+func (h *Hub) notifyModeration(room, userID, status, text string) {
+	frame := models.Message{
+		Type:       TypeModeration,
+		Room:       room,
+		SenderID:   "system",
+		ReceiverID: userID,
+		Status:     status,
+		Content:    text,
+		CreatedAt:  time.Now(),
+	}
+
+	h.subscribersMu.RLock()
+	defer h.subscribersMu.RUnlock()
+	for c := range h.subscribers {
+		if c.room == room && c.userID == userID {
+			h.send(c, frame)
+		}
+	}
+}
+
+// moderate handles a mute, unmute or remove frame from c.
+func (h *Hub) moderate(c *client, msg models.Message) {
+	if !h.isHost(c) {
+		h.sendError(c, msg.ClientID, "only the host can do that")
+		return
+	}
+	target := h.resolveMember(c.room, msg.ReceiverID)
+	if target == "" || target == c.userID {
+		h.sendError(c, msg.ClientID, "choose someone else in the room")
+		return
+	}
+
+	switch msg.Type {
+	case TypeMute:
+		d := defaultMute
+		if minutes, err := strconv.Atoi(msg.Content); err == nil && minutes > 0 && minutes <= 24*60 {
+			d = time.Duration(minutes) * time.Minute
+		}
+		h.MuteUser(c.room, target, d)
+	case TypeUnmute:
+		h.UnmuteUser(c.room, target)
+	case TypeRemove:
+		h.RemoveUser(c.room, target, "")
+	}
+}
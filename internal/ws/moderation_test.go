@@ -0,0 +1,117 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"Remainwith/internal/models"
+
+	"github.com/coder/websocket"
+)
+
+func TestBlockedSenderIsNotDelivered(t *testing.T) {
+	h := newTestHub()
+	h.loadBlocks = func(ctx context.Context, userID string) ([]string, error) {
+		if userID == "2" {
+			return []string{"1"}, nil
+		}
+		return nil, nil
+	}
+
+	sender := newClient(nil, "1", "a", 4)
+	blocker := newClient(nil, "2", "b", 4)
+	other := newClient(nil, "3", "c", 4)
+	for _, c := range []*client{sender, blocker, other} {
+		h.addSubscriber(c)
+		h.loadBlocksFor(c)
+	}
+
+	h.Broadcast(models.Message{Type: TypeMessage, Room: DefaultRoom, SenderID: "1", Content: "hi"})
+	h.Broadcast(models.Message{Type: TypeTyping, Room: DefaultRoom, SenderID: "1"})
+
+	if len(blocker.msgs) != 0 {
+		t.Fatalf("blocker received %d frames from a blocked user", len(blocker.msgs))
+	}
+	if len(other.msgs) != 2 {
+		t.Fatalf("other received %d frames, want 2", len(other.msgs))
+	}
+}
+
+func TestMutedUserCannotSend(t *testing.T) {
+	h := newTestHub()
+	persisted := 0
+	h.persist = func(ctx context.Context, msg *models.Message) (bool, error) {
+		persisted++
+		return false, nil
+	}
+
+	c := newClient(nil, "2", "b", 4)
+	h.addSubscriber(c)
+	h.MuteUser(DefaultRoom, "2", time.Minute)
+	<-c.msgs // the muted notice
+
+	h.handleMessage(c, models.Message{Type: TypeMessage, ClientID: "x", Content: "hello"})
+
+	if persisted != 0 {
+		t.Fatal("message from a muted user was persisted")
+	}
+	var frame models.Message
+	if err := json.Unmarshal((<-c.msgs).data, &frame); err != nil {
+		t.Fatal(err)
+	}
+	if frame.Type != TypeError {
+		t.Fatalf("got %q frame, want %q", frame.Type, TypeError)
+	}
+
+	h.UnmuteUser(DefaultRoom, "2")
+	<-c.msgs
+	h.handleMessage(c, models.Message{Type: TypeMessage, ClientID: "y", Content: "hello"})
+	if persisted != 1 {
+		t.Fatalf("persisted %d messages after unmute, want 1", persisted)
+	}
+}
+
+func TestBlockMadeWhileConnectingApplies(t *testing.T) {
+	h := newTestHub()
+	var calls atomic.Int64
+	h.loadBlocks = func(ctx context.Context, userID string) ([]string, error) {
+		if calls.Add(1) == 1 {
+			// The block lands after this read but before the
+			// connection is registered, so the refresh can't see it.
+			h.RefreshBlocks(userID)
+			return nil, nil
+		}
+		return []string{"1"}, nil
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.serve(w, r, "2", "b")
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.CloseNow()
+	// The roster is sent once the connection is fully set up
+	if _, _, err := conn.Read(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	h.subscribersMu.RLock()
+	defer h.subscribersMu.RUnlock()
+	for c := range h.subscribers {
+		if !c.blocks("1") {
+			t.Error("block made while connecting was missed")
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package ws
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"Remainwith/internal/models"
+)
+
+// Presence frame types and states.
+const (
+	TypeHeartbeat = "heartbeat" // client → server every heartbeatInterval; Status may be "idle" or "active"
+	TypeTyping    = "typing"    // client → server → room, debounced
+	TypePresence  = "presence"  // server → room when a member joins, leaves, idles or returns
+	TypeRoster    = "roster"    // server → client on joining, listing who is already in the room; ReceiverID is the ID the room knows the client by
+
+	PresenceJoin   = "join"
+	PresenceLeave  = "leave"
+	PresenceIdle   = "idle"
+	PresenceActive = "active"
+)
+
+// DefaultRoom is the room a connection joins when it does not ask for one.
+const DefaultRoom = "lobby"
+
+// presenceState is the per-connection presence bookkeeping, guarded by the
+// hub's subscribersMu.
+type presenceState struct {
+	lastSeen   time.Time
+	idle       bool
+	lastTyping time.Time
+}
+
+// join announces c to its room and tells c who is already there. The
+// roster goes out as one frame, whatever the size of the room, so a new
+// connection's queue can't overflow before its writer is running.
+func (h *Hub) join(c *client) {
+	h.send(c, models.Message{
+		Type:       TypeRoster,
+		Room:       c.room,
+		SenderID:   "system",
+		ReceiverID: h.publicID(c.room, c.userID),
+		Members:    h.roster(c),
+		CreatedAt:  time.Now(),
+	})
+
+	// Others already see the user if another tab is open; the only news
+	// is that an all-idle user is back.
+	h.subscribersMu.RLock()
+	tabs, idle := h.otherTabs(c)
+	h.subscribersMu.RUnlock()
+	switch {
+	case tabs == 0:
+		h.Broadcast(presenceFrame(c, PresenceJoin))
+	case idle && !c.presence.idle:
+		h.Broadcast(presenceFrame(c, PresenceActive))
+	}
+}
+
+// otherTabs counts c's user's other connections to c's room and reports
+// whether all of them are idle. The caller holds subscribersMu.
+func (h *Hub) otherTabs(c *client) (n int, idle bool) {
+	idle = true
+	for other := range h.subscribers {
+		if other != c && other.userID == c.userID && other.room == c.room {
+			n++
+			idle = idle && other.presence.idle
+		}
+	}
+	return n, idle
+}
+
+// roster lists the other users in c's room, once each however many tabs
+// they have open. A user counts as idle only if all their tabs are.
+func (h *Hub) roster(c *client) []models.Member {
+	h.subscribersMu.RLock()
+	index := make(map[string]int)
+	members := []models.Member{}
+	for other := range h.subscribers {
+		if other.room != c.room || other.userID == c.userID || c.blocks(other.userID) {
+			continue
+		}
+		if i, ok := index[other.userID]; ok {
+			members[i].Idle = members[i].Idle && other.presence.idle
+			continue
+		}
+		index[other.userID] = len(members)
+		members = append(members, models.Member{
+			ID:   other.userID,
+			Name: other.name,
+			Idle: other.presence.idle,
+		})
+	}
+	h.subscribersMu.RUnlock()
+
+	for i := range members {
+		members[i].ID = h.publicID(c.room, members[i].ID)
+	}
+	return members
+}
+
+// leave announces that c has gone, unless the same user is still in the
+// room from another tab, in which case it only reports the user idle if c
+// was their last active tab.
+func (h *Hub) leave(c *client) {
+	h.subscribersMu.RLock()
+	tabs, idle := h.otherTabs(c)
+	h.subscribersMu.RUnlock()
+	switch {
+	case tabs == 0:
+		h.Broadcast(presenceFrame(c, PresenceLeave))
+	case idle && !c.presence.idle:
+		h.Broadcast(presenceFrame(c, PresenceIdle))
+	}
+}
+
+// heartbeat records that c is alive. A client may also report itself idle
+// (for example when its tab is hidden); otherwise a heartbeat means active.
+// The room hears about it only if the user as a whole changes state: idle
+// once all their tabs are, active again once any one is.
+func (h *Hub) heartbeat(c *client, status string) {
+	idle := status == PresenceIdle
+
+	h.subscribersMu.Lock()
+	c.presence.lastSeen = time.Now()
+	_, othersIdle := h.otherTabs(c)
+	changed := othersIdle && c.presence.idle != idle
+	c.presence.idle = idle
+	h.subscribersMu.Unlock()
+
+	if changed {
+		if idle {
+			h.Broadcast(presenceFrame(c, PresenceIdle))
+		} else {
+			h.Broadcast(presenceFrame(c, PresenceActive))
+		}
+	}
+}
+
+// typing forwards a typing event from c to the rest of its room, at most
+// once per typingDebounce.
+func (h *Hub) typing(c *client) {
+	now := time.Now()
+
+	if h.isMuted(c.room, c.userID) {
+		return
+	}
+
+	h.subscribersMu.Lock()
+	c.presence.lastSeen = now
+	if now.Sub(c.presence.lastTyping) < h.typingDebounce {
+		h.subscribersMu.Unlock()
+		return
+	}
+	c.presence.lastTyping = now
+	h.subscribersMu.Unlock()
+
+	h.Broadcast(models.Message{
+		Type:       TypeTyping,
+		Room:       c.room,
+		SenderID:   c.userID,
+		SenderName: c.name,
+		CreatedAt:  now,
+	})
+}
+
+// sweepIdle marks connections idle once they have missed heartbeats for
+// longer than idleAfter, and announces each user whose tabs are now all
+// idle.
+func (h *Hub) sweepIdle(now time.Time) {
+	var stale, idled []*client
+
+	h.subscribersMu.Lock()
+	for c := range h.subscribers {
+		if !c.presence.idle && now.Sub(c.presence.lastSeen) > h.idleAfter {
+			c.presence.idle = true
+			stale = append(stale, c)
+		}
+	}
+	announced := make(map[[2]string]bool)
+	for _, c := range stale {
+		key := [2]string{c.room, c.userID}
+		if _, idle := h.otherTabs(c); idle && !announced[key] {
+			announced[key] = true
+			idled = append(idled, c)
+		}
+	}
+	h.subscribersMu.Unlock()
+
+	for _, c := range idled {
+		h.Broadcast(presenceFrame(c, PresenceIdle))
+	}
+}
+
+// watchPresence periodically sweeps for idle connections.
+func (h *Hub) watchPresence() {
+	ticker := time.NewTicker(h.idleAfter / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			h.sweepIdle(now)
+		case <-h.stop:
+			return
+		}
+	}
+}
+
+// ConnectedUsers returns the number of distinct users with at least one
+// open connection. If room is not empty only that room is counted.
+func (h *Hub) ConnectedUsers(room string) int {
+	h.subscribersMu.RLock()
+	defer h.subscribersMu.RUnlock()
+
+	users := make(map[string]struct{})
+	for c := range h.subscribers {
+		if room == "" || c.room == room {
+			users[c.userID] = struct{}{}
+		}
+	}
+	return len(users)
+}
+
+// PresenceCountHandler serves GET /api/presence/count with the number of
+// connected users, optionally narrowed by the room query parameter.
+func (h *Hub) PresenceCountHandler(w http.ResponseWriter, r *http.Request) {
+	count := h.ConnectedUsers(r.URL.Query().Get("room"))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]int{"count": count})
+}
+
+func presenceFrame(c *client, status string) models.Message {
+	return models.Message{
+		Type:       TypePresence,
+		Room:       c.room,
+		SenderID:   c.userID,
+		SenderName: c.name,
+		Status:     status,
+		CreatedAt:  time.Now(),
+	}
+}
@@ -0,0 +1,212 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"Remainwith/internal/models"
+
+	"github.com/coder/websocket"
+)
+
+func TestTypingIsDebouncedAndScopedToRoom(t *testing.T) {
+	h := newTestHub()
+
+	typist := newClient(nil, "1", "a", 8)
+	roommate := newClient(nil, "2", "b", 8)
+	elsewhere := newClient(nil, "3", "c", 8)
+	elsewhere.room = "other"
+	for _, c := range []*client{typist, roommate, elsewhere} {
+		h.addSubscriber(c)
+	}
+
+	h.typing(typist)
+	h.typing(typist)
+
+	if len(roommate.msgs) != 1 {
+		t.Fatalf("roommate got %d typing frames, want 1", len(roommate.msgs))
+	}
+	if len(typist.msgs) != 0 || len(elsewhere.msgs) != 0 {
+		t.Fatalf("typist/elsewhere got %d/%d frames, want 0/0", len(typist.msgs), len(elsewhere.msgs))
+	}
+
+	var frame models.Message
+	if err := json.Unmarshal((<-roommate.msgs).data, &frame); err != nil {
+		t.Fatal(err)
+	}
+	if frame.Type != TypeTyping || frame.SenderID != "1" {
+		t.Fatalf("got %+v, want typing from 1", frame)
+	}
+}
+
+func TestSweepIdleAndConnectedUsers(t *testing.T) {
+	h := newTestHub()
+
+	stale := newClient(nil, "1", "a", 8)
+	stale.presence.lastSeen = time.Now().Add(-time.Hour)
+	fresh := newClient(nil, "2", "b", 8)
+	secondTab := newClient(nil, "2", "b", 8)
+	for _, c := range []*client{stale, fresh, secondTab} {
+		h.addSubscriber(c)
+	}
+
+	if got := h.ConnectedUsers(""); got != 2 {
+		t.Fatalf("ConnectedUsers = %d, want 2", got)
+	}
+
+	h.sweepIdle(time.Now())
+	if !stale.presence.idle || fresh.presence.idle {
+		t.Fatalf("idle = %v/%v, want true/false", stale.presence.idle, fresh.presence.idle)
+	}
+	if len(fresh.msgs) != 1 {
+		t.Fatalf("fresh got %d presence frames, want 1", len(fresh.msgs))
+	}
+}
+
+func TestPresenceIsPerUserAcrossTabs(t *testing.T) {
+	h := newTestHub()
+	watcher := newClient(nil, "2", "b", 8)
+	h.addSubscriber(watcher)
+
+	statuses := func() string {
+		var got []string
+		for _, f := range frames(t, watcher) {
+			got = append(got, f.Status)
+		}
+		return strings.Join(got, ",")
+	}
+	expect := func(step, want string) {
+		t.Helper()
+		if got := statuses(); got != want {
+			t.Errorf("%s: watcher saw %q, want %q", step, got, want)
+		}
+	}
+
+	tab1 := newClient(nil, "1", "a", 8)
+	h.addSubscriber(tab1)
+	h.join(tab1)
+	tab2 := newClient(nil, "1", "a", 8)
+	h.addSubscriber(tab2)
+	h.join(tab2)
+	expect("two tabs join", "join")
+
+	h.heartbeat(tab1, PresenceIdle)
+	expect("one tab idles", "")
+	h.heartbeat(tab2, PresenceIdle)
+	expect("both tabs idle", "idle")
+	h.heartbeat(tab1, PresenceActive)
+	expect("one tab returns", "active")
+
+	h.heartbeat(tab2, PresenceActive)
+	tab2.presence.lastSeen = time.Now().Add(-time.Hour)
+	h.sweepIdle(time.Now())
+	expect("other tab goes quiet", "")
+
+	h.deleteSubscriber(tab1)
+	h.leave(tab1)
+	expect("active tab closes", "idle")
+	h.deleteSubscriber(tab2)
+	h.leave(tab2)
+	expect("last tab closes", "leave")
+}
+
+func TestRosterIsOneFramePerJoin(t *testing.T) {
+	h := newTestHub()
+
+	tab1 := newClient(nil, "1", "a", 4)
+	tab2 := newClient(nil, "1", "a", 4)
+	tab2.presence.idle = true
+	for _, c := range []*client{tab1, tab2} {
+		h.addSubscriber(c)
+	}
+	for i := 2; i <= 10; i++ {
+		h.addSubscriber(newClient(nil, strconv.Itoa(i), "", 4))
+	}
+
+	joiner := newClient(nil, "99", "new", 4)
+	var kicked atomic.Bool
+	joiner.closeSlow = func() { kicked.Store(true) }
+	h.addSubscriber(joiner)
+	h.join(joiner)
+
+	if kicked.Load() || len(joiner.msgs) != 1 {
+		t.Fatalf("joiner kicked=%v with %d frames, want one roster frame", kicked.Load(), len(joiner.msgs))
+	}
+	var frame models.Message
+	if err := json.Unmarshal((<-joiner.msgs).data, &frame); err != nil {
+		t.Fatal(err)
+	}
+	if frame.Type != TypeRoster || len(frame.Members) != 10 {
+		t.Fatalf("got %s with %d members, want roster with 10", frame.Type, len(frame.Members))
+	}
+	for _, m := range frame.Members {
+		if m.ID == "1" && m.Idle {
+			t.Error("user with an active tab listed as idle")
+		}
+	}
+}
+
+func TestJoinCrowdedRoom(t *testing.T) {
+	h := newTestHub()
+	var ids atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.serve(w, r, strconv.FormatInt(ids.Add(1), 10), "test")
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	// More members than a client's queue holds
+	n := h.subscriberMessageBuffer + 4
+	kicked := make(chan error, n)
+	var last *websocket.Conn
+	for i := 0; i < n; i++ {
+		conn, _, err := websocket.Dial(ctx, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.CloseNow()
+		for h.ConnectedUsers(DefaultRoom) != i+1 {
+			time.Sleep(time.Millisecond)
+		}
+		if i < n-1 {
+			go func() {
+				for {
+					if _, _, err := conn.Read(ctx); err != nil {
+						if websocket.CloseStatus(err) == websocket.StatusPolicyViolation {
+							kicked <- err
+						}
+						return
+					}
+				}
+			}()
+		}
+		last = conn
+	}
+
+	_, data, err := last.Read(ctx)
+	if err != nil {
+		t.Fatalf("last to join: %v", err)
+	}
+	var frame models.Message
+	if err := json.Unmarshal(data, &frame); err != nil {
+		t.Fatal(err)
+	}
+	if frame.Type != TypeRoster || len(frame.Members) != n-1 {
+		t.Fatalf("got %s with %d members, want roster with %d", frame.Type, len(frame.Members), n-1)
+	}
+	select {
+	case err := <-kicked:
+		t.Fatalf("a member was kicked: %v", err)
+	default:
+	}
+}
@@ -0,0 +1,247 @@
+package ws
+
+import (
+	"time"
+
+	"Remainwith/internal/models"
+
+	"github.com/coder/websocket"
+)
+
+// Room frame types.
+const (
+	// TypeReaction is a lightweight, content-free gesture (client → server → room).
+	// Content carries one of the allowed reactions.
+	TypeReaction = "reaction"
+
+	// TypeRoomClosed tells a room's members it has been closed (server → room).
+	TypeRoomClosed = "closed"
+)
+
+// Reactions are the gestures accepted in reaction frames.
+var Reactions = map[string]struct{}{
+	"🌿": {}, "🤍": {}, "🔥": {}, "🙏": {}, "🌙": {}, "☕": {},
+}
+
+// RoomOptions configures behaviour for a single room. Rooms without options
+// behave like open chat rooms.
+type RoomOptions struct {
+	// Capacity is the maximum number of distinct users; zero means unlimited.
+	Capacity int
+
+	// ReactionsOnly rejects chat messages and only allows reaction frames.
+	ReactionsOnly bool
+
+	// HostID is the user who owns the room, if any.
+	HostID string
+
+	// Closed refuses new connections, for rooms that have not opened yet
+	// or have been shut down.
+	Closed bool
+
+	// Filters replaces the hub's default safety filters for this room.
+	// Nil uses the defaults; an empty chain disables filtering.
+	Filters FilterChain
+
+	// AllowAnonymous shows members who turned on anonymous mode under an
+	// alias instead of their display name.
+	AllowAnonymous bool
+
+	// Admit, if set, decides whether a user may take a seat, and should
+	// use up whatever granted it, such as a matchmaking reservation. It
+	// is not asked about users already connected from another tab.
+	Admit func(userID string) bool
+}
+
+// admitError is why a connection was turned away from its room.
+type admitError struct {
+	code   websocket.StatusCode
+	reason string
+}
+
+func (e *admitError) Error() string { return e.reason }
+
+// Reasons admit refuses a connection.
+var (
+	errShuttingDown = &admitError{websocket.StatusGoingAway, "server is shutting down"}
+	errUnknownRoom  = &admitError{websocket.StatusPolicyViolation, "no such room"}
+	errRoomClosed   = &admitError{websocket.StatusNormalClosure, "room is closed"}
+	errNoSeat       = &admitError{websocket.StatusPolicyViolation, "no seat reserved in this room"}
+	errRoomFull     = &admitError{websocket.StatusTryAgainLater, "room is full"}
+)
+
+// ConfigureRoom sets the options for room, replacing any previous ones.
+func (h *Hub) ConfigureRoom(room string, opts RoomOptions) {
+	h.roomsMu.Lock()
+	h.rooms[room] = opts
+	h.roomsMu.Unlock()
+}
+
+// RemoveRoom forgets the options for room.
+func (h *Hub) RemoveRoom(room string) {
+	h.roomsMu.Lock()
+	delete(h.rooms, room)
+	delete(h.pseudonyms, room)
+	h.roomsMu.Unlock()
+}
+
+// CloseRoom marks room closed, tells its members why and disconnects them.
+func (h *Hub) CloseRoom(room, reason string) {
+	h.roomsMu.Lock()
+	opts := h.rooms[room]
+	opts.Closed = true
+	h.rooms[room] = opts
+	h.roomsMu.Unlock()
+
+	h.Broadcast(models.Message{
+		Type:      TypeRoomClosed,
+		Room:      room,
+		SenderID:  "system",
+		Content:   reason,
+		CreatedAt: time.Now(),
+	})
+
+	h.subscribersMu.RLock()
+	var members []*client
+	for c := range h.subscribers {
+		if c.room == room {
+			members = append(members, c)
+		}
+	}
+	h.subscribersMu.RUnlock()
+
+	// Give writers a moment to flush the closed frame before disconnecting
+	go func() {
+		time.Sleep(time.Second)
+		for _, c := range members {
+			if c.conn != nil {
+				c.conn.Close(websocket.StatusNormalClosure, reason)
+			}
+		}
+	}()
+}
+
+// OnRoomMessage registers fn to be called after a chat message has been
+// accepted in any room. It is called on the sender's reader goroutine.
+func (h *Hub) OnRoomMessage(fn func(room string, msg models.Message)) {
+	h.roomsMu.Lock()
+	h.roomHooks = append(h.roomHooks, fn)
+	h.roomsMu.Unlock()
+}
+
+// notifyRoomMessage calls the registered room message hooks.
+func (h *Hub) notifyRoomMessage(msg models.Message) {
+	h.roomsMu.RLock()
+	hooks := h.roomHooks
+	h.roomsMu.RUnlock()
+	for _, fn := range hooks {
+		fn(msg.Room, msg)
+	}
+}
+
+// roomOptions returns the options for room.
+func (h *Hub) roomOptions(room string) RoomOptions {
+	h.roomsMu.RLock()
+	defer h.roomsMu.RUnlock()
+	return h.rooms[room]
+}
+
+// RoomMembers returns the distinct user IDs connected to room.
+func (h *Hub) RoomMembers(room string) []string {
+	h.subscribersMu.RLock()
+	defer h.subscribersMu.RUnlock()
+	return h.roomMembersLocked(room)
+}
+
+// roomMembersLocked is RoomMembers for callers holding subscribersMu.
+func (h *Hub) roomMembersLocked(room string) []string {
+	seen := make(map[string]struct{})
+	var members []string
+	for c := range h.subscribers {
+		if c.room != room {
+			continue
+		}
+		if _, ok := seen[c.userID]; ok {
+			continue
+		}
+		seen[c.userID] = struct{}{}
+		members = append(members, c.userID)
+	}
+	return members
+}
+
+// admit registers c unless its room is unknown, closed, at capacity or
+// refuses c's user through RoomOptions.Admit. Only the default room exists
+// without being configured. A user already in the room (another tab)
+// always fits. Checking capacity and adding happen under one lock so two
+// users cannot race into the last seat.
+func (h *Hub) admit(c *client) error {
+	h.roomsMu.RLock()
+	opts, known := h.rooms[c.room]
+	h.roomsMu.RUnlock()
+	if !known && c.room != DefaultRoom {
+		return errUnknownRoom
+	}
+	if opts.Closed {
+		return errRoomClosed
+	}
+
+	// Admit may take its own locks, so it is asked before subscribersMu
+	// is held.
+	if opts.Admit != nil && !h.isMember(c.room, c.userID) && !opts.Admit(c.userID) {
+		return errNoSeat
+	}
+
+	h.subscribersMu.Lock()
+	if h.closing {
+		h.subscribersMu.Unlock()
+		return errShuttingDown
+	}
+	if opts.Capacity > 0 {
+		members := h.roomMembersLocked(c.room)
+		fits := len(members) < opts.Capacity
+		for _, m := range members {
+			if m == c.userID {
+				fits = true
+			}
+		}
+		if !fits {
+			h.subscribersMu.Unlock()
+			return errRoomFull
+		}
+	}
+	h.subscribers[c] = struct{}{}
+	n := len(h.subscribers)
+	h.subscribersMu.Unlock()
+
+	h.logf("Client connected. Total subscribers: %d", n)
+	return nil
+}
+
+// isMember reports whether userID is connected to room.
+func (h *Hub) isMember(room, userID string) bool {
+	h.subscribersMu.RLock()
+	defer h.subscribersMu.RUnlock()
+	for c := range h.subscribers {
+		if c.room == room && c.userID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// react forwards a reaction from c to its room.
+func (h *Hub) react(c *client, reaction string) {
+	if _, ok := Reactions[reaction]; !ok {
+		h.sendError(c, "", "unknown reaction")
+		return
+	}
+	h.Broadcast(models.Message{
+		Type:       TypeReaction,
+		Room:       c.room,
+		SenderID:   c.userID,
+		SenderName: c.name,
+		Content:    reaction,
+		CreatedAt:  time.Now(),
+	})
+}
@@ -4,60 +4,177 @@ import (
 	"Remainwith/config"
 	"Remainwith/db"
 	"Remainwith/internal/about"
+	"Remainwith/internal/admin"
+	"Remainwith/internal/api"
+	"Remainwith/internal/avatar"
+	"Remainwith/internal/certs"
 	"Remainwith/internal/chat"
 	"Remainwith/internal/handler"
+	"Remainwith/internal/mail"
+	"Remainwith/internal/match"
 	"Remainwith/internal/message"
+	"Remainwith/internal/moderation"
+	"Remainwith/internal/openapi"
+	"Remainwith/internal/presence"
+	"Remainwith/internal/safety"
 	"Remainwith/internal/ws"
 	"context"
+	"html/template"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
+// shutdownTimeout bounds how long in-flight requests and websocket close
+// handshakes get once a shutdown signal arrives.
+const shutdownTimeout = 15 * time.Second
+
+// certReloadInterval is how often TLS_MODE=file checks for a renewed
+// certificate.
+const certReloadInterval = time.Minute
+
 func main() {
-	config.Init()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Invalid configuration:\n", err)
+	}
+	log.Printf("Starting in %s mode", cfg.Env)
 
-	handler.InitJWT()
+	handler.Configure(cfg)
 
-	if err := config.InitDB(); err != nil {
+	if err := config.InitDB(cfg.Database); err != nil {
 		log.Fatal("Database connection failed:", err)
 	}
 
-	// Seed interests if they don't exist
-	if err := db.SeedInterests(context.Background()); err != nil {
-		log.Println("Warning: Failed to seed interests:", err)
+	// Schema setup; internal/testdb runs the same migration for tests
+	if err := db.Migrate(context.Background()); err != nil {
+		log.Fatal("Database migration failed:", err)
+	}
+	if err := handler.LoadSuspensions(context.Background()); err != nil {
+		log.Println("Warning: Failed to load suspensions:", err)
 	}
 
+	// Accounts, journals and interests live in Postgres
+	store := db.NewPostgres(config.DB)
+
+	// Account emails go through SMTP when configured, else to the log
+	accounts := handler.NewAccounts(store, mail.FromConfig(cfg.SMTP))
+	interests := handler.NewInterests(store)
+	profiles := handler.NewProfiles(store)
+	journals := message.NewJournals(store)
+	v1 := api.NewHandlers(store, store, store, accounts)
+
 	// Initialize websocket hub
 	hub := ws.NewHub()
+	hub.SetAliasSecret(cfg.JWTKey)
+	if err := hub.EndStaleVisits(context.Background()); err != nil {
+		log.Println("Warning: Failed to end stale room visits:", err)
+	}
+
+	// Safety filters for chat, with wordlists and crisis resources from
+	// SAFETY_DIR, or config/ built in or from ASSETS_DIR
+	safetyDir, err := safetyFiles(cfg.SafetyDir, cfg.AssetsDir)
+	if err != nil {
+		log.Fatal("Failed to open safety config:", err)
+	}
+	var wordlists ws.Wordlists
+	if wordlists.Profanity, err = ws.LoadWordlist(safetyDir, "wordlists/profanity.txt"); err != nil {
+		log.Fatal("Failed to load profanity wordlist:", err)
+	}
+	if wordlists.Slurs, err = ws.LoadWordlist(safetyDir, "wordlists/slurs.txt"); err != nil {
+		log.Fatal("Failed to load slur wordlist:", err)
+	}
+	if len(wordlists.Slurs) == 0 {
+		// The built-in list is a placeholder, see config/wordlists/slurs.txt
+		if cfg.Env == config.Production {
+			log.Fatal("The slur wordlist is empty; set SAFETY_DIR to a directory with wordlists/slurs.txt")
+		}
+		log.Println("Warning: The slur wordlist is empty, so slurs are not refused in chat; set SAFETY_DIR to supply one")
+	}
+	crisisResources, err := safety.LoadRegistry(safetyDir, "crisis_resources.json")
+	if err != nil {
+		log.Fatal("Failed to load crisis resources:", err)
+	}
+	hub.SetFilters(ws.DefaultFilters(wordlists, crisisResources.For))
+	support := safety.NewHandlers(crisisResources)
+
+	// Presence rooms are matched here and backed by hub rooms
+	presenceRooms := presence.NewManager(hub)
+
+	// Campfires are persisted and mirrored into hub rooms
+	campfires := chat.NewCampfires(hub)
+	if err := campfires.Restore(context.Background()); err != nil {
+		log.Println("Warning: Failed to restore campfires:", err)
+	}
+
+	// Blocks and reports, applied to live hub connections
+	mod := moderation.NewHandlers(hub)
+
+	// Avatar uploads, stored by content hash under AVATAR_DIR
+	avatarStore, err := avatar.NewFileStore(cfg.AvatarDir)
+	if err != nil {
+		log.Fatal("Failed to open avatar store:", err)
+	}
+	avatars := avatar.NewHandlers(avatarStore)
+
+	// Staff console for reports, suspensions, campfires and the catalog
+	console := admin.NewConsole(hub, campfires)
+	moderator := func(h http.HandlerFunc) http.Handler {
+		return handler.JWTMiddleware(handler.RequireRole(db.RoleModerator)(h))
+	}
+	adminOnly := func(h http.HandlerFunc) http.Handler {
+		return handler.JWTMiddleware(handler.RequireRole(db.RoleAdmin)(h))
+	}
+
+	// Templates and static files, built in or from ASSETS_DIR, which
+	// reloads static files while developing
+	templates, staticFiles, err := webFiles(cfg.AssetsDir, cfg.Env == config.Development)
+	if err != nil {
+		log.Fatal("Failed to load static files:", err)
+	}
+
+	// Page templates, parsed up front unless reloading for development
+	renderer, err := handler.NewRenderer(templates, cfg.ReloadTemplates, template.FuncMap{"asset": staticFiles.URL})
+	if err != nil {
+		log.Fatal("Failed to parse templates:", err)
+	}
+	handler.SetRenderer(renderer)
 
 	router := http.NewServeMux()
 
-	router.Handle("/assets/", http.StripPrefix("/assets/", http.FileServer(http.Dir("./assets"))))
+	// Probes for the orchestrator; see handler.Health
+	health := handler.NewHealth(config.DB)
+	router.HandleFunc("GET /healthz", health.LivenessHandler)
+	router.HandleFunc("GET /readyz", health.ReadinessHandler)
 
-	router.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("frontend/static/"))))
+	// Static files, fingerprinted and precompressed; see static.Files
+	router.Handle("GET /assets/", staticFiles)
+	router.Handle("GET /static/", staticFiles)
 
 	router.HandleFunc("/", handler.IndexHandler)
 
-	router.Handle("GET /signup", handler.CSRFMiddleware()(http.HandlerFunc(handler.SignupPageHandler)))
+	router.HandleFunc("GET /signup", handler.SignupPageHandler)
 
-	router.HandleFunc("POST /signup", handler.SignupHandler)
+	router.HandleFunc("POST /signup", accounts.SignupHandler)
 
-	router.Handle("GET /login", handler.CSRFMiddleware()(http.HandlerFunc(handler.LoginPageHandler)))
+	router.HandleFunc("GET /login", handler.LoginPageHandler)
 
-	// router.Handle("POST /login", handler.CSRFMiddleware()(http.HandlerFunc(handler.LoginHandler)))
-	router.HandleFunc("POST /login", handler.LoginHandler)
+	router.HandleFunc("POST /login", accounts.LoginHandler)
 
 	router.HandleFunc("GET /dashboard", func(w http.ResponseWriter, r *http.Request) {
 		handler.JWTMiddleware(http.HandlerFunc(handler.DashboardHandler)).ServeHTTP(w, r)
 	})
 
-	router.Handle("GET /journal", handler.JWTMiddleware(handler.CSRFMiddleware()(http.HandlerFunc(message.JournalPageHandler))))
+	router.Handle("GET /journal", handler.JWTMiddleware(http.HandlerFunc(journals.JournalPageHandler)))
 
-	router.Handle("POST /journal", handler.JWTMiddleware(handler.CSRFMiddleware()(http.HandlerFunc(message.JournalHandler))))
+	router.Handle("POST /journal", handler.JWTMiddleware(http.HandlerFunc(journals.JournalHandler)))
 
-	router.Handle("POST /journal/update/{id}", handler.JWTMiddleware(handler.CSRFMiddleware()(http.HandlerFunc(message.UpdateJournalHandler))))
+	router.Handle("POST /journal/update/{id}", handler.JWTMiddleware(http.HandlerFunc(journals.UpdateJournalHandler)))
 
-	router.Handle("POST /journal/delete/{id}", handler.JWTMiddleware(handler.CSRFMiddleware()(http.HandlerFunc(message.DeleteJournalHandler))))
+	router.Handle("POST /journal/delete/{id}", handler.JWTMiddleware(http.HandlerFunc(journals.DeleteJournalHandler)))
 
 	router.HandleFunc("POST /logout", handler.LogoutHandler)
 
@@ -67,29 +184,204 @@ func main() {
 
 	router.Handle("GET /campfire/chat", handler.JWTMiddleware(http.HandlerFunc(chat.ChatPageHandler)))
 
+	router.Handle("GET /campfire/new", handler.JWTMiddleware(http.HandlerFunc(campfires.NewPageHandler)))
+
+	router.Handle("POST /campfire/new", handler.JWTMiddleware(http.HandlerFunc(campfires.CreateFormHandler)))
+
+	router.Handle("GET /campfire/{id}", handler.JWTMiddleware(http.HandlerFunc(campfires.RoomHandler)))
+
+	// Campfire API routes
+	router.Handle("GET /api/campfires", handler.JWTMiddleware(http.HandlerFunc(campfires.ListHandler)))
+	router.Handle("POST /api/campfires", handler.JWTMiddleware(http.HandlerFunc(campfires.CreateHandler)))
+
+	// Presence room routes
+	router.Handle("GET /presence/join", handler.JWTMiddleware(http.HandlerFunc(presenceRooms.JoinHandler)))
+	router.Handle("GET /presence/room/{id}", handler.JWTMiddleware(http.HandlerFunc(presenceRooms.RoomPageHandler)))
+	router.Handle("GET /presence/solo", handler.JWTMiddleware(http.HandlerFunc(presence.SoloPageHandler)))
+	router.Handle("POST /presence/solo/complete", handler.JWTMiddleware(http.HandlerFunc(presence.CompleteSoloHandler)))
+
 	// Interests API routes
-	router.HandleFunc("GET /api/interests", handler.GetInterestsHandler)
+	router.HandleFunc("GET /api/interests", interests.GetInterestsHandler)
 	router.HandleFunc("POST /api/interests", func(w http.ResponseWriter, r *http.Request) {
-		handler.JWTMiddleware(http.HandlerFunc(handler.SaveInterestsHandler)).ServeHTTP(w, r)
+		handler.JWTMiddleware(http.HandlerFunc(interests.SaveInterestsHandler)).ServeHTTP(w, r)
 	})
 	router.HandleFunc("GET /api/onboarding/check", func(w http.ResponseWriter, r *http.Request) {
-		handler.JWTMiddleware(http.HandlerFunc(handler.CheckOnboardingHandler)).ServeHTTP(w, r)
+		handler.JWTMiddleware(http.HandlerFunc(interests.CheckOnboardingHandler)).ServeHTTP(w, r)
 	})
 
+	// Crisis support and safety plan routes
+	router.Handle("GET /support", handler.JWTMiddleware(http.HandlerFunc(support.SupportPageHandler)))
+	router.Handle("GET /safety-plan", handler.JWTMiddleware(http.HandlerFunc(support.SafetyPlanPageHandler)))
+	router.Handle("POST /safety-plan", handler.JWTMiddleware(http.HandlerFunc(support.SaveSafetyPlanHandler)))
+
+	// Moderation API routes
+	router.Handle("GET /api/blocks", handler.JWTMiddleware(http.HandlerFunc(mod.ListBlocksHandler)))
+	router.Handle("POST /api/blocks", handler.JWTMiddleware(http.HandlerFunc(mod.BlockHandler)))
+	router.Handle("DELETE /api/blocks/{id}", handler.JWTMiddleware(http.HandlerFunc(mod.UnblockHandler)))
+	router.Handle("POST /api/reports", handler.JWTMiddleware(http.HandlerFunc(mod.ReportHandler)))
+
+	// Admin console routes
+	router.Handle("GET /admin", moderator(console.IndexHandler))
+	router.Handle("GET /admin/reports", moderator(console.ReportsHandler))
+	router.Handle("POST /admin/reports/{id}", moderator(console.ResolveReportHandler))
+	router.Handle("GET /admin/users", moderator(console.UsersHandler))
+	router.Handle("POST /admin/users/{id}/suspend", moderator(console.SuspendUserHandler))
+	router.Handle("POST /admin/users/{id}/unsuspend", moderator(console.UnsuspendUserHandler))
+	router.Handle("POST /admin/users/{id}/role", adminOnly(console.SetRoleHandler))
+	router.Handle("GET /admin/campfires", moderator(console.CampfiresHandler))
+	router.Handle("POST /admin/campfires/{id}/close", moderator(console.CloseCampfireHandler))
+	router.Handle("GET /admin/interests", adminOnly(console.InterestsHandler))
+	router.Handle("POST /admin/categories", adminOnly(console.AddCategoryHandler))
+	router.Handle("POST /admin/categories/{id}", adminOnly(console.UpdateCategoryHandler))
+	router.Handle("POST /admin/categories/{id}/move", adminOnly(console.MoveCategoryHandler))
+	router.Handle("POST /admin/interests", adminOnly(console.AddInterestHandler))
+	router.Handle("POST /admin/interests/{id}", adminOnly(console.UpdateInterestHandler))
+	router.Handle("POST /admin/interests/{id}/move", adminOnly(console.MoveInterestHandler))
+	router.Handle("POST /admin/interests/{id}/active", adminOnly(console.SetInterestActiveHandler))
+	router.Handle("GET /admin/audit", adminOnly(console.AuditHandler))
+
+	// Interest catalog API, for admins. Send the CSRF token as X-CSRF-Token.
+	router.Handle("GET /api/admin/interests", adminOnly(console.CatalogAPIHandler))
+	router.Handle("POST /api/admin/interests", adminOnly(console.CreateInterestAPIHandler))
+	router.Handle("PATCH /api/admin/interests/{id}", adminOnly(console.UpdateInterestAPIHandler))
+	router.Handle("POST /api/admin/categories", adminOnly(console.CreateCategoryAPIHandler))
+	router.Handle("PATCH /api/admin/categories/{id}", adminOnly(console.UpdateCategoryAPIHandler))
+	router.Handle("PUT /api/admin/categories/order", adminOnly(console.ReorderCategoriesAPIHandler))
+	router.Handle("PUT /api/admin/categories/{id}/order", adminOnly(console.ReorderInterestsAPIHandler))
+
+	router.Handle("GET /api/matches", handler.JWTMiddleware(http.HandlerFunc(match.SuggestionsHandler)))
+
+	router.Handle("GET /api/presence/count", handler.JWTMiddleware(http.HandlerFunc(hub.PresenceCountHandler)))
+
 	// Websocket routes
-	router.HandleFunc("/ws", hub.HandleConnection)
+	router.Handle("GET /ws", handler.JWTMiddleware(http.HandlerFunc(hub.HandleConnection)))
+
+	router.Handle("/profile", handler.JWTMiddleware(http.HandlerFunc(profiles.ProfilePageHandler)))
+	router.Handle("POST /profile/settings", handler.JWTMiddleware(http.HandlerFunc(profiles.SaveProfileSettingsHandler)))
+	router.Handle("POST /profile/account", handler.JWTMiddleware(http.HandlerFunc(accounts.SaveAccountHandler)))
+	router.Handle("POST /profile/email", handler.JWTMiddleware(http.HandlerFunc(accounts.RequestEmailChangeHandler)))
+	router.HandleFunc("GET /profile/email/confirm", accounts.ConfirmEmailChangeHandler)
+	router.Handle("GET /api/users/{id}/profile", handler.JWTMiddleware(http.HandlerFunc(handler.PublicProfileHandler)))
+	router.Handle("POST /api/profile/avatar", handler.JWTMiddleware(http.HandlerFunc(avatars.UploadHandler)))
+	router.Handle("DELETE /api/profile/avatar", handler.JWTMiddleware(http.HandlerFunc(avatars.DeleteHandler)))
+	router.HandleFunc("GET /avatars/{hash}/{file}", avatars.ServeHandler)
+
+	// Versioned JSON API for non-browser clients, with bearer-token auth
+	router.HandleFunc("POST /api/v1/auth/signup", v1.SignupHandler)
+	router.HandleFunc("POST /api/v1/auth/login", v1.LoginHandler)
+	router.Handle("GET /api/v1/me", api.BearerAuth(http.HandlerFunc(v1.MeHandler)))
+	router.Handle("PATCH /api/v1/me", api.BearerAuth(http.HandlerFunc(v1.UpdateMeHandler)))
+	router.Handle("POST /api/v1/me/email", api.BearerAuth(http.HandlerFunc(v1.ChangeEmailHandler)))
+	router.Handle("GET /api/v1/me/interests", api.BearerAuth(http.HandlerFunc(v1.MyInterestsHandler)))
+	router.Handle("PUT /api/v1/me/interests", api.BearerAuth(http.HandlerFunc(v1.SetMyInterestsHandler)))
+	router.HandleFunc("GET /api/v1/interests", v1.CatalogHandler)
+	router.Handle("GET /api/v1/journals", api.BearerAuth(http.HandlerFunc(v1.ListJournalsHandler)))
+	router.Handle("POST /api/v1/journals", api.BearerAuth(http.HandlerFunc(v1.CreateJournalHandler)))
+	router.Handle("GET /api/v1/journals/{id}", api.BearerAuth(http.HandlerFunc(v1.GetJournalHandler)))
+	router.Handle("PUT /api/v1/journals/{id}", api.BearerAuth(http.HandlerFunc(v1.UpdateJournalHandler)))
+	router.Handle("DELETE /api/v1/journals/{id}", api.BearerAuth(http.HandlerFunc(v1.DeleteJournalHandler)))
+	router.HandleFunc("/api/v1/", api.NotFoundHandler)
+
+	// API description; see internal/openapi
+	router.HandleFunc("GET /api/openapi.json", openapi.Handler)
 
-	router.Handle("/profile", handler.JWTMiddleware(handler.CSRFMiddleware()(http.HandlerFunc(handler.ProfilePageHandler))))
+	// One CSRF check for every state-changing route. The bearer-token API
+	// doesn't use the session cookie and so is left out.
+	var root http.Handler = handler.CSRF(router, "/api/v1/")
+	if cfg.Env == config.Development {
+		// Check API traffic against the document while developing
+		doc, err := openapi.Load()
+		if err != nil {
+			log.Fatal("Failed to load OpenAPI document:", err)
+		}
+		root = openapi.NewValidator(doc).Middleware(root)
+	}
+
+	// Browser hardening: CSP with per-request nonces, framing and referrer rules
+	security := handler.DefaultSecurityHeaders()
+	security.ReportOnly = cfg.CSPReportOnly
+	root = security.SecureHeaders(root)
 
-	logger := handler.Logger(router)
+	logger := handler.Logger(handler.HSTS(cfg.HSTS())(root))
 	srv := &http.Server{
-		Addr:    ":8080",
-		Handler: logger,
-		// ReadTimeout:  10 * time.Second,
-		// WriteTimeout: 10 * time.Second,
-		// IdleTimeout:  60 * time.Second,
+		Addr:              cfg.Addr,
+		Handler:           logger,
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
 	}
 
-	log.Println("Server listening on http://localhost:8080")
-	log.Fatal(srv.ListenAndServe())
+	// Stops background work such as certificate reloading on shutdown
+	background, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	errc := make(chan error, 2)
+	var redirect *http.Server
+	if cfg.TLS.Mode == config.TLSFile {
+		certificates, err := certs.New(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go certificates.Watch(background, certReloadInterval)
+		srv.TLSConfig = certificates.TLSConfig()
+
+		if cfg.TLS.RedirectAddr != "" {
+			redirect = &http.Server{
+				Addr:              cfg.TLS.RedirectAddr,
+				Handler:           handler.RedirectToHTTPS(cfg.Addr),
+				ReadHeaderTimeout: 5 * time.Second,
+				IdleTimeout:       time.Minute,
+			}
+			go func() {
+				log.Printf("Redirecting %s to HTTPS", redirect.Addr)
+				errc <- redirect.ListenAndServe()
+			}()
+		}
+	}
+
+	go func() {
+		if srv.TLSConfig != nil {
+			log.Printf("Server listening on %s (TLS)", srv.Addr)
+			errc <- srv.ListenAndServeTLS("", "")
+			return
+		}
+		log.Printf("Server listening on %s", srv.Addr)
+		errc <- srv.ListenAndServe()
+	}()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err := <-errc:
+		log.Fatal(err)
+	case sig := <-sigs:
+		log.Printf("Shutting down: %v", sig)
+	}
+
+	// Fail readiness first and give load balancers DRAIN_DELAY to notice,
+	// then drain HTTP, then close the websockets the HTTP server has handed
+	// off, and only then the pool they all use. A second signal skips the
+	// wait.
+	health.Drain()
+	if cfg.DrainDelay > 0 {
+		log.Printf("Draining for %v", cfg.DrainDelay)
+		select {
+		case <-time.After(cfg.DrainDelay):
+		case <-sigs:
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if redirect != nil {
+		redirect.Shutdown(ctx)
+	}
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Println("Warning: HTTP shutdown incomplete:", err)
+	}
+	if err := hub.Shutdown(ctx); err != nil {
+		log.Println("Warning: websocket shutdown incomplete:", err)
+	}
+	config.DB.Close()
+	log.Println("Server stopped")
 }